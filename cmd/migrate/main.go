@@ -6,6 +6,7 @@ import (
 	"gorm.io/gorm"
 	"rip-go-app/internal/app/ds"
 	"rip-go-app/internal/app/dsn"
+	"rip-go-app/internal/app/repository"
 )
 
 func main() {
@@ -28,11 +29,31 @@ func main() {
 		&ds.Service{},
 		&ds.Order{},
 		&ds.OrderService{},
+		&ds.CustomsItem{},
+		&ds.OrderEvent{},
+		&ds.TrackingEvent{},
+		&ds.SLAPolicy{},
+		&ds.Notification{},
+		&ds.Schedule{},
+		&ds.OrderMessage{},
+		&ds.MessageRead{},
+		&ds.Attachment{},
+		&ds.OrderDocument{},
+		&ds.Payment{},
+		&ds.Cancellation{},
+		&ds.OrderStop{},
 	)
 	if err != nil {
 		panic("cant migrate db")
 	}
 
+	// Индексы полнотекстового и нечёткого поиска
+	for _, stmt := range repository.SearchIndexDDL() {
+		if err := db.Exec(stmt).Error; err != nil {
+			panic("cant create search indexes: " + err.Error())
+		}
+	}
+
 	// Создаем системных пользователей
 	users := []ds.User{
 		{
@@ -10,17 +10,25 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
+	"rip-go-app/internal/app/calendar"
 	"rip-go-app/internal/app/config"
+	"rip-go-app/internal/app/documents"
+	"rip-go-app/internal/app/ds"
 	"rip-go-app/internal/app/dsn"
 	"rip-go-app/internal/app/handler"
+	"rip-go-app/internal/app/jobs"
 	"rip-go-app/internal/app/repository"
 	"rip-go-app/internal/app/auth"
 	"rip-go-app/internal/app/service"
 	"rip-go-app/internal/app/middleware"
+	"rip-go-app/internal/app/payments"
+	"rip-go-app/internal/app/storage"
 	
 	// Swagger imports
 	_ "rip-go-app/docs"
@@ -47,6 +55,48 @@ func main() {
 		logrus.Fatalf("error initializing repository: %v", err)
 	}
 
+	// Настраиваем очередь модерации
+	repo.SetQueuePolicy(repository.QueuePolicy{
+		Lease:        time.Duration(conf.QueueLeaseMinutes) * time.Minute,
+		OverdueAfter: time.Duration(conf.QueueOverdueHours) * time.Hour,
+		AutoAssign:   conf.QueueAutoAssign,
+	})
+
+	// Штрафы за отмену заявок
+	var cancellationRules []repository.CancellationRule
+	for _, fee := range conf.CancellationFees {
+		cancellationRules = append(cancellationRules, repository.CancellationRule{
+			Status:     fee.Status,
+			Percent:    fee.Percent,
+			MinFee:     fee.MinFee,
+			GraceHours: fee.GraceHours,
+		})
+	}
+	repo.SetCancellationPolicy(repository.CancellationPolicy{Rules: cancellationRules})
+
+	// Специальные тарифы возврата
+	var returnTariffs []repository.ReturnTariff
+	for _, t := range conf.ReturnTariffs {
+		returnTariffs = append(returnTariffs, repository.ReturnTariff{
+			Name:        t.Name,
+			Discount:    t.Discount,
+			Description: t.Description,
+		})
+	}
+	repo.SetReturnTariffs(returnTariffs)
+
+	// Рабочий календарь для сроков SLA и расписаний
+	businessCalendar := calendar.NewCalendar(conf.BusinessHoursStart, conf.BusinessHoursEnd, conf.BusinessTimezone)
+	businessCalendar.AddHolidays(conf.Holidays...)
+	repo.SetCalendar(businessCalendar)
+	repo.SetRejectInfeasibleDeadlines(conf.RejectInfeasibleDeadlines)
+
+	// Фоновая проверка сроков SLA
+	go jobs.NewSLAChecker(repo, time.Duration(conf.SLACheckIntervalSeconds)*time.Second).Run(context.Background())
+
+	// Создание заявок по расписаниям регулярных отправок
+	go jobs.NewScheduler(repo, time.Duration(conf.ScheduleIntervalSeconds)*time.Second).Run(context.Background())
+
 	// Инициализируем JWT сервис
 	jwtService := auth.NewJWTService(
 		conf.JWTSecret,
@@ -78,6 +128,50 @@ func main() {
 	// Создаем хендлер
 	handler := handler.NewHandler(repo, authService, authMiddleware)
 
+	// Хранилище файлов вложений
+	blobStore, err := newBlobStore(conf)
+	if err != nil {
+		logrus.Warnf("file storage disabled: %v", err)
+		blobStore = nil
+	} else {
+		handler.SetBlobStore(blobStore, attachmentPolicy(conf))
+	}
+
+	// Окончательное удаление записей из корзины по истечении срока хранения
+	repo.SetTrashRetention(time.Duration(conf.TrashRetentionDays) * 24 * time.Hour)
+	go jobs.NewTrashPurger(repo, blobStore, time.Duration(conf.TrashPurgeIntervalSeconds)*time.Second).Run(context.Background())
+
+	// Генератор PDF-документов заявок
+	documentGenerator, err := documents.NewGenerator(conf.PDFFontPath, documents.Company{
+		Name:        conf.CompanyName,
+		INN:         conf.CompanyINN,
+		KPP:         conf.CompanyKPP,
+		OGRN:        conf.CompanyOGRN,
+		Address:     conf.CompanyAddress,
+		Phone:       conf.CompanyPhone,
+		Bank:        conf.CompanyBank,
+		BIK:         conf.CompanyBIK,
+		Account:     conf.CompanyAccount,
+		CorrAccount: conf.CompanyCorrAccount,
+		VATRate:     conf.CompanyVATRate,
+	})
+	if err != nil {
+		logrus.Warnf("order documents disabled: %v", err)
+	} else {
+		handler.SetDocumentGenerator(documentGenerator)
+	}
+
+	// Оплата заявок
+	switch conf.PaymentProvider {
+	case "mock":
+		paymentService := service.NewPaymentService(repo, payments.NewMock(conf.PaymentWebhookSecret), conf.PaymentCurrency)
+		handler.SetPaymentService(paymentService)
+		// Повтор возвратов по отмене, которые провайдер не выполнил сразу
+		go jobs.NewRefundRetrier(repo, paymentService, time.Duration(conf.RefundRetryIntervalSeconds)*time.Second).Run(context.Background())
+	default:
+		logrus.Warnf("payments disabled: unknown provider %q", conf.PaymentProvider)
+	}
+
 	// Создаем роутер
 	r := gin.Default()
 
@@ -97,11 +191,41 @@ func main() {
 	logrus.Info("Application terminated")
 }
 
+// newBlobStore - хранилище файлов по настройкам: локальный каталог или S3-совместимое хранилище
+func newBlobStore(conf *config.Config) (storage.Store, error) {
+	switch conf.StorageDriver {
+	case "s3":
+		return storage.NewS3Store(conf.S3Endpoint, conf.S3Region, conf.S3Bucket, conf.S3AccessKey, conf.S3SecretKey)
+	case "", "local":
+		path := conf.StorageLocalPath
+		if path == "" {
+			path = "uploads"
+		}
+		key := conf.StorageSigningKey
+		if key == "" {
+			key = conf.JWTSecret
+		}
+		return storage.NewLocalStore(path, "/api/files", key)
+	default:
+		return nil, fmt.Errorf("unknown storage driver: %s", conf.StorageDriver)
+	}
+}
+
+// attachmentPolicy - ограничения вложений из настроек
+func attachmentPolicy(conf *config.Config) handler.AttachmentPolicy {
+	return handler.AttachmentPolicy{
+		MaxSize:      int64(conf.AttachmentMaxSizeMB) << 20,
+		AllowedTypes: conf.AttachmentAllowedTypes,
+		URLTTL:       time.Duration(conf.AttachmentURLTTLSeconds) * time.Second,
+	}
+}
+
 func registerRoutes(r *gin.Engine, handler *handler.Handler) {
 	// Маршруты для четырех страниц
 	r.GET("/", handler.GetServices)                    // Главная страница со списком услуг
 	r.GET("/service/:id", handler.GetService)          // Страница с подробной информацией об услуге
 	r.GET("/order", handler.GetOrderDetails)           // Страница с деталями заявки
+	r.GET("/order/:id", handler.AuthMiddleware.RequireAuth(), handler.RequireOrderAccess(), handler.GetOrderPage) // Страница заявки с обсуждением
 	r.GET("/calculator", handler.GetCalculator)        // Страница калькулятора
 	r.POST("/calculator", handler.PostCalculator)      // Обработка формы калькулятора
 
@@ -117,9 +241,6 @@ func registerRoutes(r *gin.Engine, handler *handler.Handler) {
 	r.POST("/api/calculatecargo", handler.CalculateService) // Расчет стоимости грузоперевозки
 	r.POST("/api/submitcargoorder", handler.AuthMiddleware.RequireAuth(), handler.SubmitOrder) // Отправка заявки на грузоперевозку
 
-	// API маршрут для обновления статуса заказа через курсор
-	r.PUT("/api/order/:id/status", handler.UpdateOrderStatus) // Обновление статуса заказа
-
     // CRUD JSON для услуг
     r.GET("/api/services", handler.GetAllServicesJSON)     // Список всех услуг с фильтрацией
     r.GET("/api/services/:id", handler.GetServiceJSON)
@@ -139,60 +260,79 @@ func registerRoutes(r *gin.Engine, handler *handler.Handler) {
     {
         authGroup.GET("/profile", handler.GetUserProfile)
         authGroup.PUT("/profile", handler.UpdateUserProfile)
+        authGroup.PUT("/:id/credit", handler.AuthMiddleware.RequireRole(ds.RoleAdmin), handler.SetUserCredit)
     }
 
-    // Заявки (требуют авторизации)
-    ordersGroup := r.Group("/api/orders")
-    ordersGroup.Use(handler.AuthMiddleware.RequireAuth())
+    // Корзина удалённых заявок и услуг (администратор)
+    trash := r.Group("/api/trash")
+    trash.Use(handler.AuthMiddleware.RequireAuth(), handler.AuthMiddleware.RequireRole(ds.RoleAdmin))
     {
-        ordersGroup.GET("", handler.GetOrders)
-        ordersGroup.GET("/:id", handler.GetOrder)
-        ordersGroup.DELETE("/:id", handler.DeleteOrder)
-    }
-    
-    // Специфичные маршруты с дополнительными сегментами
-    r.PUT("/api/orders/:id/form", handler.AuthMiddleware.RequireAuth(), handler.FormOrder)
-    r.PUT("/api/orders/:id/update", handler.AuthMiddleware.RequireAuth(), handler.UpdateOrder)
-    
-    // Маршрут модератора для завершения заявки
-    moderatorCompleteGroup := r.Group("/api/orders/:id")
-    moderatorCompleteGroup.Use(handler.AuthMiddleware.RequireModerator())
-    {
-        moderatorCompleteGroup.PUT("/complete", handler.CompleteOrder)
+        trash.GET("/orders", handler.GetTrashedOrders)
+        trash.POST("/orders/:id/restore", handler.RestoreOrder)
+        trash.GET("/services", handler.GetTrashedServices)
+        trash.POST("/services/:id/restore", handler.RestoreService)
     }
 
-    // Новые маршруты: логистические заявки (основные), алиасы для совместимости
-    logisticGroup := r.Group("/api/logistic-requests")
-    logisticGroup.Use(handler.AuthMiddleware.RequireAuth())
+    // Очередь модерации
+    r.GET("/api/queue", handler.AuthMiddleware.RequireAuth(), handler.AuthMiddleware.RequireRole(ds.RoleManager, ds.RoleAdmin), handler.GetQueue)
+
+    // SLA: политики (администратор), отчёт (модераторы)
+    sla := r.Group("/api/sla")
+    sla.Use(handler.AuthMiddleware.RequireAuth(), handler.AuthMiddleware.RequireRole(ds.RoleManager, ds.RoleAdmin))
     {
-        logisticGroup.GET("", handler.GetOrders)
-        logisticGroup.GET("/:id", handler.GetOrder)
-        logisticGroup.DELETE("/:id", handler.DeleteOrder)
-        logisticGroup.PUT("/:id/form", handler.FormOrder)
-        logisticGroup.PUT("/:id/update", handler.UpdateOrder)
-        logisticGroup.DELETE("/:id/services/:service_id", handler.RemoveServiceFromOrder)
-        logisticGroup.PUT("/:id/services/:service_id", handler.UpdateOrderService)
+        admin := handler.AuthMiddleware.RequireRole(ds.RoleAdmin)
+        sla.GET("/policies", handler.GetSLAPolicies)
+        sla.POST("/policies", admin, handler.CreateSLAPolicy)
+        sla.PUT("/policies/:id", admin, handler.UpdateSLAPolicy)
+        sla.DELETE("/policies/:id", admin, handler.DeleteSLAPolicy)
+        sla.GET("/report", handler.GetSLAReport)
     }
-    // Завершение логистической заявки (модератор)
-    moderatorLR := r.Group("/api/logistic-requests/:id")
-    moderatorLR.Use(handler.AuthMiddleware.RequireModerator())
+
+    // Регулярные отправки
+    schedules := r.Group("/api/schedules")
+    schedules.Use(handler.AuthMiddleware.RequireAuth())
     {
-        moderatorLR.PUT("/complete", handler.CompleteOrder)
+        schedules.GET("", handler.GetSchedules)
+        schedules.POST("", handler.CreateSchedule)
+        schedules.POST("/preview", handler.PreviewScheduleRule)
+        schedules.GET("/:id", handler.GetSchedule)
+        schedules.PUT("/:id", handler.UpdateSchedule)
+        schedules.DELETE("/:id", handler.DeleteSchedule)
+        schedules.POST("/:id/pause", handler.PauseSchedule)
+        schedules.POST("/:id/resume", handler.ResumeSchedule)
+        schedules.GET("/:id/preview", handler.PreviewSchedule)
     }
 
-    // Алиас статуса для логистических заявок
-    r.PUT("/api/logistic-requests/:id/status", handler.UpdateOrderStatus)
+    // Уведомления текущего пользователя
+    r.GET("/api/notifications", handler.AuthMiddleware.RequireAuth(), handler.GetNotifications)
+    r.PUT("/api/notifications/:id/read", handler.AuthMiddleware.RequireAuth(), handler.MarkNotificationRead)
+    r.GET("/api/messages/unread", handler.AuthMiddleware.RequireAuth(), handler.GetUnreadMessages)
+
+    // Уведомления платёжного провайдера (подлинность проверяется подписью)
+    r.POST("/api/payments/webhook/:provider", handler.PaymentWebhook)
+
+    // Скачивание файлов по подписанным ссылкам (без авторизации)
+    r.GET("/api/files/*key", handler.DownloadFile)
+
+    // Поиск по заявкам, комментариям и услугам
+    r.GET("/api/search", handler.AuthMiddleware.RequireAuth(), handler.Search)
+
+    // Заявки: основные маршруты и алиасы логистических заявок
+    handler.RegisterOrderRoutes(r.Group("/api/orders", handler.AuthMiddleware.RequireAuth()))
+    handler.RegisterOrderRoutes(r.Group("/api/logistic-requests", handler.AuthMiddleware.RequireAuth()))
+
+    // Старый маршрут смены статуса
+    r.PUT("/api/order/:id/status", handler.AuthMiddleware.RequireAuth(), handler.RequireOrderAccess(), handler.UpdateOrderStatus)
 
     // Эндпоинт оформления логистической заявки (новый алиас)
     r.POST("/api/submit-cargo-logistic-request", handler.AuthMiddleware.RequireAuth(), handler.SubmitOrder)
 
+    // Публичное отслеживание отправления по номеру
+    r.GET("/track/:number", handler.TrackShipment)
+
     // Иконка корзины доступна без авторизации (для фронта)
     r.GET("/api/cart/icon", handler.GetCartIcon)
 
-    // М-М заявка-услуга
-    r.DELETE("/api/orders/:id/services/:service_id", handler.RemoveServiceFromOrder)
-    r.PUT("/api/orders/:id/services/:service_id", handler.UpdateOrderService)
-
     // Swagger документация
     r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
-}
\ No newline at end of file
+}
@@ -46,6 +46,8 @@ func StartServer() {
     r.GET("/api/orders", handler.GetOrders)
     r.PUT("/api/orders/:id/form", handler.FormOrder)
     r.PUT("/api/orders/:id/complete", handler.CompleteOrder)
+    r.DELETE("/api/orders/:id/lines/:line_id", handler.RemoveOrderLine)
+    r.PUT("/api/orders/:id/lines/:line_id", handler.UpdateOrderLine)
     r.DELETE("/api/orders/:id/services/:service_id", handler.RemoveServiceFromOrder)
     r.PUT("/api/orders/:id/services/:service_id", handler.UpdateOrderService)
     r.GET("/api/orders/:id", handler.GetOrder)
@@ -56,6 +58,8 @@ func StartServer() {
     r.GET("/api/logistic-requests", handler.GetOrders)
     r.PUT("/api/logistic-requests/:id/form", handler.FormOrder)
     r.PUT("/api/logistic-requests/:id/complete", handler.CompleteOrder)
+    r.DELETE("/api/logistic-requests/:id/lines/:line_id", handler.RemoveOrderLine)
+    r.PUT("/api/logistic-requests/:id/lines/:line_id", handler.UpdateOrderLine)
     r.DELETE("/api/logistic-requests/:id/services/:service_id", handler.RemoveServiceFromOrder)
     r.PUT("/api/logistic-requests/:id/services/:service_id", handler.UpdateOrderService)
     r.GET("/api/logistic-requests/:id", handler.GetOrder)
@@ -3,6 +3,7 @@ package calculator
 import (
 	"math"
 	"strings"
+	"rip-go-app/internal/app/customs"
 	"rip-go-app/internal/app/ds"
 )
 
@@ -16,20 +17,35 @@ func NewDeliveryCalculator() *DeliveryCalculator {
 
 // DeliveryResult - результат расчета доставки
 type DeliveryResult struct {
-	DeliveryDays int     `json:"delivery_days"`
-	TotalCost    float64 `json:"total_cost"`
-	Distance     float64 `json:"distance"`
-	Volume       float64 `json:"volume"`
-	IsValid      bool    `json:"is_valid"`
-	ErrorMessage string  `json:"error_message,omitempty"`
+	DeliveryDays int                `json:"delivery_days"`
+	TotalCost    float64            `json:"total_cost"`
+	Distance     float64            `json:"distance"`
+	Volume       float64            `json:"volume"`
+	CustomsDays  int                `json:"customs_days"`
+	CustomsCost  float64            `json:"customs_cost"`
+	Crossings    []customs.Crossing `json:"crossings,omitempty"`
+	IsValid      bool               `json:"is_valid"`
+	ErrorMessage string             `json:"error_message,omitempty"`
 }
 
-// CalculateDelivery - основной метод расчета доставки
+// CalculateDelivery - основной метод расчета доставки (стандартный уровень сервиса)
 func (dc *DeliveryCalculator) CalculateDelivery(service ds.Service, fromCity, toCity string, length, width, height, weight float64) DeliveryResult {
+	return dc.CalculateDeliveryLevel(service, ds.LevelStandard, fromCity, toCity, length, width, height, weight)
+}
+
+// CalculateDeliveryLevel - расчет доставки на выбранном уровне сервиса
+func (dc *DeliveryCalculator) CalculateDeliveryLevel(service ds.Service, levelName string, fromCity, toCity string, length, width, height, weight float64) DeliveryResult {
 	result := DeliveryResult{
 		IsValid: true,
 	}
 
+	level, ok := dc.ServiceLevel(service.ID, levelName)
+	if !ok {
+		result.IsValid = false
+		result.ErrorMessage = "Уровень сервиса недоступен для выбранного типа транспорта"
+		return result
+	}
+
 	// Проверяем ограничения
 	if !dc.validateConstraints(service, length, width, height, weight) {
 		result.IsValid = false
@@ -44,14 +60,28 @@ func (dc *DeliveryCalculator) CalculateDelivery(service ds.Service, fromCity, to
 	result.Distance = dc.calculateDistance(fromCity, toCity)
 
 	// Рассчитываем сроки доставки
-	result.DeliveryDays = dc.calculateDeliveryDays(service, result.Distance, result.Volume, weight)
+	result.DeliveryDays = dc.calculateDeliveryDays(service, level, result.Distance, result.Volume, weight)
 
 	// Рассчитываем стоимость
-	result.TotalCost = dc.calculateCost(service, result.Distance, result.Volume, weight)
+	result.TotalCost = dc.calculateCost(service, level, result.Distance, result.Volume, weight)
 
 	return result
 }
 
+// ApplyCustoms - добавление сроков и стоимости таможенного оформления к результату расчёта
+func (dc *DeliveryCalculator) ApplyCustoms(result *DeliveryResult, originCountry, destinationCountry string, declaredValue float64) {
+	if !result.IsValid || originCountry == "" || destinationCountry == "" || originCountry == destinationCountry {
+		return
+	}
+
+	clearance := customs.CalculateClearance(originCountry, destinationCountry, declaredValue)
+	result.CustomsDays = clearance.Days
+	result.CustomsCost = clearance.Cost
+	result.Crossings = clearance.Crossings
+	result.DeliveryDays += clearance.Days
+	result.TotalCost = math.Round((result.TotalCost+clearance.Cost)*100) / 100
+}
+
 // validateConstraints - проверка ограничений
 func (dc *DeliveryCalculator) validateConstraints(service ds.Service, length, width, height, weight float64) bool {
 	volume := length * width * height
@@ -103,15 +133,15 @@ func (dc *DeliveryCalculator) getMaxDimensions(serviceID int) MaxDimensions {
 }
 
 // calculateDeliveryDays - расчет сроков доставки
-func (dc *DeliveryCalculator) calculateDeliveryDays(service ds.Service, distance, volume, weight float64) int {
+func (dc *DeliveryCalculator) calculateDeliveryDays(service ds.Service, level ServiceLevel, distance, volume, weight float64) int {
 	// Базовые сроки
 	baseDays := service.DeliveryDays
 	
 	// Коэффициенты для разных типов транспорта
 	coefficients := dc.getDeliveryCoefficients(service.ID)
 	
-	// Расчет по расстоянию
-	distanceDays := math.Ceil(distance / coefficients.DistancePerDay)
+	// Расчет по расстоянию (уровень сервиса меняет дневной пробег)
+	distanceDays := math.Ceil(distance / (coefficients.DistancePerDay * level.SpeedFactor))
 	
 	// Дополнительные дни за сложность груза
 	complexityDays := dc.calculateComplexityDays(volume, weight, service.ID)
@@ -199,7 +229,7 @@ func (dc *DeliveryCalculator) getMinDeliveryDays(serviceID int) int {
 }
 
 // calculateCost - расчет стоимости доставки
-func (dc *DeliveryCalculator) calculateCost(service ds.Service, distance, volume, weight float64) float64 {
+func (dc *DeliveryCalculator) calculateCost(service ds.Service, level ServiceLevel, distance, volume, weight float64) float64 {
 	// Базовая стоимость
 	baseCost := service.Price
 	
@@ -226,6 +256,9 @@ func (dc *DeliveryCalculator) calculateCost(service ds.Service, distance, volume
 		totalCost = baseCost
 	}
 	
+	// Наценка или скидка уровня сервиса
+	totalCost *= level.PriceMultiplier
+	
 	// Округляем до рублей
 	return math.Round(totalCost*100) / 100
 }
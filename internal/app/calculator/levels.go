@@ -0,0 +1,73 @@
+package calculator
+
+import "rip-go-app/internal/app/ds"
+
+// ServiceLevel - уровень сервиса для типа транспорта
+type ServiceLevel struct {
+	Name            string  `json:"name"`
+	SpeedFactor     float64 `json:"speed_factor"`     // множитель дневного пробега
+	PriceMultiplier float64 `json:"price_multiplier"` // множитель стоимости
+}
+
+// standardLevel - стандартный уровень без изменения сроков и цены
+var standardLevel = ServiceLevel{Name: ds.LevelStandard, SpeedFactor: 1, PriceMultiplier: 1}
+
+// GetServiceLevels - уровни сервиса, доступные для типа транспорта
+func (dc *DeliveryCalculator) GetServiceLevels(serviceID int) []ServiceLevel {
+	switch serviceID {
+	case 1: // Фура: экспресс - сменные водители
+		return []ServiceLevel{
+			{Name: ds.LevelEconomy, SpeedFactor: 0.8, PriceMultiplier: 0.85},
+			standardLevel,
+			{Name: ds.LevelExpress, SpeedFactor: 1.5, PriceMultiplier: 1.35},
+		}
+	case 2: // Малотоннажный грузовик
+		return []ServiceLevel{
+			{Name: ds.LevelEconomy, SpeedFactor: 0.85, PriceMultiplier: 0.9},
+			standardLevel,
+			{Name: ds.LevelExpress, SpeedFactor: 1.5, PriceMultiplier: 1.3},
+		}
+	case 3: // Авиаперевозка: эконом - сборные рейсы
+		return []ServiceLevel{
+			{Name: ds.LevelEconomy, SpeedFactor: 0.7, PriceMultiplier: 0.8},
+			standardLevel,
+			{Name: ds.LevelExpress, SpeedFactor: 1.3, PriceMultiplier: 1.5},
+		}
+	case 4: // Поезд: экспресс - ускоренные контейнерные поезда
+		return []ServiceLevel{
+			{Name: ds.LevelEconomy, SpeedFactor: 0.75, PriceMultiplier: 0.8},
+			standardLevel,
+			{Name: ds.LevelExpress, SpeedFactor: 1.25, PriceMultiplier: 1.3},
+		}
+	case 5: // Корабль: экспресса нет
+		return []ServiceLevel{
+			{Name: ds.LevelEconomy, SpeedFactor: 0.8, PriceMultiplier: 0.75},
+			standardLevel,
+		}
+	case 6: // Мультимодальные
+		return []ServiceLevel{
+			{Name: ds.LevelEconomy, SpeedFactor: 0.8, PriceMultiplier: 0.85},
+			standardLevel,
+			{Name: ds.LevelExpress, SpeedFactor: 1.3, PriceMultiplier: 1.4},
+		}
+	default:
+		return []ServiceLevel{
+			{Name: ds.LevelEconomy, SpeedFactor: 0.8, PriceMultiplier: 0.85},
+			standardLevel,
+			{Name: ds.LevelExpress, SpeedFactor: 1.3, PriceMultiplier: 1.35},
+		}
+	}
+}
+
+// ServiceLevel - уровень сервиса типа транспорта по названию (пустое - стандартный)
+func (dc *DeliveryCalculator) ServiceLevel(serviceID int, name string) (ServiceLevel, bool) {
+	if name == "" {
+		name = ds.LevelStandard
+	}
+	for _, level := range dc.GetServiceLevels(serviceID) {
+		if level.Name == name {
+			return level, true
+		}
+	}
+	return ServiceLevel{}, false
+}
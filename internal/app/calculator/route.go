@@ -0,0 +1,89 @@
+package calculator
+
+import (
+	"fmt"
+	"math"
+
+	"rip-go-app/internal/app/ds"
+)
+
+// RouteLeg - участок маршрута между соседними остановками с грузом на борту
+type RouteLeg struct {
+	FromCity string  `json:"from_city"`
+	ToCity   string  `json:"to_city"`
+	Weight   float64 `json:"weight"` // кг на борту на участке
+	Volume   float64 `json:"volume"` // м³ на борту на участке
+}
+
+// LegResult - расчёт участка маршрута
+type LegResult struct {
+	RouteLeg
+	Distance float64 `json:"distance"`
+	Cost     float64 `json:"cost"`
+	Overload bool    `json:"overload,omitempty"` // груз на участке превышает вместимость транспорта
+}
+
+// RouteResult - расчёт маршрута с несколькими остановками
+type RouteResult struct {
+	Legs         []LegResult `json:"legs"`
+	Distance     float64     `json:"distance"`
+	DeliveryDays int         `json:"delivery_days"`
+	TotalCost    float64     `json:"total_cost"`
+	IsValid      bool        `json:"is_valid"`
+	ErrorMessage string      `json:"error_message,omitempty"`
+}
+
+// CalculateRoute - расчёт маршрута по участкам на уровне сервиса levelName: вместимость транспорта
+// проверяется на каждом участке, пробег оплачивается с учётом загрузки участка, вес и объём - один раз при погрузке
+func (dc *DeliveryCalculator) CalculateRoute(service ds.Service, levelName string, legs []RouteLeg) RouteResult {
+	result := RouteResult{IsValid: true, Legs: make([]LegResult, 0, len(legs))}
+	if len(legs) == 0 {
+		result.IsValid = false
+		result.ErrorMessage = "Маршрут не содержит участков"
+		return result
+	}
+	level, ok := dc.ServiceLevel(service.ID, levelName)
+	if !ok {
+		result.IsValid = false
+		result.ErrorMessage = "Уровень сервиса недоступен для выбранного типа транспорта"
+		return result
+	}
+
+	coeffs := dc.getCostCoefficients(service.ID)
+	var loadedWeight, loadedVolume, peakWeight, peakVolume float64
+	prevWeight, prevVolume := 0.0, 0.0
+	distanceCost := 0.0
+
+	for _, leg := range legs {
+		lr := LegResult{RouteLeg: leg, Distance: dc.calculateDistance(leg.FromCity, leg.ToCity)}
+		if leg.Weight > service.MaxWeight || leg.Volume > service.MaxVolume {
+			lr.Overload = true
+			if result.IsValid {
+				result.IsValid = false
+				result.ErrorMessage = fmt.Sprintf("Груз на участке %s → %s превышает вместимость транспорта", leg.FromCity, leg.ToCity)
+			}
+		}
+
+		// Догруз на остановке оплачивается по весу и объёму
+		loadedWeight += math.Max(leg.Weight-prevWeight, 0)
+		loadedVolume += math.Max(leg.Volume-prevVolume, 0)
+		prevWeight, prevVolume = leg.Weight, leg.Volume
+		peakWeight = math.Max(peakWeight, leg.Weight)
+		peakVolume = math.Max(peakVolume, leg.Volume)
+
+		multiplier := dc.calculateComplexityMultiplier(leg.Volume, leg.Weight, service.ID)
+		lr.Cost = math.Round(lr.Distance*coeffs.DistanceRate*multiplier*level.PriceMultiplier*100) / 100
+		distanceCost += lr.Cost
+		result.Distance += lr.Distance
+		result.Legs = append(result.Legs, lr)
+	}
+	if !result.IsValid {
+		return result
+	}
+
+	result.DeliveryDays = dc.calculateDeliveryDays(service, level, result.Distance, peakVolume, peakWeight)
+
+	totalCost := distanceCost + (service.Price+loadedWeight*coeffs.WeightRate+loadedVolume*coeffs.VolumeRate)*level.PriceMultiplier
+	result.TotalCost = math.Round(totalCost*100) / 100
+	return result
+}
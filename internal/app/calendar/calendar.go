@@ -0,0 +1,127 @@
+package calendar
+
+import "time"
+
+// Calendar - рабочий календарь: рабочие часы, рабочие дни недели и праздники
+type Calendar struct {
+	StartHour int             // начало рабочего дня (час)
+	EndHour   int             // конец рабочего дня (час, не включая)
+	Workdays  []time.Weekday  // рабочие дни недели
+	Holidays  map[string]bool // нерабочие даты в формате 2006-01-02
+	Location  *time.Location
+}
+
+// NewCalendar - календарь с рабочими часами пн-пт в указанном часовом поясе
+func NewCalendar(startHour, endHour int, timezone string) *Calendar {
+	loc, err := time.LoadLocation(timezone)
+	if err != nil || timezone == "" {
+		loc = time.Local
+	}
+	if startHour < 0 || startHour > 23 {
+		startHour = 9
+	}
+	if endHour <= startHour || endHour > 24 {
+		endHour = 18
+	}
+	return &Calendar{
+		StartHour: startHour,
+		EndHour:   endHour,
+		Workdays:  []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday},
+		Holidays:  map[string]bool{},
+		Location:  loc,
+	}
+}
+
+// AddHolidays - добавление нерабочих дат (2006-01-02), некорректные даты пропускаются
+func (c *Calendar) AddHolidays(dates ...string) {
+	for _, d := range dates {
+		if _, err := time.Parse("2006-01-02", d); err == nil {
+			c.Holidays[d] = true
+		}
+	}
+}
+
+// IsHoliday - праздничный день
+func (c *Calendar) IsHoliday(t time.Time) bool {
+	return c.Holidays[t.In(c.Location).Format("2006-01-02")]
+}
+
+// IsWorkday - рабочий ли день (день недели и не праздник)
+func (c *Calendar) IsWorkday(t time.Time) bool {
+	t = t.In(c.Location)
+	if c.IsHoliday(t) {
+		return false
+	}
+	for _, d := range c.Workdays {
+		if t.Weekday() == d {
+			return true
+		}
+	}
+	return false
+}
+
+// dayBounds - начало и конец рабочего времени в день t
+func (c *Calendar) dayBounds(t time.Time) (time.Time, time.Time) {
+	t = t.In(c.Location)
+	y, m, d := t.Date()
+	return time.Date(y, m, d, c.StartHour, 0, 0, 0, c.Location),
+		time.Date(y, m, d, c.EndHour, 0, 0, 0, c.Location)
+}
+
+// nextDay - начало следующих суток
+func (c *Calendar) nextDay(t time.Time) time.Time {
+	t = t.In(c.Location)
+	y, m, d := t.Date()
+	return time.Date(y, m, d+1, 0, 0, 0, 0, c.Location)
+}
+
+// AddBusinessHours - момент, когда от start пройдёт указанное число рабочих часов
+func (c *Calendar) AddBusinessHours(start time.Time, hours float64) time.Time {
+	remaining := time.Duration(hours * float64(time.Hour))
+	t := start.In(c.Location)
+
+	// Защита от календаря без рабочих дней
+	for guard := 0; guard < 3660; guard++ {
+		if c.IsWorkday(t) {
+			open, close := c.dayBounds(t)
+			if t.Before(open) {
+				t = open
+			}
+			if t.Before(close) {
+				available := close.Sub(t)
+				if remaining <= available {
+					return t.Add(remaining)
+				}
+				remaining -= available
+			}
+		}
+		t = c.nextDay(t)
+	}
+	return t
+}
+
+// BusinessHoursBetween - число рабочих часов между from и to
+func (c *Calendar) BusinessHoursBetween(from, to time.Time) float64 {
+	if !to.After(from) {
+		return 0
+	}
+
+	var total time.Duration
+	t := from.In(c.Location)
+	for t.Before(to) {
+		if c.IsWorkday(t) {
+			open, close := c.dayBounds(t)
+			if t.After(open) {
+				open = t
+			}
+			if close.After(to) {
+				close = to
+			}
+			if close.After(open) {
+				total += close.Sub(open)
+			}
+		}
+		t = c.nextDay(t)
+	}
+	return total.Hours()
+}
@@ -0,0 +1,88 @@
+package calendar
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// 2 марта 2026 - понедельник
+func at(loc *time.Location, d, h, min int) time.Time {
+	return time.Date(2026, 3, d, h, min, 0, 0, loc)
+}
+
+func testCalendar() *Calendar {
+	c := NewCalendar(9, 18, "UTC")
+	c.AddHolidays("2026-03-04", "not-a-date")
+	return c
+}
+
+func TestAddBusinessHours(t *testing.T) {
+	moscow, err := time.LoadLocation("Europe/Moscow")
+	if err != nil {
+		t.Fatalf("load location: %v", err)
+	}
+	msk := NewCalendar(9, 18, "Europe/Moscow")
+
+	tests := []struct {
+		name  string
+		cal   *Calendar
+		start time.Time
+		hours float64
+		want  time.Time
+	}{
+		{"within day", testCalendar(), at(time.UTC, 2, 10, 0), 3, at(time.UTC, 2, 13, 0)},
+		{"fraction of hour", testCalendar(), at(time.UTC, 2, 9, 0), 0.5, at(time.UTC, 2, 9, 30)},
+		{"before opening", testCalendar(), at(time.UTC, 2, 7, 0), 2, at(time.UTC, 2, 11, 0)},
+		{"after closing", testCalendar(), at(time.UTC, 2, 19, 0), 1, at(time.UTC, 3, 10, 0)},
+		{"ends at closing", testCalendar(), at(time.UTC, 2, 9, 0), 9, at(time.UTC, 2, 18, 0)},
+		{"spills to next day", testCalendar(), at(time.UTC, 2, 16, 0), 4, at(time.UTC, 3, 11, 0)},
+		{"skips holiday", testCalendar(), at(time.UTC, 3, 17, 0), 2, at(time.UTC, 5, 10, 0)},
+		{"skips weekend", testCalendar(), at(time.UTC, 6, 17, 0), 2, at(time.UTC, 9, 10, 0)},
+		{"starts on weekend", testCalendar(), at(time.UTC, 7, 12, 0), 1, at(time.UTC, 9, 10, 0)},
+		{"several days", testCalendar(), at(time.UTC, 5, 9, 0), 18, at(time.UTC, 6, 18, 0)},
+		{"zero hours after closing", testCalendar(), at(time.UTC, 6, 18, 0), 0, at(time.UTC, 9, 9, 0)},
+		{"calendar time zone", msk, at(time.UTC, 2, 5, 0), 1, at(moscow, 2, 10, 0)},
+	}
+
+	for _, tt := range tests {
+		if got := tt.cal.AddBusinessHours(tt.start, tt.hours); !got.Equal(tt.want) {
+			t.Errorf("%s: AddBusinessHours(%s, %v) = %s, want %s", tt.name, tt.start, tt.hours, got, tt.want)
+		}
+	}
+}
+
+func TestBusinessHoursBetween(t *testing.T) {
+	tests := []struct {
+		name     string
+		from, to time.Time
+		want     float64
+	}{
+		{"within day", at(time.UTC, 2, 10, 0), at(time.UTC, 2, 13, 30), 3.5},
+		{"reversed", at(time.UTC, 2, 13, 0), at(time.UTC, 2, 10, 0), 0},
+		{"outside working hours", at(time.UTC, 2, 18, 30), at(time.UTC, 3, 8, 0), 0},
+		{"overnight", at(time.UTC, 2, 16, 0), at(time.UTC, 3, 11, 0), 4},
+		{"weekend", at(time.UTC, 6, 17, 0), at(time.UTC, 9, 10, 0), 2},
+		{"holiday", at(time.UTC, 3, 17, 0), at(time.UTC, 5, 10, 0), 2},
+		{"full week", at(time.UTC, 2, 0, 0), at(time.UTC, 9, 0, 0), 36},
+	}
+
+	cal := testCalendar()
+	for _, tt := range tests {
+		if got := cal.BusinessHoursBetween(tt.from, tt.to); got != tt.want {
+			t.Errorf("%s: BusinessHoursBetween(%s, %s) = %v, want %v", tt.name, tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestBusinessHoursRoundTrip(t *testing.T) {
+	cal := testCalendar()
+	for _, start := range []time.Time{at(time.UTC, 2, 9, 0), at(time.UTC, 3, 15, 20), at(time.UTC, 7, 11, 0)} {
+		for _, hours := range []float64{0.25, 1, 8, 9, 24, 40} {
+			end := cal.AddBusinessHours(start, hours)
+			if got := cal.BusinessHoursBetween(start, end); got != hours {
+				t.Errorf("BusinessHoursBetween(%s, AddBusinessHours(%v)) = %v", start, hours, got)
+			}
+		}
+	}
+}
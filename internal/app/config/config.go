@@ -22,6 +22,82 @@ type Config struct {
 	RedisPort     int
 	RedisPassword string
 	RedisDB       int
+
+	// Очередь модерации
+	QueueLeaseMinutes int    // срок захвата заявки менеджером
+	QueueOverdueHours int    // через сколько часов сформированная заявка считается просроченной
+	QueueAutoAssign   string // автоназначение: "" (выключено), round_robin, workload
+
+	// Рабочий календарь и SLA
+	BusinessHoursStart      int      // начало рабочего дня (час)
+	BusinessHoursEnd        int      // конец рабочего дня (час)
+	BusinessTimezone        string   // часовой пояс, например Europe/Moscow
+	SLACheckIntervalSeconds int      // период фоновой проверки сроков SLA
+	Holidays                []string // праздничные дни (2006-01-02)
+
+	// Окна погрузки и доставки
+	RejectInfeasibleDeadlines bool // запрет формирования заявки с невыполнимым сроком (иначе только отметка)
+
+	// Регулярные отправки
+	ScheduleIntervalSeconds int // период создания заявок по расписаниям
+
+	// Корзина удалённых заявок и услуг
+	TrashRetentionDays        int // срок хранения до окончательного удаления
+	TrashPurgeIntervalSeconds int // период фоновой очистки корзины
+
+	// Вложения и хранилище файлов
+	StorageDriver           string   // local или s3
+	StorageLocalPath        string   // каталог файлов для local
+	StorageSigningKey       string   // ключ подписи ссылок local (по умолчанию JWTSecret)
+	S3Endpoint              string   // адрес S3-совместимого хранилища, например http://localhost:9003
+	S3Region                string
+	S3Bucket                string
+	S3AccessKey             string
+	S3SecretKey             string
+	AttachmentMaxSizeMB     int      // максимальный размер вложения
+	AttachmentURLTTLSeconds int      // срок действия ссылки на скачивание
+	AttachmentAllowedTypes  []string // допустимые типы содержимого
+
+	// Документы заявок (счёт, накладная)
+	PDFFontPath        string  // шрифт TrueType с кириллицей
+	CompanyName        string  // реквизиты компании-перевозчика
+	CompanyINN         string
+	CompanyKPP         string
+	CompanyOGRN        string
+	CompanyAddress     string
+	CompanyPhone       string
+	CompanyBank        string
+	CompanyBIK         string
+	CompanyAccount     string
+	CompanyCorrAccount string
+	CompanyVATRate     float64 // ставка НДС в процентах, 0 - без НДС
+
+	// Оплата
+	PaymentProvider            string // платёжный провайдер: mock
+	PaymentWebhookSecret       string // ключ проверки подписи уведомлений провайдера
+	PaymentCurrency            string
+	RefundRetryIntervalSeconds int // период повтора возвратов по отмене, которые не удалось выполнить сразу
+
+	// Отмена заявок: штрафы по статусам; в статусах без правила отмена запрещена
+	CancellationFees []CancellationFee
+
+	// Специальные тарифы возврата груза
+	ReturnTariffs []ReturnTariff
+}
+
+// CancellationFee - штраф за отмену заявки в статусе Status
+type CancellationFee struct {
+	Status     string
+	Percent    float64 // процент от стоимости заявки
+	MinFee     float64 // минимальный штраф
+	GraceHours int     // бесплатная отмена в течение часов после перехода в статус
+}
+
+// ReturnTariff - специальный тариф возврата: скидка от обычного тарифа перевозки
+type ReturnTariff struct {
+	Name        string
+	Discount    float64 // процент скидки, 100 - возврат бесплатно для клиента
+	Description string
 }
 
 func NewConfig() (*Config, error) {
@@ -0,0 +1,195 @@
+package customs
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"rip-go-app/internal/app/ds"
+)
+
+// Crossing - пересечение одной границы с таможенным оформлением
+type Crossing struct {
+	From string  `json:"from"`
+	To   string  `json:"to"`
+	Days int     `json:"days"`
+	Cost float64 `json:"cost"`
+}
+
+// Clearance - итог таможенного оформления по маршруту
+type Clearance struct {
+	Crossings []Crossing `json:"crossings"`
+	Days      int        `json:"days"`
+	Cost      float64    `json:"cost"`
+}
+
+// borderRule - сроки и стоимость оформления на границе
+type borderRule struct {
+	Days      int
+	BaseCost  float64 // фиксированный сбор за оформление, руб
+	ValueRate float64 // доля от таможенной стоимости груза
+}
+
+// customsUnions - таможенные союзы, внутри которых оформление не требуется
+var customsUnions = [][]string{
+	{"RU", "BY", "KZ", "AM", "KG"}, // ЕАЭС
+	{"AT", "BE", "BG", "HR", "CY", "CZ", "DK", "EE", "FI", "FR", "DE", "GR", "HU", "IE",
+		"IT", "LV", "LT", "LU", "MT", "NL", "PL", "PT", "RO", "SK", "SI", "ES", "SE"}, // ЕС
+}
+
+// borderRules - правила для конкретных границ (ключ - пара стран в алфавитном порядке)
+var borderRules = map[string]borderRule{
+	"BY-PL": {Days: 3, BaseCost: 12000, ValueRate: 0.002},
+	"BY-LT": {Days: 2, BaseCost: 10000, ValueRate: 0.002},
+	"FI-RU": {Days: 2, BaseCost: 11000, ValueRate: 0.002},
+	"CN-RU": {Days: 4, BaseCost: 15000, ValueRate: 0.003},
+	"CN-KZ": {Days: 3, BaseCost: 12000, ValueRate: 0.003},
+	"GE-RU": {Days: 2, BaseCost: 8000, ValueRate: 0.0015},
+	"KZ-UZ": {Days: 1, BaseCost: 6000, ValueRate: 0.0015},
+	"MN-RU": {Days: 2, BaseCost: 9000, ValueRate: 0.002},
+	"RU-TR": {Days: 3, BaseCost: 13000, ValueRate: 0.0025},
+}
+
+// defaultBorderRule - правило для границ, не описанных в borderRules
+var defaultBorderRule = borderRule{Days: 2, BaseCost: 10000, ValueRate: 0.0025}
+
+// transitRoutes - типовые транзитные маршруты (страны по порядку следования)
+var transitRoutes = map[string][]string{
+	"RU-DE": {"RU", "BY", "PL", "DE"},
+	"RU-PL": {"RU", "BY", "PL"},
+	"RU-FR": {"RU", "BY", "PL", "DE", "FR"},
+	"RU-IT": {"RU", "BY", "PL", "DE", "AT", "IT"},
+	"RU-UZ": {"RU", "KZ", "UZ"},
+	"RU-LT": {"RU", "BY", "LT"},
+}
+
+var (
+	countryPattern = regexp.MustCompile(`^[A-Z]{2}$`)
+	hsCodePattern  = regexp.MustCompile(`^[0-9]{6}([0-9]{2}([0-9]{2})?)?$`)
+)
+
+// NormalizeCountry - приведение кода страны к ISO 3166-1 alpha-2 в верхнем регистре
+func NormalizeCountry(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if code == "" {
+		return ds.DefaultCountry, nil
+	}
+	if !countryPattern.MatchString(code) {
+		return "", fmt.Errorf("неверный код страны: %s", code)
+	}
+	return code, nil
+}
+
+// ValidateHSCode - проверка кода ТН ВЭД (6, 8 или 10 цифр)
+func ValidateHSCode(code string) error {
+	if !hsCodePattern.MatchString(strings.ReplaceAll(code, " ", "")) {
+		return fmt.Errorf("неверный код ТН ВЭД: %s", code)
+	}
+	return nil
+}
+
+// ValidateIncoterms - проверка базиса поставки (пустое значение допустимо)
+func ValidateIncoterms(term string) error {
+	if term == "" {
+		return nil
+	}
+	for _, t := range ds.Incoterms {
+		if t == term {
+			return nil
+		}
+	}
+	return fmt.Errorf("неизвестный базис поставки: %s", term)
+}
+
+// ValidateItems - проверка товарных позиций
+func ValidateItems(items []ds.CustomsItem) error {
+	for i, it := range items {
+		if strings.TrimSpace(it.Description) == "" {
+			return fmt.Errorf("позиция %d: не указано описание", i+1)
+		}
+		if err := ValidateHSCode(it.HSCode); err != nil {
+			return fmt.Errorf("позиция %d: %v", i+1, err)
+		}
+		if it.Quantity <= 0 || it.NetWeight < 0 || it.Value < 0 {
+			return fmt.Errorf("позиция %d: неверное количество, вес или стоимость", i+1)
+		}
+	}
+	return nil
+}
+
+// DeclaredValue - суммарная таможенная стоимость позиций
+func DeclaredValue(items []ds.CustomsItem) float64 {
+	total := 0.0
+	for _, it := range items {
+		total += it.Value
+	}
+	return total
+}
+
+// Route - страны по маршруту следования
+func Route(origin, destination string) []string {
+	if origin == destination {
+		return []string{origin}
+	}
+	if route, ok := transitRoutes[origin+"-"+destination]; ok {
+		return route
+	}
+	if route, ok := transitRoutes[destination+"-"+origin]; ok {
+		reversed := make([]string, len(route))
+		for i, c := range route {
+			reversed[len(route)-1-i] = c
+		}
+		return reversed
+	}
+	return []string{origin, destination}
+}
+
+// CalculateClearance - расчёт сроков и стоимости оформления на всех границах маршрута
+func CalculateClearance(origin, destination string, declaredValue float64) Clearance {
+	result := Clearance{Crossings: []Crossing{}}
+	route := Route(origin, destination)
+
+	for i := 0; i+1 < len(route); i++ {
+		from, to := route[i], route[i+1]
+		if sameUnion(from, to) {
+			continue
+		}
+		rule, ok := borderRules[pairKey(from, to)]
+		if !ok {
+			rule = defaultBorderRule
+		}
+		cost := math.Round((rule.BaseCost+declaredValue*rule.ValueRate)*100) / 100
+		result.Crossings = append(result.Crossings, Crossing{From: from, To: to, Days: rule.Days, Cost: cost})
+		result.Days += rule.Days
+		result.Cost += cost
+	}
+
+	return result
+}
+
+// sameUnion - находятся ли страны в одном таможенном союзе
+func sameUnion(a, b string) bool {
+	for _, union := range customsUnions {
+		hasA, hasB := false, false
+		for _, c := range union {
+			if c == a {
+				hasA = true
+			}
+			if c == b {
+				hasB = true
+			}
+		}
+		if hasA && hasB {
+			return true
+		}
+	}
+	return false
+}
+
+func pairKey(a, b string) string {
+	if a > b {
+		a, b = b, a
+	}
+	return a + "-" + b
+}
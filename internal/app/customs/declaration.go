@@ -0,0 +1,88 @@
+package customs
+
+import (
+	"encoding/xml"
+	"time"
+
+	"rip-go-app/internal/app/ds"
+)
+
+// Declaration - блок данных таможенной декларации для передачи брокеру
+type Declaration struct {
+	XMLName            xml.Name          `json:"-" xml:"CustomsDeclaration"`
+	OrderID            int               `json:"order_id" xml:"OrderID"`
+	CreatedAt          time.Time         `json:"created_at" xml:"CreatedAt"`
+	Exporter           DeclarationParty  `json:"exporter" xml:"Exporter"`
+	OriginCountry      string            `json:"origin_country" xml:"OriginCountry"`
+	DestinationCountry string            `json:"destination_country" xml:"DestinationCountry"`
+	Route              []string          `json:"route" xml:"Route>Country"`
+	Incoterms          string            `json:"incoterms" xml:"Incoterms"`
+	GrossWeight        float64           `json:"gross_weight" xml:"GrossWeight"`
+	DeclaredValue      float64           `json:"declared_value" xml:"DeclaredValue"`
+	Items              []DeclarationItem `json:"items" xml:"Items>Item"`
+	Clearance          Clearance         `json:"clearance" xml:"-"`
+}
+
+// DeclarationParty - участник внешнеэкономической сделки
+type DeclarationParty struct {
+	Name  string `json:"name" xml:"Name"`
+	Email string `json:"email" xml:"Email"`
+	Phone string `json:"phone,omitempty" xml:"Phone,omitempty"`
+}
+
+// DeclarationItem - товарная позиция декларации
+type DeclarationItem struct {
+	Number        int     `json:"number" xml:"Number,attr"`
+	Description   string  `json:"description" xml:"Description"`
+	HSCode        string  `json:"hs_code" xml:"HSCode"`
+	Quantity      int     `json:"quantity" xml:"Quantity"`
+	NetWeight     float64 `json:"net_weight" xml:"NetWeight"`
+	Value         float64 `json:"value" xml:"Value"`
+	Currency      string  `json:"currency" xml:"Currency"`
+	OriginCountry string  `json:"origin_country" xml:"OriginCountry"`
+}
+
+// BuildDeclaration - формирование декларации по заявке (ожидаются предзагруженные Creator и CustomsItems)
+func BuildDeclaration(order ds.Order) Declaration {
+	decl := Declaration{
+		OrderID:            order.ID,
+		CreatedAt:          time.Now(),
+		Exporter:           DeclarationParty{Name: order.Creator.Name, Email: order.Creator.Email, Phone: order.Creator.Phone},
+		OriginCountry:      order.OriginCountry,
+		DestinationCountry: order.DestinationCountry,
+		Route:              Route(order.OriginCountry, order.DestinationCountry),
+		Incoterms:          order.Incoterms,
+		GrossWeight:        order.Weight,
+		DeclaredValue:      DeclaredValue(order.CustomsItems),
+		Items:              make([]DeclarationItem, 0, len(order.CustomsItems)),
+	}
+
+	for i, it := range order.CustomsItems {
+		origin := it.OriginCountry
+		if origin == "" {
+			origin = order.OriginCountry
+		}
+		decl.Items = append(decl.Items, DeclarationItem{
+			Number:        i + 1,
+			Description:   it.Description,
+			HSCode:        it.HSCode,
+			Quantity:      it.Quantity,
+			NetWeight:     it.NetWeight,
+			Value:         it.Value,
+			Currency:      it.Currency,
+			OriginCountry: origin,
+		})
+	}
+	decl.Clearance = CalculateClearance(order.OriginCountry, order.DestinationCountry, decl.DeclaredValue)
+
+	return decl
+}
+
+// ToXML - сериализация декларации в XML с заголовком
+func (d Declaration) ToXML() ([]byte, error) {
+	body, err := xml.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), body...), nil
+}
@@ -0,0 +1,277 @@
+package documents
+
+import (
+	"fmt"
+	"math"
+	"time"
+
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/pdf"
+)
+
+// Виды документов заявки
+const (
+	KindInvoice = "invoice" // счёт на оплату
+	KindWaybill = "waybill" // транспортная накладная
+)
+
+// ValidKind - проверка вида документа
+func ValidKind(kind string) bool {
+	return kind == KindInvoice || kind == KindWaybill
+}
+
+// Company - реквизиты компании-перевозчика
+type Company struct {
+	Name        string
+	INN         string
+	KPP         string
+	OGRN        string
+	Address     string
+	Phone       string
+	Bank        string
+	BIK         string
+	Account     string  // расчётный счёт
+	CorrAccount string  // корреспондентский счёт
+	VATRate     float64 // ставка НДС в процентах, 0 - без НДС
+}
+
+// Generator - формирование PDF-документов по заявке
+type Generator struct {
+	Font    *pdf.Font
+	Company Company
+}
+
+// NewGenerator - генератор со шрифтом TrueType из fontPath (шрифт должен содержать кириллицу)
+func NewGenerator(fontPath string, company Company) (*Generator, error) {
+	font, err := pdf.LoadFont(fontPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Generator{Font: font, Company: company}, nil
+}
+
+// Render - документ вида kind по заявке (ожидаются предзагруженные Creator и Services.Service)
+func (g *Generator) Render(kind string, order ds.Order, issued time.Time) ([]byte, error) {
+	switch kind {
+	case KindInvoice:
+		return g.invoice(order, issued)
+	case KindWaybill:
+		return g.waybill(order, issued)
+	default:
+		return nil, fmt.Errorf("неизвестный вид документа: %s", kind)
+	}
+}
+
+// line - строка счёта
+type line struct {
+	name   string
+	amount float64
+}
+
+// invoiceLines - строки счёта: перевозка по каждой услуге и таможенное оформление.
+// Если тарифы изменились и сумма строк не сходится с итогом заявки, перевозка выставляется одной строкой
+func invoiceLines(order ds.Order) []line {
+	route := fmt.Sprintf("%s - %s", order.FromCity, order.ToCity)
+
+	var lines []line
+	sum := 0.0
+	for _, item := range order.Services {
+		if item.Cost <= 0 || !item.Accepted() {
+			continue
+		}
+		cargo := item.WithOrderDefaults(order)
+		service := item.Service.Name
+		if order.ServiceLevel != "" && order.ServiceLevel != ds.LevelStandard {
+			service += ", " + levelTitles[order.ServiceLevel]
+		}
+		lines = append(lines, line{name: fmt.Sprintf("Перевозка груза (%s), маршрут %s - %s", service, cargo.FromCity, cargo.ToCity), amount: item.Cost})
+		sum += item.Cost
+	}
+
+	transport := order.TotalCost - order.CustomsCost
+	if math.Abs(sum-transport) >= 0.01 {
+		lines = []line{{name: fmt.Sprintf("Транспортные услуги по заявке № %d, маршрут %s", order.ID, route), amount: transport}}
+	}
+	if order.CustomsCost > 0 {
+		lines = append(lines, line{name: fmt.Sprintf("Таможенное оформление (%s - %s)", order.OriginCountry, order.DestinationCountry), amount: order.CustomsCost})
+	}
+	return lines
+}
+
+// invoice - счёт на оплату
+func (g *Generator) invoice(order ds.Order, issued time.Time) ([]byte, error) {
+	c := g.Company
+	doc := pdf.New(g.Font)
+	doc.Title = fmt.Sprintf("Счёт на оплату № %d", order.ID)
+	l := newLayout(doc)
+
+	l.table([]column{{title: "Банк получателя", width: 330}, {title: "БИК / счёт", width: contentWidth - 330}}, [][]string{
+		{c.Bank, fmt.Sprintf("БИК %s\nК/с %s", c.BIK, c.CorrAccount)},
+		{fmt.Sprintf("%s\nИНН %s  КПП %s", c.Name, c.INN, c.KPP), "Р/с " + c.Account},
+	})
+
+	l.gap(lineHeight)
+	l.title(fmt.Sprintf("Счёт на оплату № %d от %s", order.ID, issued.Format("02.01.2006")))
+	l.gap(lineHeight)
+
+	l.field("Поставщик:", fmt.Sprintf("%s, ИНН %s, КПП %s, %s, тел. %s", c.Name, c.INN, c.KPP, c.Address, c.Phone))
+	l.field("Покупатель:", party(order.Creator))
+	l.field("Основание:", fmt.Sprintf("Заявка на перевозку № %d", order.ID))
+
+	lines := invoiceLines(order)
+	rows := make([][]string, 0, len(lines))
+	total := 0.0
+	for i, ln := range lines {
+		rows = append(rows, []string{fmt.Sprint(i + 1), ln.name, "1", "усл.", money(ln.amount), money(ln.amount)})
+		total += ln.amount
+	}
+	l.table([]column{
+		{title: "№", width: 25, right: true},
+		{title: "Наименование", width: 250},
+		{title: "Кол-во", width: 45, right: true},
+		{title: "Ед.", width: 35},
+		{title: "Цена, руб.", width: 80, right: true},
+		{title: "Сумма, руб.", width: contentWidth - 435, right: true},
+	}, rows)
+
+	l.total("Итого:", money(total))
+	if c.VATRate > 0 {
+		vat := total * c.VATRate / (100 + c.VATRate)
+		l.total(fmt.Sprintf("В том числе НДС %s%%:", quantity(c.VATRate)), money(vat))
+	} else {
+		l.total("Без налога (НДС)", "-")
+	}
+	l.total("Всего к оплате:", money(total))
+
+	l.gap(lineHeight)
+	l.paragraph(fmt.Sprintf("Всего наименований %d, на сумму %s руб.", len(lines), money(total)))
+	l.paragraph("Оплата данного счёта означает согласие с условиями перевозки груза.")
+
+	l.signatures("Руководитель", "Главный бухгалтер")
+	return doc.Bytes()
+}
+
+// waybill - транспортная накладная
+func (g *Generator) waybill(order ds.Order, issued time.Time) ([]byte, error) {
+	c := g.Company
+	doc := pdf.New(g.Font)
+	doc.Title = fmt.Sprintf("Транспортная накладная № %d", order.ID)
+	l := newLayout(doc)
+
+	l.title(fmt.Sprintf("Транспортная накладная № %d от %s", order.ID, issued.Format("02.01.2006")))
+	if order.TrackingNumber != nil {
+		l.field("Трек-номер:", *order.TrackingNumber)
+	}
+	l.field("Заявка:", fmt.Sprintf("№ %d", order.ID))
+
+	l.heading("1. Грузоотправитель")
+	l.paragraph(party(order.Creator))
+
+	l.heading("2. Перевозчик")
+	l.paragraph(fmt.Sprintf("%s, ИНН %s, ОГРН %s, %s, тел. %s", c.Name, c.INN, c.OGRN, c.Address, c.Phone))
+
+	l.heading("3. Маршрут")
+	l.field("Пункт погрузки:", fmt.Sprintf("%s (%s)", order.FromCity, order.OriginCountry))
+	l.field("Пункт выгрузки:", fmt.Sprintf("%s (%s)", order.ToCity, order.DestinationCountry))
+	if len(order.Stops) > 0 {
+		rows := make([][]string, 0, len(order.Stops))
+		for _, s := range order.Stops {
+			kind := "Погрузка"
+			if s.Kind == ds.StopDropoff {
+				kind = "Выгрузка"
+			}
+			window := ""
+			switch {
+			case s.WindowFrom != nil && s.WindowTo != nil:
+				window = s.WindowFrom.Format("02.01 15:04") + " - " + s.WindowTo.Format("02.01 15:04")
+			case s.WindowFrom != nil:
+				window = "с " + s.WindowFrom.Format("02.01 15:04")
+			case s.WindowTo != nil:
+				window = "до " + s.WindowTo.Format("02.01 15:04")
+			}
+			rows = append(rows, []string{fmt.Sprint(s.Seq), s.City, kind, quantity(s.Weight), window})
+		}
+		l.table([]column{
+			{title: "№", width: 25, right: true},
+			{title: "Остановка", width: 150},
+			{title: "Операция", width: 80},
+			{title: "Масса, кг", width: 70, right: true},
+			{title: "Окно прибытия", width: contentWidth - 325},
+		}, rows)
+	}
+	if order.Incoterms != "" {
+		l.field("Условия поставки:", order.Incoterms)
+	}
+	l.field("Срок доставки:", fmt.Sprintf("%d дн.", order.TotalDays))
+	if title, ok := levelTitles[order.ServiceLevel]; ok && order.ServiceLevel != ds.LevelStandard {
+		l.field("Уровень сервиса:", title)
+	}
+
+	l.heading("4. Груз")
+	volume := order.Length * order.Width * order.Height
+	l.field("Масса брутто:", quantity(order.Weight)+" кг")
+	l.field("Габариты (Д×Ш×В):", fmt.Sprintf("%s × %s × %s м, объём %s м³",
+		quantity(order.Length), quantity(order.Width), quantity(order.Height), quantity(volume)))
+	if len(order.CustomsItems) > 0 {
+		rows := make([][]string, 0, len(order.CustomsItems))
+		for i, it := range order.CustomsItems {
+			rows = append(rows, []string{fmt.Sprint(i + 1), it.Description, it.HSCode, fmt.Sprint(it.Quantity), quantity(it.NetWeight)})
+		}
+		l.table([]column{
+			{title: "№", width: 25, right: true},
+			{title: "Наименование", width: 250},
+			{title: "Код ТН ВЭД", width: 90},
+			{title: "Кол-во", width: 60, right: true},
+			{title: "Масса нетто, кг", width: contentWidth - 425, right: true},
+		}, rows)
+	}
+
+	l.heading("5. Транспорт")
+	rows := make([][]string, 0, len(order.Services))
+	for _, item := range order.Services {
+		if !item.Accepted() {
+			continue
+		}
+		cargo := item.WithOrderDefaults(order)
+		rows = append(rows, []string{fmt.Sprint(len(rows) + 1), item.Service.Name,
+			fmt.Sprintf("%s - %s", cargo.FromCity, cargo.ToCity), quantity(cargo.Weight), fmt.Sprint(item.Quantity), item.Comment})
+	}
+	l.table([]column{
+		{title: "№", width: 25, right: true},
+		{title: "Вид транспорта", width: 120},
+		{title: "Маршрут", width: 150},
+		{title: "Масса, кг", width: 65, right: true},
+		{title: "Кол-во", width: 45, right: true},
+		{title: "Примечание", width: contentWidth - 405},
+	}, rows)
+
+	l.heading("6. Сроки")
+	if order.FormedAt != nil {
+		l.field("Заявка сформирована:", order.FormedAt.Format("02.01.2006 15:04"))
+	}
+	if order.CompletedAt != nil {
+		l.field("Заявка принята:", order.CompletedAt.Format("02.01.2006 15:04"))
+	}
+
+	l.signatures("Груз сдал", "Груз принял к перевозке", "Груз получил")
+	return doc.Bytes()
+}
+
+// levelTitles - названия уровней сервиса в документах
+var levelTitles = map[string]string{
+	ds.LevelEconomy:  "эконом",
+	ds.LevelStandard: "стандарт",
+	ds.LevelExpress:  "экспресс",
+}
+
+// party - описание участника по данным пользователя
+func party(u ds.User) string {
+	s := u.Name
+	if u.Email != "" {
+		s += ", " + u.Email
+	}
+	if u.Phone != "" {
+		s += ", тел. " + u.Phone
+	}
+	return s
+}
@@ -0,0 +1,201 @@
+package documents
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"rip-go-app/internal/app/pdf"
+)
+
+// Поля страницы и размеры шрифта
+const (
+	margin       = 40.0
+	contentWidth = pdf.PageWidth - 2*margin
+	textSize     = 9.0
+	lineHeight   = 12.0
+)
+
+// column - колонка таблицы
+type column struct {
+	title string
+	width float64
+	right bool // выравнивание по правому краю (суммы, количества)
+}
+
+// layout - последовательная вёрстка сверху вниз с переносом на новую страницу
+type layout struct {
+	doc  *pdf.Document
+	page *pdf.Page
+	y    float64
+}
+
+func newLayout(doc *pdf.Document) *layout {
+	l := &layout{doc: doc}
+	l.newPage()
+	return l
+}
+
+func (l *layout) newPage() {
+	l.page = l.doc.AddPage()
+	l.y = margin
+}
+
+// space - перенос на новую страницу, если блок высотой h не помещается
+func (l *layout) space(h float64) {
+	if l.y+h > pdf.PageHeight-margin {
+		l.newPage()
+	}
+}
+
+// gap - вертикальный отступ
+func (l *layout) gap(h float64) {
+	l.y += h
+}
+
+// title - заголовок документа по центру
+func (l *layout) title(s string) {
+	const size = 14.0
+	l.space(size * 2)
+	l.y += size
+	width := l.doc.Font().Width(s, size)
+	l.page.BoldText((pdf.PageWidth-width)/2, l.y, size, s)
+	l.y += size
+}
+
+// heading - заголовок раздела
+func (l *layout) heading(s string) {
+	l.space(lineHeight * 2)
+	l.y += lineHeight
+	l.page.BoldText(margin, l.y, textSize+1, s)
+	l.y += 4
+}
+
+// paragraph - текст с переносом по ширине страницы
+func (l *layout) paragraph(s string) {
+	for _, line := range l.doc.Font().Wrap(s, textSize, contentWidth) {
+		l.space(lineHeight)
+		l.y += lineHeight
+		l.page.Text(margin, l.y, textSize, line)
+	}
+}
+
+// field - строка «название: значение»; значение переносится в своей колонке
+func (l *layout) field(label, value string) {
+	const labelWidth = 150.0
+	lines := l.doc.Font().Wrap(value, textSize, contentWidth-labelWidth)
+	l.space(lineHeight * float64(len(lines)))
+	for i, line := range lines {
+		l.y += lineHeight
+		if i == 0 {
+			l.page.Text(margin, l.y, textSize, label)
+		}
+		l.page.Text(margin+labelWidth, l.y, textSize, line)
+	}
+}
+
+// table - таблица с рамками; текст ячеек переносится, заголовок повторяется на новой странице
+func (l *layout) table(cols []column, rows [][]string) {
+	const pad = 3.0
+	font := l.doc.Font()
+
+	drawRow := func(cells []string, bold bool) {
+		wrapped := make([][]string, len(cols))
+		height := 0
+		for i, c := range cols {
+			text := ""
+			if i < len(cells) {
+				text = cells[i]
+			}
+			wrapped[i] = font.Wrap(text, textSize, c.width-2*pad)
+			if len(wrapped[i]) > height {
+				height = len(wrapped[i])
+			}
+		}
+		h := float64(height)*lineHeight + 2*pad
+
+		x := margin
+		top := l.y
+		for i, c := range cols {
+			l.page.Rect(x, top, c.width, h, 0.5)
+			for j, line := range wrapped[i] {
+				y := top + pad + float64(j+1)*lineHeight - 3
+				switch {
+				case bold:
+					l.page.BoldText(x+pad, y, textSize, line)
+				case c.right:
+					l.page.TextRight(x+c.width-pad, y, textSize, line)
+				default:
+					l.page.Text(x+pad, y, textSize, line)
+				}
+			}
+			x += c.width
+		}
+		l.y += h
+	}
+
+	header := make([]string, len(cols))
+	for i, c := range cols {
+		header[i] = c.title
+	}
+
+	l.gap(4)
+	l.space(lineHeight*2 + 12)
+	drawRow(header, true)
+	for _, row := range rows {
+		before := l.page
+		l.space(lineHeight*2 + 6)
+		if l.page != before {
+			drawRow(header, true)
+		}
+		drawRow(row, false)
+	}
+}
+
+// total - итоговая строка справа под таблицей
+func (l *layout) total(label, value string) {
+	l.space(lineHeight)
+	l.y += lineHeight
+	right := margin + contentWidth
+	font := l.doc.Font()
+	l.page.BoldText(right-font.Width(value, textSize)-font.Width(label+" ", textSize), l.y, textSize, label)
+	l.page.TextRight(right, l.y, textSize, value)
+}
+
+// signatures - строки для подписей
+func (l *layout) signatures(labels ...string) {
+	l.gap(lineHeight)
+	for _, label := range labels {
+		l.space(lineHeight * 2)
+		l.y += lineHeight * 2
+		l.page.Text(margin, l.y, textSize, label)
+		l.page.Line(margin+170, l.y+2, margin+320, l.y+2, 0.5)
+		l.page.Text(margin+330, l.y, textSize, "/")
+		l.page.Line(margin+340, l.y+2, margin+contentWidth, l.y+2, 0.5)
+	}
+}
+
+// money - сумма в формате «12 345,67»
+func money(v float64) string {
+	cents := int64(math.Round(math.Abs(v) * 100))
+	whole := fmt.Sprintf("%d", cents/100)
+
+	var groups []string
+	for len(whole) > 3 {
+		groups = append([]string{whole[len(whole)-3:]}, groups...)
+		whole = whole[:len(whole)-3]
+	}
+	groups = append([]string{whole}, groups...)
+
+	sign := ""
+	if v < 0 && cents != 0 {
+		sign = "-"
+	}
+	return fmt.Sprintf("%s%s,%02d", sign, strings.Join(groups, " "), cents%100)
+}
+
+// quantity - число без лишних нулей
+func quantity(v float64) string {
+	s := strings.TrimRight(strings.TrimRight(fmt.Sprintf("%.3f", v), "0"), ".")
+	return strings.Replace(s, ".", ",", 1)
+}
@@ -0,0 +1,38 @@
+package ds
+
+import "time"
+
+// Attachment - файл, приложенный к заявке (счёт, упаковочный лист, фото груза, накладная)
+type Attachment struct {
+	ID          int       `json:"id" gorm:"primaryKey"`
+	OrderID     int       `json:"order_id" gorm:"not null;index"`
+	UploaderID  int       `json:"uploader_id" gorm:"not null"`
+	Kind        string    `json:"kind" gorm:"type:varchar(32);not null"`
+	FileName    string    `json:"file_name" gorm:"type:varchar(255);not null"`
+	ContentType string    `json:"content_type" gorm:"type:varchar(100);not null"`
+	Size        int64     `json:"size" gorm:"not null"`
+	Checksum    string    `json:"checksum" gorm:"type:varchar(64)"`                // SHA-256 содержимого
+	StorageKey  string    `json:"-" gorm:"type:varchar(255);not null;uniqueIndex"` // ключ в хранилище файлов
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+
+	// Связи
+	Uploader *User `json:"uploader,omitempty" gorm:"foreignKey:UploaderID"`
+}
+
+// Виды вложений
+const (
+	AttachmentInvoice     = "invoice"      // счёт
+	AttachmentPackingList = "packing_list" // упаковочный лист
+	AttachmentPhoto       = "photo"        // фото груза
+	AttachmentWaybill     = "waybill"      // подписанная накладная
+	AttachmentOther       = "other"        // прочее
+)
+
+// ValidAttachmentKind - проверка вида вложения
+func ValidAttachmentKind(kind string) bool {
+	switch kind {
+	case AttachmentInvoice, AttachmentPackingList, AttachmentPhoto, AttachmentWaybill, AttachmentOther:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package ds
+
+import "time"
+
+// Cancellation - отмена заявки клиентом: причина, штраф и сумма к возврату
+type Cancellation struct {
+	ID           int        `json:"id" gorm:"primaryKey"`
+	OrderID      int        `json:"order_id" gorm:"not null;uniqueIndex"`
+	ActorID      *int       `json:"actor_id"`
+	Reason       string     `json:"reason" gorm:"type:text"`
+	FromStatus   string     `json:"from_status" gorm:"type:varchar(32);not null"` // статус заявки на момент отмены
+	FeePercent   float64    `json:"fee_percent" gorm:"not null;default:0"`
+	Fee          float64    `json:"fee" gorm:"not null;default:0"`
+	PaidAmount   float64    `json:"paid_amount" gorm:"not null;default:0"` // оплачено (в том числе заблокировано) на момент отмены
+	RefundAmount float64    `json:"refund_amount" gorm:"not null;default:0"`
+	RefundStatus string     `json:"refund_status" gorm:"type:varchar(16);not null"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	RefundedAt   *time.Time `json:"refunded_at"`
+}
+
+// Статусы возврата по отмене
+const (
+	RefundNone     = "none"     // возвращать нечего
+	RefundPending  = "pending"  // ожидает возврата платёжным модулем или бухгалтерией
+	RefundComplete = "refunded" // возвращено
+)
@@ -0,0 +1,22 @@
+package ds
+
+// CustomsItem - товарная позиция груза для таможенного оформления
+type CustomsItem struct {
+	ID            int     `json:"id" gorm:"primaryKey"`
+	OrderID       int     `json:"order_id" gorm:"not null;index"`
+	Description   string  `json:"description" gorm:"type:text;not null"`
+	HSCode        string  `json:"hs_code" gorm:"type:varchar(10);not null"` // код ТН ВЭД / HS
+	Quantity      int     `json:"quantity" gorm:"not null;default:1"`
+	NetWeight     float64 `json:"net_weight" gorm:"not null;default:0"` // кг
+	Value         float64 `json:"value" gorm:"not null;default:0"`      // таможенная стоимость позиции
+	Currency      string  `json:"currency" gorm:"type:varchar(3);not null;default:'RUB'"`
+	OriginCountry string  `json:"origin_country" gorm:"type:varchar(2)"` // страна происхождения товара
+}
+
+func (CustomsItem) TableName() string { return "order_customs_items" }
+
+// DefaultCountry - страна по умолчанию для внутренних перевозок
+const DefaultCountry = "RU"
+
+// Incoterms - допустимые базисы поставки (Incoterms 2020)
+var Incoterms = []string{"EXW", "FCA", "CPT", "CIP", "DAP", "DPU", "DDP", "FAS", "FOB", "CFR", "CIF"}
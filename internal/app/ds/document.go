@@ -0,0 +1,15 @@
+package ds
+
+import "time"
+
+// OrderDocument - сформированный PDF-документ заявки (счёт, накладная).
+// Хранится, чтобы при повторном скачивании документ не менялся
+type OrderDocument struct {
+	ID         int       `json:"id" gorm:"primaryKey"`
+	OrderID    int       `json:"order_id" gorm:"not null;uniqueIndex:idx_order_documents_kind"`
+	Kind       string    `json:"kind" gorm:"type:varchar(32);not null;uniqueIndex:idx_order_documents_kind"`
+	Size       int64     `json:"size" gorm:"not null"`
+	Checksum   string    `json:"checksum" gorm:"type:varchar(64)"` // SHA-256 содержимого
+	StorageKey string    `json:"-" gorm:"type:varchar(255);not null"`
+	IssuedAt   time.Time `json:"issued_at" gorm:"not null"`
+}
@@ -0,0 +1,29 @@
+package ds
+
+import "time"
+
+// OrderMessage - сообщение в обсуждении заявки
+type OrderMessage struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	OrderID   int       `json:"order_id" gorm:"not null;index"`
+	AuthorID  int       `json:"author_id" gorm:"not null"`
+	ParentID  *int      `json:"parent_id" gorm:"index"` // ответ на сообщение
+	Body      string    `json:"body" gorm:"type:text;not null"`
+	Internal  bool      `json:"internal" gorm:"not null;default:false"` // заметка только для менеджеров
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+
+	// Связи
+	Author *User         `json:"author,omitempty" gorm:"foreignKey:AuthorID"`
+	Reads  []MessageRead `json:"reads,omitempty" gorm:"foreignKey:MessageID"`
+}
+
+// MessageRead - отметка о прочтении сообщения пользователем
+type MessageRead struct {
+	MessageID int       `json:"message_id" gorm:"primaryKey"`
+	UserID    int       `json:"user_id" gorm:"primaryKey;index"`
+	ReadAt    time.Time `json:"read_at" gorm:"not null"`
+}
+
+func (MessageRead) TableName() string {
+	return "order_message_reads"
+}
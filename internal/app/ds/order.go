@@ -1,6 +1,10 @@
 package ds
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Order - модель заявки
 type Order struct {
@@ -14,23 +18,63 @@ type Order struct {
     Length    float64        `json:"length" gorm:"not null;default:0"`
     Width     float64        `json:"width" gorm:"not null;default:0"`
     Height    float64        `json:"height" gorm:"not null;default:0"`
+    // Международная перевозка
+    OriginCountry      string        `json:"origin_country" gorm:"type:varchar(2);not null;default:'RU'"`
+    DestinationCountry string        `json:"destination_country" gorm:"type:varchar(2);not null;default:'RU'"`
+    Incoterms          string        `json:"incoterms" gorm:"type:varchar(3)"`
+    CustomsDays        int           `json:"customs_days" gorm:"not null;default:0"`
+    CustomsCost        float64       `json:"customs_cost" gorm:"not null;default:0"`
+    CustomsItems       []CustomsItem `json:"customs_items" gorm:"foreignKey:OrderID"`
+    // Маршрут с несколькими остановками (пусто - перевозка FromCity → ToCity)
+    Stops     []OrderStop    `json:"stops,omitempty" gorm:"foreignKey:OrderID"`
     Services  []OrderService `json:"services" gorm:"foreignKey:OrderID"`
-    TotalCost float64        `json:"total_cost"`
+    TotalCost float64        `json:"total_cost" gorm:"index"`
     TotalDays int            `json:"total_days"`
-    Status    string         `json:"status" gorm:"type:varchar(32);not null;default:'draft'"`
+    Status    string         `json:"status" gorm:"type:varchar(32);not null;default:'draft';index"`
+    TrackingNumber *string   `json:"tracking_number" gorm:"type:varchar(16);uniqueIndex"`
+    PaymentStatus  string     `json:"payment_status" gorm:"type:varchar(16);not null;default:'unpaid';index"`
+    PaidAt         *time.Time `json:"paid_at"`
     
     // Системные поля
-    CreatorID   int        `json:"creator_id" gorm:"not null"`
-    ModeratorID *int       `json:"moderator_id"`
-    CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
-    FormedAt    *time.Time `json:"formed_at"`
+    CreatorID   int        `json:"creator_id" gorm:"not null;index"`
+    ModeratorID *int       `json:"moderator_id" gorm:"index"`
+    // Очередь модерации: ответственный менеджер и срок захвата (nil - назначение без срока)
+    AssigneeID     *int       `json:"assignee_id" gorm:"index"`
+    ClaimedAt      *time.Time `json:"claimed_at"`
+    ClaimExpiresAt *time.Time `json:"claim_expires_at"`
+    // SLA: срок ответа после формирования
+    SLAPolicyID *int       `json:"sla_policy_id"`
+    SLAWarnAt   *time.Time `json:"sla_warn_at"`
+    SLADueAt    *time.Time `json:"sla_due_at" gorm:"index"`
+    SLAStatus   string     `json:"sla_status" gorm:"type:varchar(16);index"`
+    Priority    bool       `json:"priority" gorm:"not null;default:false"`
+    // Окна погрузки и доставки, запрошенные клиентом, и выполнимость срока доставки
+    PickupFrom     *time.Time `json:"pickup_from"`
+    PickupTo       *time.Time `json:"pickup_to"`
+    DeliveryFrom   *time.Time `json:"delivery_from"`
+    DeliveryTo     *time.Time `json:"delivery_to"`
+    DeadlineStatus string     `json:"deadline_status" gorm:"type:varchar(16);index"`
+    ServiceLevel   string     `json:"service_level" gorm:"type:varchar(16);not null;default:'standard'"`
+    // Заявка, созданная по расписанию регулярной отправки
+    ScheduleID   *int       `json:"schedule_id" gorm:"uniqueIndex:idx_orders_schedule_date"`
+    ScheduledFor *time.Time `json:"scheduled_for" gorm:"uniqueIndex:idx_orders_schedule_date"`
+    ClonedFromID *int       `json:"cloned_from_id"` // заявка-источник копии
+    // Возврат груза по завершённой заявке: исходная заявка, причина и специальный тариф
+    ReturnOfID     *int    `json:"return_of_id" gorm:"index"`
+    ReturnReason   string  `json:"return_reason,omitempty" gorm:"type:text"`
+    ReturnTariff   string  `json:"return_tariff,omitempty" gorm:"type:varchar(32)"`
+    ReturnDiscount float64 `json:"return_discount" gorm:"not null;default:0"` // скидка тарифа возврата, %
+    CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime;index"`
+    FormedAt    *time.Time `json:"formed_at" gorm:"index"`
     CompletedAt *time.Time `json:"completed_at"`
     UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
-    DeletedAt   *time.Time `json:"-" gorm:"index"`
+    Version     int        `json:"version" gorm:"not null;default:1"` // для оптимистичной блокировки
+    DeletedAt   gorm.DeletedAt `json:"-" gorm:"index"` // заявка в корзине
     
     // Связи
     Creator   User `json:"creator" gorm:"foreignKey:CreatorID"`
     Moderator *User `json:"moderator" gorm:"foreignKey:ModeratorID"`
+    Assignee  *User `json:"assignee,omitempty" gorm:"foreignKey:AssigneeID"`
 }
 
 // OrderStatus - статусы заявки
@@ -40,6 +84,35 @@ const (
     StatusCompleted = "completed" // завершён
     StatusRejected  = "rejected"  // отклонён
     StatusDeleted   = "deleted"   // удалён
+    StatusShipped   = "shipped"   // в пути
+    StatusDelivered = "delivered" // доставлен
+    StatusCancelled = "cancelled" // отменён
+)
+
+// Уровни сервиса: меняют скорость и стоимость перевозки
+const (
+    LevelEconomy  = "economy"  // эконом
+    LevelStandard = "standard" // стандарт
+    LevelExpress  = "express"  // экспресс, с приоритетом в очереди модерации
+)
+
+// ServiceLevels - допустимые уровни сервиса
+var ServiceLevels = []string{LevelEconomy, LevelStandard, LevelExpress}
+
+// ValidServiceLevel - известен ли уровень сервиса
+func ValidServiceLevel(level string) bool {
+    for _, l := range ServiceLevels {
+        if l == level {
+            return true
+        }
+    }
+    return false
+}
+
+// Выполнимость запрошенного срока доставки
+const (
+    DeadlineFeasible   = "feasible"   // груз успевает к окну доставки
+    DeadlineInfeasible = "infeasible" // срок невыполним выбранными услугами
 )
 
 // OrderService - услуга в заявке (м-м)
@@ -50,7 +123,64 @@ type OrderService struct {
 	Quantity  int     `json:"quantity" gorm:"not null;default:1"`
 	Comment   string  `json:"comment" gorm:"type:text"`
 	Order     int     `json:"order" gorm:"not null;default:0"` // порядок в заявке
+	// Маршрут и груз строки; пустые значения берутся из заявки (строки, добавленные из корзины)
+	FromCity  string  `json:"from_city"`
+	ToCity    string  `json:"to_city"`
+	Weight    float64 `json:"weight" gorm:"not null;default:0"`
+	Length    float64 `json:"length" gorm:"not null;default:0"`
+	Width     float64 `json:"width" gorm:"not null;default:0"`
+	Height    float64 `json:"height" gorm:"not null;default:0"`
+	// Рассчитанные стоимость и срок строки
+	Cost      float64 `json:"cost" gorm:"not null;default:0"`
+	Days      int     `json:"days" gorm:"not null;default:0"`
+	// Решение модератора по строке
+	Decision          string     `json:"decision" gorm:"type:varchar(16);not null;default:'pending'"`
+	DecisionReason    string     `json:"decision_reason" gorm:"type:text"`
+	OriginalServiceID *int       `json:"original_service_id"` // заявленная услуга, если строка заменена
+	DecidedByID       *int       `json:"decided_by_id"`
+	DecidedAt         *time.Time `json:"decided_at"`
+	ReturnOfLineID    *int       `json:"return_of_line_id" gorm:"index"` // строка исходной заявки, груз которой возвращается
 	
 	// Связи
 	Service Service `json:"service" gorm:"foreignKey:ServiceID"`
 }
+
+// Решения модератора по строке заявки
+const (
+    LinePending     = "pending"     // решение не принято
+    LineApproved    = "approved"    // одобрена
+    LineRejected    = "rejected"    // отклонена с указанием причины
+    LineSubstituted = "substituted" // заменена другой услугой
+)
+
+// Accepted - входит ли строка в перевозку: не отклонена модератором
+func (s OrderService) Accepted() bool {
+    return s.Decision != LineRejected
+}
+
+// ClaimedByOther - закреплена ли заявка за другим пользователем на момент now
+func (o Order) ClaimedByOther(userID int, now time.Time) bool {
+    if o.AssigneeID == nil || *o.AssigneeID == userID {
+        return false
+    }
+    return o.ClaimExpiresAt == nil || o.ClaimExpiresAt.After(now)
+}
+
+// IsInternational - пересекает ли перевозка государственную границу
+func (o Order) IsInternational() bool {
+    return o.OriginCountry != "" && o.DestinationCountry != "" && o.OriginCountry != o.DestinationCountry
+}
+
+// WithOrderDefaults - строка с маршрутом и грузом заявки вместо незаданных
+func (s OrderService) WithOrderDefaults(o Order) OrderService {
+    if s.FromCity == "" {
+        s.FromCity = o.FromCity
+    }
+    if s.ToCity == "" {
+        s.ToCity = o.ToCity
+    }
+    if s.Weight <= 0 || s.Length <= 0 || s.Width <= 0 || s.Height <= 0 {
+        s.Weight, s.Length, s.Width, s.Height = o.Weight, o.Length, o.Width, o.Height
+    }
+    return s
+}
@@ -0,0 +1,35 @@
+package ds
+
+import "time"
+
+// OrderEvent - запись журнала изменений заявки
+type OrderEvent struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	OrderID   int       `json:"order_id" gorm:"not null;index"`
+	ActorID   *int      `json:"actor_id"` // nil - системное изменение
+	Type      string    `json:"type" gorm:"type:varchar(32);not null"`
+	Field     string    `json:"field" gorm:"type:varchar(64)"`
+	OldValue  string    `json:"old_value" gorm:"type:text"`
+	NewValue  string    `json:"new_value" gorm:"type:text"`
+	Reason    string    `json:"reason" gorm:"type:text"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime;index"`
+
+	// Связи
+	Actor *User `json:"actor,omitempty" gorm:"foreignKey:ActorID"`
+}
+
+// OrderEventType - типы событий журнала
+const (
+	EventStatusChange   = "status_change"   // смена статуса
+	EventFieldChange    = "field_change"    // изменение поля заявки
+	EventServiceAdded   = "service_added"   // услуга добавлена
+	EventServiceRemoved = "service_removed" // услуга удалена
+	EventServiceUpdated = "service_updated" // изменена строка услуги
+	EventAssignment     = "assignment"      // назначение ответственного менеджера
+	EventSLA            = "sla"             // изменение состояния SLA
+	EventCloned         = "cloned"          // заявка создана копированием
+	EventPayment        = "payment"         // изменение статуса оплаты
+	EventRestored       = "restored"        // заявка восстановлена из корзины
+	EventLineDecision   = "line_decision"   // решение модератора по строке
+	EventReturnCreated  = "return_created"  // создан возврат по заявке
+)
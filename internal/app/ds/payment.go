@@ -0,0 +1,40 @@
+package ds
+
+import "time"
+
+// Payment - платёж по заявке у платёжного провайдера
+type Payment struct {
+	ID              int       `json:"id" gorm:"primaryKey"`
+	OrderID         int       `json:"order_id" gorm:"not null;index"`
+	Provider        string    `json:"provider" gorm:"type:varchar(32);not null;uniqueIndex:idx_payments_external"`
+	ExternalID      string    `json:"external_id" gorm:"type:varchar(64);not null;uniqueIndex:idx_payments_external"` // ID платежа у провайдера
+	Amount          float64   `json:"amount" gorm:"not null"`
+	RefundedAmount  float64   `json:"refunded_amount" gorm:"not null;default:0"`
+	Currency        string    `json:"currency" gorm:"type:varchar(3);not null;default:'RUB'"`
+	Status          string    `json:"status" gorm:"type:varchar(16);not null"`
+	ConfirmationURL string    `json:"confirmation_url,omitempty" gorm:"type:varchar(500)"` // страница оплаты у провайдера
+	CreatedAt       time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// Статусы оплаты. У заявки бывают unpaid, authorized, paid и refunded,
+// у платежа дополнительно pending, cancelled и failed
+const (
+	PaymentUnpaid     = "unpaid"     // не оплачена
+	PaymentPending    = "pending"    // ожидает подтверждения покупателем
+	PaymentAuthorized = "authorized" // сумма заблокирована на счёте покупателя
+	PaymentPaid       = "paid"       // списана
+	PaymentRefunded   = "refunded"   // возвращена полностью
+	PaymentCancelled  = "cancelled"  // авторизация отменена
+	PaymentFailed     = "failed"     // отклонён
+)
+
+// OrderPaymentStatus - статус оплаты заявки по состоянию платежа
+func OrderPaymentStatus(p Payment) string {
+	switch p.Status {
+	case PaymentAuthorized, PaymentPaid, PaymentRefunded:
+		return p.Status
+	default:
+		return PaymentUnpaid
+	}
+}
@@ -0,0 +1,49 @@
+package ds
+
+import "time"
+
+// Schedule - регулярная отправка: шаблон заявки и правило повторения
+type Schedule struct {
+	ID        int    `json:"id" gorm:"primaryKey"`
+	CreatorID int    `json:"creator_id" gorm:"not null;index"`
+	Name      string `json:"name" gorm:"not null"`
+
+	// Шаблон заявки
+	FromCity           string         `json:"from_city" gorm:"not null"`
+	ToCity             string         `json:"to_city" gorm:"not null"`
+	OriginCountry      string         `json:"origin_country" gorm:"type:varchar(2);not null;default:'RU'"`
+	DestinationCountry string         `json:"destination_country" gorm:"type:varchar(2);not null;default:'RU'"`
+	Incoterms          string         `json:"incoterms" gorm:"type:varchar(3)"`
+	Items              []ScheduleItem `json:"items" gorm:"serializer:json;type:jsonb;not null"`
+	CustomsItems       []CustomsItem  `json:"customs_items" gorm:"serializer:json;type:jsonb"`
+
+	// Правило повторения
+	RuleKind  string `json:"rule_kind" gorm:"type:varchar(16);not null"` // weekly, monthly, cron
+	Weekdays  string `json:"weekdays" gorm:"type:varchar(32)"`           // для weekly: mon,thu
+	MonthDay  int    `json:"month_day"`                                  // для monthly
+	TimeOfDay string `json:"time_of_day" gorm:"type:varchar(5)"`         // для weekly/monthly: 09:00
+	Cron      string `json:"cron" gorm:"type:varchar(64)"`               // для cron
+	Timezone  string `json:"timezone" gorm:"type:varchar(64)"`
+
+	LeadDays     int        `json:"lead_days" gorm:"not null;default:3"`        // за сколько дней создавать заявку
+	AutoForm     bool       `json:"auto_form" gorm:"not null;default:false"`    // сразу формировать заявку
+	SkipHolidays bool       `json:"skip_holidays" gorm:"not null;default:true"` // пропускать праздничные даты
+	Paused       bool       `json:"paused" gorm:"not null;default:false"`
+	StartsAt     *time.Time `json:"starts_at"`
+	EndsAt       *time.Time `json:"ends_at"`
+	NextRunAt    *time.Time `json:"next_run_at" gorm:"index"` // ближайшая дата отправки без заявки
+	LastOrderID  *int       `json:"last_order_id"`
+	LastError    string     `json:"last_error,omitempty" gorm:"type:text"` // почему расписание приостановлено планировщиком
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// ScheduleItem - груз и услуга в шаблоне регулярной отправки
+type ScheduleItem struct {
+	ServiceID int     `json:"service_id"`
+	Weight    float64 `json:"weight"`
+	Length    float64 `json:"length"`
+	Width     float64 `json:"width"`
+	Height    float64 `json:"height"`
+}
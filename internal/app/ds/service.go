@@ -1,6 +1,10 @@
 package ds
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 // Service - модель услуги (тип транспорта)
 type Service struct {
@@ -14,7 +18,7 @@ type Service struct {
 	MaxVolume    float64 `json:"max_volume" gorm:"not null"`
 	
 	// Системные поля
-	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
-	UpdatedAt time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
-	DeletedAt *time.Time `json:"-" gorm:"index"`
+	CreatedAt time.Time      `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time      `json:"updated_at" gorm:"autoUpdateTime"`
+	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"` // услуга в корзине
 }
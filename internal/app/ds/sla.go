@@ -0,0 +1,59 @@
+package ds
+
+import "time"
+
+// SLAPolicy - норматив времени реакции на сформированную заявку.
+// Политика клиента важнее политики услуги, политика без клиента и услуги - по умолчанию
+type SLAPolicy struct {
+	ID            int     `json:"id" gorm:"primaryKey"`
+	Name          string  `json:"name" gorm:"not null"`
+	ServiceID     *int    `json:"service_id" gorm:"index"`
+	CustomerID    *int    `json:"customer_id" gorm:"index"`
+	ResponseHours float64 `json:"response_hours" gorm:"not null"`          // рабочих часов на ответ
+	WarnPercent   int     `json:"warn_percent" gorm:"not null;default:80"` // предупреждение после этой доли срока
+	Escalation    string  `json:"escalation" gorm:"type:varchar(128)"`     // действия при нарушении через запятую
+	Active        bool    `json:"active" gorm:"not null;default:true"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+func (SLAPolicy) TableName() string {
+	return "sla_policies"
+}
+
+// Действия эскалации
+const (
+	EscalateReassign    = "reassign"     // передать заявку другому менеджеру
+	EscalateNotifyAdmin = "notify_admin" // уведомить администраторов
+	EscalatePriority    = "priority"     // пометить заявку как приоритетную
+)
+
+// EscalationActions - допустимые действия эскалации
+var EscalationActions = []string{EscalateReassign, EscalateNotifyAdmin, EscalatePriority}
+
+// Состояния SLA заявки
+const (
+	SLAPending  = "pending"  // срок идёт
+	SLAWarning  = "warning"  // срок подходит к концу
+	SLABreached = "breached" // срок нарушен, заявка не обработана
+	SLAMet      = "met"      // обработана в срок
+	SLAMissed   = "missed"   // обработана с нарушением срока
+)
+
+// Notification - уведомление пользователя
+type Notification struct {
+	ID        int        `json:"id" gorm:"primaryKey"`
+	UserID    int        `json:"user_id" gorm:"not null;index"`
+	OrderID   *int       `json:"order_id" gorm:"index"`
+	Type      string     `json:"type" gorm:"type:varchar(32);not null"`
+	Message   string     `json:"message" gorm:"type:text"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime;index"`
+}
+
+// Типы уведомлений
+const (
+	NotifySLAWarning = "sla_warning"
+	NotifySLABreach  = "sla_breach"
+)
@@ -0,0 +1,26 @@
+package ds
+
+import "time"
+
+// OrderStop - остановка маршрута заявки: погрузка или выгрузка части груза
+type OrderStop struct {
+	ID         int        `json:"id" gorm:"primaryKey"`
+	OrderID    int        `json:"order_id" gorm:"not null;index"`
+	Seq        int        `json:"seq" gorm:"not null;default:0"` // порядок остановки на маршруте
+	City       string     `json:"city" gorm:"not null"`
+	Address    string     `json:"address"`
+	Kind       string     `json:"kind" gorm:"type:varchar(16);not null"`
+	WindowFrom *time.Time `json:"window_from"` // окно прибытия на остановку
+	WindowTo   *time.Time `json:"window_to"`
+	Weight     float64    `json:"weight" gorm:"not null;default:0"` // кг, погружаемые или выгружаемые на остановке
+	Volume     float64    `json:"volume" gorm:"not null;default:0"` // м³
+	Note       string     `json:"note" gorm:"type:text"`
+}
+
+func (OrderStop) TableName() string { return "order_stops" }
+
+// Виды остановок
+const (
+	StopPickup  = "pickup"  // погрузка
+	StopDropoff = "dropoff" // выгрузка
+)
@@ -0,0 +1,32 @@
+package ds
+
+import "time"
+
+// TrackingEvent - этап доставки заявки
+type TrackingEvent struct {
+	ID          int       `json:"id" gorm:"primaryKey"`
+	OrderID     int       `json:"order_id" gorm:"not null;index"`
+	Milestone   string    `json:"milestone" gorm:"type:varchar(32);not null"`
+	Location    string    `json:"location" gorm:"type:varchar(255)"`
+	OccurredAt  time.Time `json:"occurred_at" gorm:"not null;index"`
+	Note        string    `json:"note" gorm:"type:text"`
+	CreatedByID int       `json:"created_by_id" gorm:"not null"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// TrackingMilestone - этапы доставки
+const (
+	MilestonePickedUp       = "picked_up"        // груз забран у отправителя
+	MilestoneInTransit      = "in_transit"       // в пути
+	MilestoneArrivedHub     = "arrived_hub"      // прибыл на терминал
+	MilestoneCustoms        = "customs"          // таможенное оформление
+	MilestoneOutForDelivery = "out_for_delivery" // передан на доставку
+	MilestoneDelivered      = "delivered"        // доставлен получателю
+	MilestoneException      = "exception"        // нештатная ситуация
+)
+
+// TrackingMilestones - все допустимые этапы
+var TrackingMilestones = []string{
+	MilestonePickedUp, MilestoneInTransit, MilestoneArrivedHub, MilestoneCustoms,
+	MilestoneOutForDelivery, MilestoneDelivered, MilestoneException,
+}
@@ -13,9 +13,12 @@ type User struct {
 	Password  string    `json:"-" gorm:"not null"` // не возвращаем в JSON
 	Name      string    `json:"name" gorm:"not null"`
 	Phone     string    `json:"phone"`
-	Role      string    `json:"role" gorm:"not null;default:'buyer'"` // buyer, manager, admin
+	Role      string    `json:"role" gorm:"not null;default:'buyer'"` // buyer, manager, admin, driver
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Клиенту одобрена отсрочка: отгрузка без предварительной оплаты
+	CreditApproved bool `json:"credit_approved" gorm:"not null;default:false"`
 }
 
 // UserRole - роли пользователей
@@ -23,4 +26,5 @@ const (
 	RoleBuyer   = "buyer"
 	RoleManager = "manager"
 	RoleAdmin   = "admin"
+	RoleDriver  = "driver" // водитель/перевозчик, отмечает этапы доставки
 )
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/repository"
+)
+
+// ctxCurrentUser - ключ контекста с загруженным пользователем
+const ctxCurrentUser = "current_user"
+
+// orderPolicy - правило доступа к заявке для каждой роли
+var orderPolicy = map[string]func(user ds.User, order ds.Order) bool{
+	// Покупатель работает только со своими заявками
+	ds.RoleBuyer: func(user ds.User, order ds.Order) bool {
+		return order.CreatorID == user.ID
+	},
+	// Менеджер и администратор - со всеми
+	ds.RoleManager: func(ds.User, ds.Order) bool { return true },
+	ds.RoleAdmin:   func(ds.User, ds.Order) bool { return true },
+	// Водитель работает только с принятыми к перевозке заявками и только через driverRoutes
+	ds.RoleDriver: func(_ ds.User, order ds.Order) bool {
+		return order.Status == ds.StatusCompleted || order.Status == ds.StatusShipped || order.Status == ds.StatusDelivered
+	},
+}
+
+// driverRoutes - маршруты заявки, нужные водителю: отметки отслеживания и смена статуса.
+// Карточка заявки с контактами клиента, обсуждение, вложения и документы водителю недоступны
+var driverRoutes = []string{"/tracking", "/status"}
+
+// driverRoute - доступен ли водителю маршрут с шаблоном path
+func driverRoute(path string) bool {
+	for _, suffix := range driverRoutes {
+		if strings.HasSuffix(path, "/:id"+suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// CanAccessOrder - может ли пользователь действовать над заявкой
+func CanAccessOrder(user ds.User, order ds.Order) bool {
+	rule, ok := orderPolicy[user.Role]
+	return ok && rule(user, order)
+}
+
+// scopeOrderFilter - ограничение списка заявок теми, что доступны пользователю
+func scopeOrderFilter(user ds.User, filter *repository.OrderFilter) {
+	switch user.Role {
+	case ds.RoleManager, ds.RoleAdmin:
+		return
+	case ds.RoleDriver:
+		allowed := []string{ds.StatusCompleted, ds.StatusShipped, ds.StatusDelivered}
+		if len(filter.Statuses) == 0 {
+			filter.Statuses = allowed
+			return
+		}
+		var statuses []string
+		for _, s := range filter.Statuses {
+			for _, a := range allowed {
+				if s == a {
+					statuses = append(statuses, s)
+				}
+			}
+		}
+		if len(statuses) == 0 {
+			// Ни один из запрошенных статусов водителю не доступен
+			statuses = []string{""}
+		}
+		filter.Statuses = statuses
+	default:
+		id := user.ID
+		filter.CreatorID = &id
+	}
+}
+
+// isModerator - менеджер или администратор
+func isModerator(role string) bool {
+	return role == ds.RoleManager || role == ds.RoleAdmin
+}
+
+// RequireOrderAccess - middleware проверки прав на заявку из параметра :id.
+// Должен идти после RequireAuth
+func (h *Handler) RequireOrderAccess() gin.HandlerFunc {
+	load := h.loadOrder
+	if load == nil {
+		load = h.Repository.GetOrder
+	}
+	return h.orderAccess(load)
+}
+
+// orderAccess - проверка прав на заявку, загружаемую через load
+func (h *Handler) orderAccess(load func(id int) (ds.Order, error)) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		id, err := strconv.Atoi(ctx.Param("id"))
+		if err != nil {
+			fail(ctx, http.StatusBadRequest, "invalid order id")
+			ctx.Abort()
+			return
+		}
+
+		user, ok := h.currentUser(ctx)
+		if !ok {
+			ctx.Abort()
+			return
+		}
+
+		order, err := load(id)
+		if err != nil {
+			fail(ctx, http.StatusNotFound, "order not found")
+			ctx.Abort()
+			return
+		}
+
+		if !CanAccessOrder(user, order) || (user.Role == ds.RoleDriver && !driverRoute(ctx.FullPath())) {
+			fail(ctx, http.StatusForbidden, "access to this order is forbidden")
+			ctx.Abort()
+			return
+		}
+
+		ctx.Next()
+	}
+}
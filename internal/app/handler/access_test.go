@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/middleware"
+	"rip-go-app/internal/app/repository"
+)
+
+// roleRoutes - маршруты заявки из RegisterOrderRoutes, закрытые ролью, и допустимые роли
+var roleRoutes = map[string][]string{
+	"PUT /complete":                {ds.RoleManager, ds.RoleAdmin},
+	"PUT /lines/:line_id/decision": {ds.RoleManager, ds.RoleAdmin},
+	"POST /finalize":               {ds.RoleManager, ds.RoleAdmin},
+	"POST /claim":                  {ds.RoleManager, ds.RoleAdmin},
+	"POST /release":                {ds.RoleManager, ds.RoleAdmin},
+	"POST /payments/capture":       {ds.RoleManager, ds.RoleAdmin},
+	"POST /payments/refund":        {ds.RoleManager, ds.RoleAdmin},
+	"PUT /assignee":                {ds.RoleAdmin},
+	"POST /clone":                  {ds.RoleBuyer, ds.RoleManager, ds.RoleAdmin},
+	"POST /return":                 {ds.RoleBuyer, ds.RoleManager, ds.RoleAdmin},
+	"POST /payments":               {ds.RoleBuyer, ds.RoleAdmin},
+	"POST /tracking":               {ds.RoleManager, ds.RoleAdmin, ds.RoleDriver},
+}
+
+// driverAllowed - маршруты заявки, открытые водителю
+var driverAllowed = map[string]bool{
+	"GET /tracking":  true,
+	"POST /tracking": true,
+	"PUT /status":    true,
+}
+
+// deniedMessages - ответы проверок доступа; остальные ответы означают, что запрос дошёл до обработчика
+var deniedMessages = map[string]bool{
+	"access to this order is forbidden": true,
+	"Insufficient permissions":          true,
+}
+
+// orderRouter - настоящие маршруты заявок с пользователем user и заявками orders вместо базы.
+// Обработчики без репозитория падают, recovery превращает это в 500
+func orderRouter(user ds.User, orders map[int]ds.Order) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	h := &Handler{
+		AuthMiddleware: &middleware.AuthMiddleware{},
+		loadOrder: func(id int) (ds.Order, error) {
+			order, ok := orders[id]
+			if !ok {
+				return ds.Order{}, errors.New("заявка не найдена")
+			}
+			return order, nil
+		},
+	}
+
+	r := gin.New()
+	r.Use(gin.CustomRecoveryWithWriter(io.Discard, func(ctx *gin.Context, _ any) {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+	}))
+	h.RegisterOrderRoutes(r.Group("/api/orders", func(ctx *gin.Context) {
+		ctx.Set("user_role", user.Role)
+		ctx.Set(ctxCurrentUser, user)
+	}))
+	return r
+}
+
+// orderRoute - маршрут конкретной заявки: ключ "METHOD /путь" после :id
+type orderRoute struct {
+	key, method, pattern string
+}
+
+func orderRoutes(r *gin.Engine) []orderRoute {
+	var routes []orderRoute
+	for _, info := range r.Routes() {
+		rest, ok := strings.CutPrefix(info.Path, "/api/orders/:id")
+		if !ok {
+			continue
+		}
+		routes = append(routes, orderRoute{key: info.Method + " " + rest, method: info.Method, pattern: rest})
+	}
+	return routes
+}
+
+var routeParam = regexp.MustCompile(`:[a-z_]+`)
+
+func TestRequireOrderAccess(t *testing.T) {
+	buyer := ds.User{ID: 1, Role: ds.RoleBuyer}
+	manager := ds.User{ID: 3, Role: ds.RoleManager}
+	admin := ds.User{ID: 4, Role: ds.RoleAdmin}
+	driver := ds.User{ID: 5, Role: ds.RoleDriver}
+	orders := map[int]ds.Order{
+		10: {ID: 10, CreatorID: 1, Status: ds.StatusDraft},
+		11: {ID: 11, CreatorID: 2, Status: ds.StatusFormed},
+		12: {ID: 12, CreatorID: 2, Status: ds.StatusCompleted},
+		13: {ID: 13, CreatorID: 2, Status: ds.StatusShipped},
+		14: {ID: 14, CreatorID: 2, Status: ds.StatusDelivered},
+		15: {ID: 15, CreatorID: 2, Status: ds.StatusDraft},
+	}
+
+	tests := []struct {
+		name   string
+		user   ds.User
+		order  string
+		access bool // проходит правило orderPolicy
+		status int  // ответ при отказе (403) или для несуществующей заявки
+	}{
+		{"buyer own order", buyer, "10", true, 0},
+		{"buyer someone else's order", buyer, "11", false, http.StatusForbidden},
+		{"buyer someone else's completed order", buyer, "12", false, http.StatusForbidden},
+		{"manager", manager, "11", true, 0},
+		{"admin", admin, "15", true, 0},
+		{"driver completed", driver, "12", true, 0},
+		{"driver shipped", driver, "13", true, 0},
+		{"driver delivered", driver, "14", true, 0},
+		{"driver draft", driver, "15", false, http.StatusForbidden},
+		{"driver formed", driver, "11", false, http.StatusForbidden},
+		{"unknown role", ds.User{ID: 6, Role: "guest"}, "10", false, http.StatusForbidden},
+		{"missing order", manager, "99", false, http.StatusNotFound},
+		{"invalid id", manager, "abc", false, http.StatusBadRequest},
+	}
+
+	routes := orderRoutes(orderRouter(buyer, orders))
+	registered := map[string]bool{}
+	for _, route := range routes {
+		registered[route.key] = true
+	}
+	for key := range roleRoutes {
+		if !registered[key] {
+			t.Errorf("roleRoutes: %s is not registered", key)
+		}
+	}
+	for key := range driverAllowed {
+		if !registered[key] {
+			t.Errorf("driverAllowed: %s is not registered", key)
+		}
+	}
+
+	for _, tt := range tests {
+		r := orderRouter(tt.user, orders)
+		for _, route := range routes {
+			allowed := tt.access && (tt.user.Role != ds.RoleDriver || driverAllowed[route.key])
+			if roles, ok := roleRoutes[route.key]; ok && allowed {
+				allowed = false
+				for _, role := range roles {
+					allowed = allowed || role == tt.user.Role
+				}
+			}
+
+			path := "/api/orders/" + tt.order + routeParam.ReplaceAllString(route.pattern, "1")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(route.method, path, nil))
+
+			var body struct{ Message string }
+			_ = json.Unmarshal(w.Body.Bytes(), &body)
+			denied := w.Code == http.StatusForbidden && deniedMessages[body.Message]
+			switch {
+			case tt.status != 0 && tt.status != http.StatusForbidden:
+				if w.Code != tt.status {
+					t.Errorf("%s: %s %s = %d, want %d", tt.name, route.method, path, w.Code, tt.status)
+				}
+			case allowed && denied:
+				t.Errorf("%s: %s %s denied (%s), want allowed", tt.name, route.method, path, body.Message)
+			case !allowed && !denied:
+				t.Errorf("%s: %s %s = %d, want 403", tt.name, route.method, path, w.Code)
+			}
+		}
+	}
+}
+
+func TestDriverRoute(t *testing.T) {
+	for path, want := range map[string]bool{
+		"/api/orders/:id/tracking":                       true,
+		"/api/logistic-requests/:id/status":              true,
+		"/api/order/:id/status":                          true,
+		"/api/orders/:id":                                false,
+		"/api/orders/:id/messages":                       false,
+		"/api/orders/:id/documents/:kind":                false,
+		"/api/orders/:id/attachments/:attachment_id/url": false,
+		"/order/:id":                                     false,
+	} {
+		if got := driverRoute(path); got != want {
+			t.Errorf("driverRoute(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestScopeOrderFilter(t *testing.T) {
+	tests := []struct {
+		name         string
+		user         ds.User
+		statuses     []string
+		wantStatuses []string
+		wantCreator  *int
+	}{
+		{"buyer sees own orders", ds.User{ID: 1, Role: ds.RoleBuyer}, nil, nil, intPtr(1)},
+		{"buyer keeps status filter", ds.User{ID: 1, Role: ds.RoleBuyer}, []string{ds.StatusFormed}, []string{ds.StatusFormed}, intPtr(1)},
+		{"manager sees all", ds.User{ID: 2, Role: ds.RoleManager}, nil, nil, nil},
+		{"admin sees all", ds.User{ID: 3, Role: ds.RoleAdmin}, []string{ds.StatusDraft}, []string{ds.StatusDraft}, nil},
+		{"driver default statuses", ds.User{ID: 4, Role: ds.RoleDriver}, nil,
+			[]string{ds.StatusCompleted, ds.StatusShipped, ds.StatusDelivered}, nil},
+		{"driver narrows statuses", ds.User{ID: 4, Role: ds.RoleDriver}, []string{ds.StatusFormed, ds.StatusShipped},
+			[]string{ds.StatusShipped}, nil},
+		{"driver forbidden statuses", ds.User{ID: 4, Role: ds.RoleDriver}, []string{ds.StatusDraft, ds.StatusFormed},
+			[]string{""}, nil},
+	}
+
+	for _, tt := range tests {
+		filter := repository.OrderFilter{Statuses: tt.statuses}
+		scopeOrderFilter(tt.user, &filter)
+		if !reflect.DeepEqual(filter.Statuses, tt.wantStatuses) {
+			t.Errorf("%s: statuses = %v, want %v", tt.name, filter.Statuses, tt.wantStatuses)
+		}
+		if !reflect.DeepEqual(filter.CreatorID, tt.wantCreator) {
+			t.Errorf("%s: creator = %v, want %v", tt.name, filter.CreatorID, tt.wantCreator)
+		}
+	}
+}
+
+func intPtr(v int) *int { return &v }
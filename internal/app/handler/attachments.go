@@ -0,0 +1,366 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/repository"
+	"rip-go-app/internal/app/storage"
+)
+
+// AttachmentPolicy - ограничения на вложения и срок действия ссылок на скачивание
+type AttachmentPolicy struct {
+	MaxSize      int64         // максимальный размер файла в байтах
+	AllowedTypes []string      // допустимые типы содержимого (определяются по содержимому файла)
+	URLTTL       time.Duration // срок действия ссылки на скачивание
+}
+
+// defaultAttachmentTypes - документы, фото и офисные файлы (xlsx/docx определяются как zip)
+var defaultAttachmentTypes = []string{
+	"application/pdf", "image/jpeg", "image/png", "image/gif", "image/webp", "text/plain", "application/zip",
+}
+
+// SetBlobStore - хранилище файлов для вложений; без него вложения недоступны
+func (h *Handler) SetBlobStore(store storage.Store, policy AttachmentPolicy) {
+	if policy.MaxSize <= 0 {
+		policy.MaxSize = 20 << 20
+	}
+	if len(policy.AllowedTypes) == 0 {
+		policy.AllowedTypes = defaultAttachmentTypes
+	}
+	if policy.URLTTL <= 0 {
+		policy.URLTTL = 5 * time.Minute
+	}
+	h.Blobs = store
+	h.Attachments = policy
+}
+
+// detectContentType - тип содержимого по первым байтам файла, если он допустим
+func (p AttachmentPolicy) detectContentType(head []byte) (string, bool) {
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	for _, allowed := range p.AllowedTypes {
+		if contentType == allowed {
+			return contentType, true
+		}
+	}
+	return contentType, false
+}
+
+// attachmentFileName - имя файла без пути и управляющих символов
+func attachmentFileName(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, name)
+	if runes := []rune(name); len(runes) > 255 {
+		name = string(runes[len(runes)-255:])
+	}
+	if name == "" || name == "." || name == "/" {
+		name = "file"
+	}
+	return name
+}
+
+// parseAttachmentID - ID вложения из параметра пути
+func parseAttachmentID(ctx *gin.Context) (orderID, attachmentID int, ok bool) {
+	orderID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return 0, 0, false
+	}
+	attachmentID, err = strconv.Atoi(ctx.Param("attachment_id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid attachment id")
+		return 0, 0, false
+	}
+	return orderID, attachmentID, true
+}
+
+// GetAttachments - вложения заявки
+// @Summary Get logistic request attachments
+// @Tags attachments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Attachments"
+// @Router /api/orders/{id}/attachments [get]
+func (h *Handler) GetAttachments(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	attachments, err := h.Repository.GetAttachments(id)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get attachments")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "attachments": attachments})
+}
+
+// UploadAttachment - загрузка файла к заявке
+// @Summary Upload attachment
+// @Description Attach a document to the logistic request (multipart/form-data). Content type is detected from file contents and must be in the allowed list
+// @Tags attachments
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param file formData file true "File"
+// @Param kind formData string false "Kind: invoice, packing_list, photo, waybill, other" default(other)
+// @Success 201 {object} map[string]interface{} "Attachment uploaded"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 413 {object} map[string]string "File too large"
+// @Failure 415 {object} map[string]string "Unsupported file type"
+// @Failure 503 {object} map[string]string "File storage is not configured"
+// @Router /api/orders/{id}/attachments [post]
+func (h *Handler) UploadAttachment(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+	if h.Blobs == nil {
+		fail(ctx, http.StatusServiceUnavailable, "file storage is not configured")
+		return
+	}
+	policy := h.Attachments
+	tooLarge := fmt.Sprintf("file is larger than %d MB", policy.MaxSize>>20)
+
+	// Запас на служебные части multipart
+	ctx.Request.Body = http.MaxBytesReader(ctx.Writer, ctx.Request.Body, policy.MaxSize+1<<20)
+	file, err := ctx.FormFile("file")
+	if err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			fail(ctx, http.StatusRequestEntityTooLarge, tooLarge)
+			return
+		}
+		fail(ctx, http.StatusBadRequest, "file is required")
+		return
+	}
+	if file.Size > policy.MaxSize {
+		fail(ctx, http.StatusRequestEntityTooLarge, tooLarge)
+		return
+	}
+	if file.Size == 0 {
+		fail(ctx, http.StatusBadRequest, "file is empty")
+		return
+	}
+
+	kind := ctx.DefaultPostForm("kind", ds.AttachmentOther)
+	if !ds.ValidAttachmentKind(kind) {
+		fail(ctx, http.StatusBadRequest, "invalid kind. allowed: invoice, packing_list, photo, waybill, other")
+		return
+	}
+
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "failed to read file")
+		return
+	}
+	defer f.Close()
+
+	head := make([]byte, 512)
+	n, err := io.ReadFull(f, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		fail(ctx, http.StatusBadRequest, "failed to read file")
+		return
+	}
+	contentType, allowed := policy.detectContentType(head[:n])
+	if !allowed {
+		fail(ctx, http.StatusUnsupportedMediaType, "unsupported file type: "+contentType)
+		return
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to read file")
+		return
+	}
+
+	key := fmt.Sprintf("orders/%d/%s", id, uuid.NewString())
+	hash := sha256.New()
+	if err := h.Blobs.Put(ctx.Request.Context(), key, io.TeeReader(f, hash), file.Size, contentType); err != nil {
+		logrus.Errorf("attachment upload failed: %v", err)
+		fail(ctx, http.StatusBadGateway, "failed to store file")
+		return
+	}
+
+	attachment := ds.Attachment{
+		OrderID:     id,
+		UploaderID:  user.ID,
+		Kind:        kind,
+		FileName:    attachmentFileName(file.Filename),
+		ContentType: contentType,
+		Size:        file.Size,
+		Checksum:    hex.EncodeToString(hash.Sum(nil)),
+		StorageKey:  key,
+	}
+	if err := h.Repository.CreateAttachment(&attachment); err != nil {
+		if delErr := h.Blobs.Delete(ctx.Request.Context(), key); delErr != nil {
+			logrus.Errorf("orphan attachment %s: %v", key, delErr)
+		}
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "ok", "attachment": attachment})
+}
+
+// GetAttachmentURL - подписанная ссылка на скачивание вложения
+// @Summary Get attachment download URL
+// @Description Signed download URL valid for a limited time. The link itself does not require authorization
+// @Tags attachments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param attachment_id path int true "Attachment ID"
+// @Success 200 {object} map[string]interface{} "Signed URL"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/orders/{id}/attachments/{attachment_id}/url [get]
+func (h *Handler) GetAttachmentURL(ctx *gin.Context) {
+	orderID, attachmentID, ok := parseAttachmentID(ctx)
+	if !ok {
+		return
+	}
+	if h.Blobs == nil {
+		fail(ctx, http.StatusServiceUnavailable, "file storage is not configured")
+		return
+	}
+
+	attachment, err := h.Repository.GetAttachment(orderID, attachmentID)
+	if err != nil {
+		fail(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+
+	expiresAt := time.Now().Add(h.Attachments.URLTTL)
+	url, err := h.Blobs.SignedURL(attachment.StorageKey, storage.URLOptions{
+		TTL:         h.Attachments.URLTTL,
+		FileName:    attachment.FileName,
+		ContentType: attachment.ContentType,
+	})
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to sign url")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "url": url, "expires_at": expiresAt})
+}
+
+// DeleteAttachment - удаление вложения (загрузившим или менеджером)
+// @Summary Delete attachment
+// @Tags attachments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param attachment_id path int true "Attachment ID"
+// @Success 200 {object} map[string]string "Attachment deleted"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/orders/{id}/attachments/{attachment_id} [delete]
+func (h *Handler) DeleteAttachment(ctx *gin.Context) {
+	orderID, attachmentID, ok := parseAttachmentID(ctx)
+	if !ok {
+		return
+	}
+
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	attachment, err := h.Repository.GetAttachment(orderID, attachmentID)
+	if err != nil {
+		fail(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	if attachment.UploaderID != user.ID && !isModerator(user.Role) {
+		fail(ctx, http.StatusForbidden, "only the uploader or a manager can delete the attachment")
+		return
+	}
+
+	if err := h.Repository.DeleteAttachment(orderID, attachmentID); err != nil {
+		failAttachment(ctx, err)
+		return
+	}
+	if h.Blobs != nil {
+		if err := h.Blobs.Delete(ctx.Request.Context(), attachment.StorageKey); err != nil {
+			logrus.Errorf("orphan attachment %s: %v", attachment.StorageKey, err)
+		}
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "message": "attachment deleted"})
+}
+
+// DownloadFile - скачивание файла по подписанной ссылке локального хранилища
+// @Summary Download file by signed URL
+// @Description Serves files of the local storage. Requires a valid signature instead of authorization
+// @Tags attachments
+// @Produce octet-stream
+// @Param key path string true "Storage key"
+// @Param expires query int true "Expiration (unix time)"
+// @Param sig query string true "Signature"
+// @Success 200 {file} file "File"
+// @Failure 403 {object} map[string]string "Invalid or expired link"
+// @Router /api/files/{key} [get]
+func (h *Handler) DownloadFile(ctx *gin.Context) {
+	verifier, ok := h.Blobs.(storage.Verifier)
+	if !ok {
+		fail(ctx, http.StatusNotFound, "not found")
+		return
+	}
+
+	key := strings.TrimPrefix(ctx.Param("key"), "/")
+	fileName, contentType := ctx.Query("name"), ctx.Query("type")
+	if err := verifier.Verify(key, ctx.Query("expires"), fileName, contentType, ctx.Query("sig")); err != nil {
+		fail(ctx, http.StatusForbidden, "invalid or expired link")
+		return
+	}
+
+	file, err := h.Blobs.Get(ctx.Request.Context(), key)
+	if err != nil {
+		failAttachment(ctx, err)
+		return
+	}
+	defer file.Close()
+
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	headers := map[string]string{}
+	if fileName != "" {
+		headers["Content-Disposition"] = mime.FormatMediaType("attachment", map[string]string{"filename": fileName})
+	}
+	ctx.DataFromReader(http.StatusOK, -1, contentType, file, headers)
+}
+
+// failAttachment - ответ с ошибкой вложения
+func failAttachment(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrAttachmentNotFound), errors.Is(err, storage.ErrNotFound):
+		fail(ctx, http.StatusNotFound, err.Error())
+	default:
+		fail(ctx, http.StatusInternalServerError, err.Error())
+	}
+}
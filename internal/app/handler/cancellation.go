@@ -0,0 +1,173 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/repository"
+	"rip-go-app/internal/app/service"
+	"rip-go-app/internal/app/workflow"
+)
+
+// CancelRequest - отмена заявки клиентом
+type CancelRequest struct {
+	Reason string `json:"reason"`
+}
+
+// cancellationRefundError - заявка отменена, но вернуть оплату не удалось
+type cancellationRefundError struct {
+	Cancellation ds.Cancellation
+	Err          error
+}
+
+func (e *cancellationRefundError) Error() string { return e.Err.Error() }
+func (e *cancellationRefundError) Unwrap() error { return e.Err }
+
+// cancelOrder - отмена заявки и возврат оплаты за вычетом штрафа.
+// Отмена не откатывается, если провайдер не смог вернуть деньги: возврат остаётся в ожидании,
+// повторяется фоновой задачей, а вызывающему возвращается *cancellationRefundError
+func (h *Handler) cancelOrder(ctx *gin.Context, orderID int, actor workflow.Actor, version int, reason string) (ds.Cancellation, error) {
+	cancellation, err := h.Repository.CancelOrder(orderID, actor, version, reason)
+	if err != nil {
+		return ds.Cancellation{}, err
+	}
+	if cancellation.RefundStatus != ds.RefundPending || h.Payments == nil {
+		return cancellation, nil
+	}
+
+	settled, err := h.Payments.SettleCancellation(ctx.Request.Context(), orderID, actor)
+	if err != nil {
+		logrus.Errorf("cancellation refund for order %d: %v", orderID, err)
+		return cancellation, &cancellationRefundError{Cancellation: cancellation, Err: err}
+	}
+	return settled, nil
+}
+
+// failCancellation - ошибка отмены; если заявка отменена, но возврат не прошёл,
+// в ответе есть сведения об отмене с ожидающим возвратом
+func (h *Handler) failCancellation(ctx *gin.Context, orderID int, err error) {
+	var refundErr *cancellationRefundError
+	if !errors.As(err, &refundErr) {
+		failOrder(ctx, err)
+		return
+	}
+
+	code := http.StatusInternalServerError
+	switch {
+	case errors.Is(err, service.ErrPaymentProvider):
+		code = http.StatusBadGateway
+	case errors.Is(err, repository.ErrPaymentState), errors.Is(err, repository.ErrPaymentNotFound):
+		code = http.StatusConflict
+	}
+	h.setOrderETag(ctx, orderID)
+	ctx.JSON(code, gin.H{
+		"status":       "fail",
+		"message":      "order cancelled, refund failed: " + err.Error(),
+		"cancellation": refundErr.Cancellation,
+	})
+}
+
+// CancelOrder - отмена заявки с удержанием штрафа по правилам отмены
+// @Summary Cancel logistic request
+// @Description Cancel a formed or accepted logistic request. Cancellation before moderation is free, after acceptance a configurable fee is withheld, after pickup cancellation is not possible. The paid amount minus the fee is refunded
+// @Tags logistic-requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param If-Match header string true "Order version from ETag"
+// @Param request body CancelRequest false "Cancellation reason"
+// @Success 200 {object} map[string]interface{} "Order cancelled"
+// @Failure 409 {object} map[string]string "Order cannot be cancelled in current status"
+// @Failure 412 {object} map[string]string "Order was modified by someone else"
+// @Failure 502 {object} map[string]interface{} "Order cancelled, refund failed and will be retried"
+// @Router /api/orders/{id}/cancel [post]
+func (h *Handler) CancelOrder(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	var req CancelRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			fail(ctx, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	cancellation, err := h.cancelOrder(ctx, id, actor, version, req.Reason)
+	if err != nil {
+		h.failCancellation(ctx, id, err)
+		return
+	}
+
+	h.setOrderETag(ctx, id)
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "cancellation": cancellation})
+}
+
+// GetCancellationQuote - штраф и сумма возврата, если отменить заявку сейчас
+// @Summary Get cancellation quote
+// @Tags logistic-requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Fee and refund amount"
+// @Failure 409 {object} map[string]string "Order cannot be cancelled in current status"
+// @Router /api/orders/{id}/cancellation/quote [get]
+func (h *Handler) GetCancellationQuote(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	quote, err := h.Repository.QuoteCancellation(id)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "quote": quote})
+}
+
+// GetCancellation - сведения об отмене заявки
+// @Summary Get logistic request cancellation
+// @Tags logistic-requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Cancellation"
+// @Failure 404 {object} map[string]string "Order was not cancelled"
+// @Router /api/orders/{id}/cancellation [get]
+func (h *Handler) GetCancellation(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	cancellation, err := h.Repository.GetCancellation(id)
+	if errors.Is(err, repository.ErrCancellationNotFound) {
+		fail(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get cancellation")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "cancellation": cancellation})
+}
@@ -0,0 +1,128 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/customs"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/repository"
+)
+
+// UpdateOrderCustoms - обновление данных международной перевозки
+// @Summary Update customs data of logistic request
+// @Description Set origin/destination countries, Incoterms and customs items (HS codes) of a draft logistic request
+// @Tags logistic-requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param If-Match header string true "Order version from ETag"
+// @Param request body map[string]interface{} true "Customs data"
+// @Success 200 {object} map[string]interface{} "Customs data updated"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 409 {object} map[string]string "Order is not a draft"
+// @Failure 412 {object} map[string]string "Order was modified by someone else"
+// @Failure 428 {object} map[string]string "If-Match header required"
+// @Router /api/logistic-requests/{id}/customs [put]
+func (h *Handler) UpdateOrderCustoms(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	var req struct {
+		OriginCountry      string           `json:"origin_country"`
+		DestinationCountry string           `json:"destination_country"`
+		Incoterms          string           `json:"incoterms"`
+		Items              []ds.CustomsItem `json:"items"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	err = h.Repository.UpdateOrderCustoms(id, repository.CustomsData{
+		OriginCountry:      req.OriginCountry,
+		DestinationCountry: req.DestinationCountry,
+		Incoterms:          req.Incoterms,
+		Items:              req.Items,
+	}, actor, version)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	order, err := h.Repository.GetOrder(id)
+	if err != nil {
+		fail(ctx, http.StatusNotFound, "order not found")
+		return
+	}
+
+	response := gin.H{"status": "ok", "order": order}
+	if order.Status == ds.StatusDraft {
+		response["price"] = h.orderPrice(id)
+	}
+
+	ctx.Header("ETag", orderETag(order.Version))
+	ctx.JSON(http.StatusOK, response)
+}
+
+// GetCustomsDeclaration - экспорт таможенной декларации для брокера
+// @Summary Export customs declaration
+// @Description Export customs declaration data block of logistic request as JSON or XML
+// @Tags logistic-requests
+// @Produce json,xml
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param format query string false "Export format (json, xml)"
+// @Success 200 {object} customs.Declaration "Customs declaration"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/logistic-requests/{id}/customs-declaration [get]
+func (h *Handler) GetCustomsDeclaration(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	order, err := h.Repository.GetOrder(id)
+	if err != nil {
+		fail(ctx, http.StatusNotFound, "order not found")
+		return
+	}
+
+	if !order.IsInternational() {
+		fail(ctx, http.StatusBadRequest, "order is domestic, customs declaration is not required")
+		return
+	}
+
+	decl := customs.BuildDeclaration(order)
+
+	switch ctx.DefaultQuery("format", "json") {
+	case "xml":
+		body, err := decl.ToXML()
+		if err != nil {
+			fail(ctx, http.StatusInternalServerError, "failed to export declaration")
+			return
+		}
+		ctx.Header("Content-Disposition", "attachment; filename=declaration-"+strconv.Itoa(order.ID)+".xml")
+		ctx.Data(http.StatusOK, "application/xml; charset=utf-8", body)
+	case "json":
+		ctx.JSON(http.StatusOK, gin.H{"status": "ok", "declaration": decl})
+	default:
+		fail(ctx, http.StatusBadRequest, "invalid format. allowed: json, xml")
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/repository"
+)
+
+// UpdateOrderWindows - окна погрузки и доставки черновика
+// @Summary Set pickup and delivery windows of logistic request
+// @Description Set requested pickup and delivery windows of a draft logistic request; the deadline is checked against transit time and the business calendar
+// @Tags logistic-requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param If-Match header string true "Order version from ETag"
+// @Param request body repository.DeliveryWindows true "Pickup and delivery windows"
+// @Success 200 {object} map[string]interface{} "Windows updated with feasibility"
+// @Failure 400 {object} map[string]string "Invalid windows"
+// @Failure 409 {object} map[string]string "Order is not a draft"
+// @Failure 412 {object} map[string]string "Order was modified by someone else"
+// @Failure 428 {object} map[string]string "If-Match header required"
+// @Router /api/logistic-requests/{id}/windows [put]
+func (h *Handler) UpdateOrderWindows(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	var req repository.DeliveryWindows
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	feasibility, err := h.Repository.SetOrderWindows(id, req, actor, version)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	order, err := h.Repository.GetOrder(id)
+	if err != nil {
+		fail(ctx, http.StatusNotFound, "order not found")
+		return
+	}
+
+	ctx.Header("ETag", orderETag(order.Version))
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "order": order, "feasibility": feasibility})
+}
+
+// GetOrderFeasibility - выполнимость окон погрузки и доставки заявки
+// @Summary Check deadline feasibility of logistic request
+// @Description Check requested pickup and delivery windows against transit time and the business calendar; suggests the fastest service that meets the deadline
+// @Tags logistic-requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} repository.Feasibility "Feasibility"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/logistic-requests/{id}/feasibility [get]
+func (h *Handler) GetOrderFeasibility(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	feasibility, err := h.Repository.GetOrderFeasibility(id)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, feasibility)
+}
@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"rip-go-app/internal/app/documents"
+	"rip-go-app/internal/app/ds"
+)
+
+// SetDocumentGenerator - генератор PDF-документов заявок
+func (h *Handler) SetDocumentGenerator(g *documents.Generator) {
+	h.Documents = g
+}
+
+// documentStatuses - статусы, в которых по заявке выдаются документы
+var documentStatuses = map[string]bool{
+	ds.StatusCompleted: true,
+	ds.StatusShipped:   true,
+	ds.StatusDelivered: true,
+}
+
+// GetOrderDocument - счёт или транспортная накладная заявки в PDF
+// @Summary Get logistic request document
+// @Description Printable invoice or consignment note (waybill) of a completed logistic request. The document is generated on first request and stored, so re-downloads return identical files
+// @Tags documents
+// @Produce application/pdf
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param kind path string true "Document kind: invoice, waybill"
+// @Success 200 {file} file "PDF document"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 409 {object} map[string]string "Logistic request is not completed"
+// @Failure 503 {object} map[string]string "Document generation is not configured"
+// @Router /api/orders/{id}/documents/{kind} [get]
+func (h *Handler) GetOrderDocument(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+	kind := ctx.Param("kind")
+	if !documents.ValidKind(kind) {
+		fail(ctx, http.StatusNotFound, "unknown document. allowed: invoice, waybill")
+		return
+	}
+	if h.Blobs == nil || h.Documents == nil {
+		fail(ctx, http.StatusServiceUnavailable, "document generation is not configured")
+		return
+	}
+
+	doc, found, err := h.Repository.GetOrderDocument(id, kind)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get document")
+		return
+	}
+
+	if !found {
+		order, err := h.Repository.GetOrder(id)
+		if err != nil {
+			fail(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		if !documentStatuses[order.Status] {
+			fail(ctx, http.StatusConflict, "documents are available for completed orders only")
+			return
+		}
+
+		if doc, err = h.issueOrderDocument(ctx, kind, order); err != nil {
+			logrus.Errorf("document %s for order %d: %v", kind, id, err)
+			fail(ctx, http.StatusInternalServerError, "failed to generate document")
+			return
+		}
+	}
+
+	file, err := h.Blobs.Get(ctx.Request.Context(), doc.StorageKey)
+	if err != nil {
+		failAttachment(ctx, err)
+		return
+	}
+	defer file.Close()
+
+	fileName := fmt.Sprintf("%s-%d.pdf", kind, id)
+	ctx.DataFromReader(http.StatusOK, doc.Size, "application/pdf", file, map[string]string{
+		"Content-Disposition": mime.FormatMediaType("attachment", map[string]string{"filename": fileName}),
+		"ETag":                `"` + doc.Checksum + `"`,
+	})
+}
+
+// issueOrderDocument - формирование документа, сохранение в хранилище и запись о нём
+func (h *Handler) issueOrderDocument(ctx *gin.Context, kind string, order ds.Order) (ds.OrderDocument, error) {
+	issued := time.Now()
+	data, err := h.Documents.Render(kind, order, issued)
+	if err != nil {
+		return ds.OrderDocument{}, err
+	}
+
+	sum := sha256.Sum256(data)
+	key := fmt.Sprintf("orders/%d/documents/%s-%s.pdf", order.ID, kind, hex.EncodeToString(sum[:8]))
+	if err := h.Blobs.Put(ctx.Request.Context(), key, bytes.NewReader(data), int64(len(data)), "application/pdf"); err != nil {
+		return ds.OrderDocument{}, err
+	}
+
+	doc, created, err := h.Repository.SaveOrderDocument(ds.OrderDocument{
+		OrderID:    order.ID,
+		Kind:       kind,
+		Size:       int64(len(data)),
+		Checksum:   hex.EncodeToString(sum[:]),
+		StorageKey: key,
+		IssuedAt:   issued,
+	})
+	if err == nil && !created && doc.StorageKey != key {
+		// Параллельный запрос успел сохранить свой экземпляр - наш больше не нужен
+		if delErr := h.Blobs.Delete(ctx.Request.Context(), key); delErr != nil {
+			logrus.Errorf("orphan document %s: %v", key, delErr)
+		}
+	}
+	return doc, err
+}
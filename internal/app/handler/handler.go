@@ -6,8 +6,13 @@ import (
     "rip-go-app/internal/app/ds"
     "rip-go-app/internal/app/repository"
     "rip-go-app/internal/app/calculator"
+    "rip-go-app/internal/app/customs"
+    "rip-go-app/internal/app/documents"
     "rip-go-app/internal/app/service"
+    "rip-go-app/internal/app/storage"
     "rip-go-app/internal/app/middleware"
+    "rip-go-app/internal/app/workflow"
+    "errors"
     "net/http"
     "strconv"
     "strings"
@@ -19,6 +24,17 @@ type Handler struct {
 	Repository   *repository.Repository
 	AuthService  *service.AuthService
 	AuthMiddleware *middleware.AuthMiddleware
+
+	// Хранилище файлов вложений (задаётся через SetBlobStore)
+	Blobs       storage.Store
+	Attachments AttachmentPolicy
+	// Генератор PDF-документов (задаётся через SetDocumentGenerator)
+	Documents *documents.Generator
+	// Оплата заявок (задаётся через SetPaymentService)
+	Payments *service.PaymentService
+
+	// Загрузка заявки для проверки прав (по умолчанию Repository.GetOrder)
+	loadOrder func(id int) (ds.Order, error)
 }
 
 func NewHandler(r *repository.Repository, authService *service.AuthService, authMiddleware *middleware.AuthMiddleware) *Handler {
@@ -101,6 +117,42 @@ func (h *Handler) GetOrderDetails(ctx *gin.Context) {
 	})
 }
 
+// GetOrderPage - страница заявки :id с обсуждением. Доступна после RequireAuth и RequireOrderAccess,
+// внутренние заметки видят только менеджеры и администраторы
+func (h *Handler) GetOrderPage(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	order, err := h.Repository.GetOrder(id)
+	if err != nil {
+		ctx.HTML(http.StatusNotFound, "error.html", gin.H{
+			"error": "Заявка не найдена",
+		})
+		return
+	}
+
+	messages, err := h.Repository.GetMessages(order.ID, isModerator(user.Role))
+	if err != nil {
+		logrus.Error(err)
+		messages = []ds.OrderMessage{}
+	}
+
+	ctx.HTML(http.StatusOK, "order.html", gin.H{
+		"order":        order,
+		"services":     order.Services,
+		"showMessages": true,
+		"messages":     messages,
+	})
+}
+
 // GetCalculator - страница калькулятора
 func (h *Handler) GetCalculator(ctx *gin.Context) {
 	// Получаем услуги из корзины
@@ -300,6 +352,7 @@ func (h *Handler) GetCart(ctx *gin.Context) {
 		"cart":     cart,
 		"services": services,
 		"count":    h.Repository.GetCartCount(),
+		"price":    h.orderPrice(cart.ID),
 	})
 }
 
@@ -319,6 +372,17 @@ func (h *Handler) CalculateService(ctx *gin.Context) {
 		Width     float64 `json:"width" form:"width"`
 		Height    float64 `json:"height" form:"height"`
 		Weight    float64 `json:"weight" form:"weight"`
+		// Для международной перевозки
+		OriginCountry      string  `json:"origin_country" form:"origin_country"`
+		DestinationCountry string  `json:"destination_country" form:"destination_country"`
+		DeclaredValue      float64 `json:"declared_value" form:"declared_value"`
+		// Уровень сервиса: economy, standard (по умолчанию), express
+		ServiceLevel string `json:"service_level" form:"service_level"`
+		// Окна погрузки и доставки для проверки срока (RFC 3339)
+		PickupFrom   *time.Time `json:"pickup_from" form:"pickup_from"`
+		PickupTo     *time.Time `json:"pickup_to" form:"pickup_to"`
+		DeliveryFrom *time.Time `json:"delivery_from" form:"delivery_from"`
+		DeliveryTo   *time.Time `json:"delivery_to" form:"delivery_to"`
 	}
 
 	// Пробуем сначала JSON, потом form data
@@ -338,20 +402,72 @@ func (h *Handler) CalculateService(ctx *gin.Context) {
 
     // Используем компонент калькулятора
     calc := calculator.NewDeliveryCalculator()
-    res := calc.CalculateDelivery(service, request.FromCity, request.ToCity, request.Length, request.Width, request.Height, request.Weight)
+    if request.ServiceLevel == "" {
+        request.ServiceLevel = ds.LevelStandard
+    }
+    res := calc.CalculateDeliveryLevel(service, request.ServiceLevel, request.FromCity, request.ToCity, request.Length, request.Width, request.Height, request.Weight)
 
     if !res.IsValid {
         fail(ctx, http.StatusBadRequest, res.ErrorMessage)
         return
     }
 
-    ctx.JSON(http.StatusOK, gin.H{
+    originCountry, err := customs.NormalizeCountry(request.OriginCountry)
+    if err != nil {
+        fail(ctx, http.StatusBadRequest, err.Error())
+        return
+    }
+    destinationCountry, err := customs.NormalizeCountry(request.DestinationCountry)
+    if err != nil {
+        fail(ctx, http.StatusBadRequest, err.Error())
+        return
+    }
+    calc.ApplyCustoms(&res, originCountry, destinationCountry, request.DeclaredValue)
+
+    response := gin.H{
         "status":        "ok",
         "delivery_days": res.DeliveryDays,
         "total_cost":    res.TotalCost,
         "distance":      res.Distance,
         "volume":        res.Volume,
-    })
+        "customs_days":  res.CustomsDays,
+        "customs_cost":  res.CustomsCost,
+        "crossings":     res.Crossings,
+        "service_level": request.ServiceLevel,
+    }
+
+    // Сроки и стоимость на других уровнях сервиса этого транспорта
+    var levels []gin.H
+    for _, level := range calc.GetServiceLevels(service.ID) {
+        alt := calc.CalculateDeliveryLevel(service, level.Name, request.FromCity, request.ToCity, request.Length, request.Width, request.Height, request.Weight)
+        calc.ApplyCustoms(&alt, originCountry, destinationCountry, request.DeclaredValue)
+        levels = append(levels, gin.H{
+            "service_level": level.Name,
+            "delivery_days": alt.DeliveryDays,
+            "total_cost":    alt.TotalCost,
+        })
+    }
+    response["levels"] = levels
+
+    // Проверка срока и подбор более быстрого транспорта для того же груза
+    windows := repository.DeliveryWindows{
+        PickupFrom:   request.PickupFrom,
+        PickupTo:     request.PickupTo,
+        DeliveryFrom: request.DeliveryFrom,
+        DeliveryTo:   request.DeliveryTo,
+    }
+    if !windows.Empty() {
+        response["feasibility"] = h.Repository.CheckFeasibility(windows, res.DeliveryDays, func(alt ds.Service) (float64, int, bool) {
+            altRes := calc.CalculateDeliveryLevel(alt, request.ServiceLevel, request.FromCity, request.ToCity, request.Length, request.Width, request.Height, request.Weight)
+            if !altRes.IsValid {
+                return 0, 0, false
+            }
+            calc.ApplyCustoms(&altRes, originCountry, destinationCountry, request.DeclaredValue)
+            return altRes.TotalCost, altRes.DeliveryDays, true
+        })
+    }
+
+    ctx.JSON(http.StatusOK, response)
 }
 
 // FormOrder - формирование заявки создателем (дата формирования)
@@ -388,12 +504,23 @@ func (h *Handler) FormOrder(ctx *gin.Context) {
 		return
 	}
 
-	err = h.Repository.FormOrder(id, request.FromCity, request.ToCity, request.Weight, request.Length, request.Width, request.Height)
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	err = h.Repository.FormOrder(id, actor, version, request.FromCity, request.ToCity, request.Weight, request.Length, request.Width, request.Height)
 	if err != nil {
-		fail(ctx, http.StatusBadRequest, err.Error())
+		failOrder(ctx, err)
 		return
 	}
 
+	h.setOrderETag(ctx, id)
 	ctx.JSON(http.StatusOK, gin.H{
 		"status":  "ok",
 		"message": "Заявка успешно сформирована",
@@ -402,7 +529,7 @@ func (h *Handler) FormOrder(ctx *gin.Context) {
 
 // SubmitOrder - отправка логистической заявки на грузоперевозку
 // @Summary Submit cargo logistic request
-// @Description Submit a new cargo transportation logistic request
+// @Description Submit a new cargo transportation logistic request. service_level (economy, standard, express) is the level chosen in the quote; every transport must offer it
 // @Tags logistic-requests
 // @Accept json
 // @Produce json
@@ -436,6 +563,11 @@ func (h *Handler) SubmitOrder(ctx *gin.Context) {
 			Height    float64 `json:"height"`
 			Weight    float64 `json:"weight"`
 		} `json:"services"`
+		OriginCountry      string           `json:"origin_country"`
+		DestinationCountry string           `json:"destination_country"`
+		Incoterms          string           `json:"incoterms"`
+		CustomsItems       []ds.CustomsItem `json:"customs_items"`
+		ServiceLevel       string           `json:"service_level"` // уровень сервиса из расчёта
 	}
 
     if err := ctx.ShouldBindJSON(&request); err != nil {
@@ -447,6 +579,10 @@ func (h *Handler) SubmitOrder(ctx *gin.Context) {
         fail(ctx, http.StatusBadRequest, "no transport types provided")
 		return
 	}
+    if request.ServiceLevel != "" && !ds.ValidServiceLevel(request.ServiceLevel) {
+        fail(ctx, http.StatusBadRequest, "unknown service level")
+        return
+    }
 
     // Маппим вход в элементы заказа и сохраняем транзакционно
     items := make([]repository.CargoOrderItem, 0, len(request.Services))
@@ -462,7 +598,14 @@ func (h *Handler) SubmitOrder(ctx *gin.Context) {
         })
     }
 
-    orderID, err := h.Repository.CreateCargoOrder(items, user.ID)
+    customsData := repository.CustomsData{
+        OriginCountry:      request.OriginCountry,
+        DestinationCountry: request.DestinationCountry,
+        Incoterms:          request.Incoterms,
+        Items:              request.CustomsItems,
+    }
+
+    orderID, err := h.Repository.CreateCargoOrder(items, customsData, request.ServiceLevel, user.ID)
     if err != nil {
         // Ошибки валидации калькулятора и пр. вернём как 400
         fail(ctx, http.StatusBadRequest, err.Error())
@@ -540,7 +683,22 @@ func (h *Handler) SearchTransport(ctx *gin.Context) {
 	}
 }
 
-// UpdateOrderStatus - обновление статуса заказа через курсор
+// UpdateOrderStatus - смена статуса заявки через машину состояний
+// @Summary Change logistic request status
+// @Description Move logistic request to another status. Allowed transitions and roles are defined by the order state machine
+// @Tags logistic-requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param If-Match header string true "Order version from ETag"
+// @Param request body map[string]string true "Target status"
+// @Success 200 {object} map[string]interface{} "Status changed"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 409 {object} map[string]string "Illegal transition"
+// @Failure 412 {object} map[string]string "Order was modified by someone else"
+// @Failure 428 {object} map[string]string "If-Match header required"
+// @Router /api/logistic-requests/{id}/status [put]
 func (h *Handler) UpdateOrderStatus(ctx *gin.Context) {
 	orderIDStr := ctx.Param("id")
     orderID, err := strconv.Atoi(orderIDStr)
@@ -552,6 +710,7 @@ func (h *Handler) UpdateOrderStatus(ctx *gin.Context) {
 	// Получаем новый статус из JSON
 	var request struct {
 		Status string `json:"status" binding:"required"`
+		Reason string `json:"reason"`
 	}
 
     if err := ctx.ShouldBindJSON(&request); err != nil {
@@ -559,29 +718,39 @@ func (h *Handler) UpdateOrderStatus(ctx *gin.Context) {
 		return
 	}
 
-	// Валидация статуса
-	validStatuses := []string{"pending", "processing", "shipped", "delivered", "cancelled"}
-	isValid := false
-	for _, status := range validStatuses {
-		if request.Status == status {
-			isValid = true
-			break
-		}
-	}
-
-    if !isValid {
-        fail(ctx, http.StatusBadRequest, "invalid status. allowed: pending, processing, shipped, delivered, cancelled")
+    if !workflow.IsStatus(request.Status) {
+        fail(ctx, http.StatusBadRequest, "invalid status. allowed: "+strings.Join(workflow.Statuses(), ", "))
 		return
 	}
 
-	// Обновляем статус через курсор
-    err = h.Repository.UpdateOrderStatusWithCursor(orderID, request.Status)
+    version, ok := ifMatchVersion(ctx)
+    if !ok {
+        return
+    }
+
+    actor, ok := h.currentActor(ctx)
+    if !ok {
+        return
+    }
+
+    // Завершение модератором требует пересчёта стоимости
+    if request.Status == ds.StatusCompleted || request.Status == ds.StatusRejected {
+        err = h.Repository.CompleteOrder(orderID, request.Status, actor, version, request.Reason)
+    } else if request.Status == ds.StatusCancelled {
+        // Отмена всегда проходит через правила штрафов и возврата
+        if _, err := h.cancelOrder(ctx, orderID, actor, version, request.Reason); err != nil {
+            h.failCancellation(ctx, orderID, err)
+            return
+        }
+    } else {
+        err = h.Repository.TransitionOrder(orderID, request.Status, actor, version, request.Reason, nil)
+    }
 	if err != nil {
-        logrus.Error(err)
-        fail(ctx, http.StatusInternalServerError, "failed to update order status")
+        failOrder(ctx, err)
 		return
 	}
 
+    h.setOrderETag(ctx, orderID)
 	ctx.JSON(http.StatusOK, gin.H{
         "status": "ok",
         "message": "Статус заказа успешно обновлен",
@@ -940,68 +1109,139 @@ func (h *Handler) RefreshToken(ctx *gin.Context) {
 
 // GetOrders - получение списка логистических заявок с фильтрацией
 // @Summary Get logistic requests list
-// @Description Get logistic requests list with filtering by status and date range
+// @Description Get logistic requests page with filtering, sorting and cursor pagination. Buyers see only their own requests
 // @Tags logistic-requests
 // @Accept json
 // @Produce json
 // @Security BearerAuth
-// @Param status query string false "Logistic request status filter"
-// @Param date_from query string false "Date from (YYYY-MM-DD)"
-// @Param date_to query string false "Date to (YYYY-MM-DD)"
+// @Param status query string false "Status filter, comma separated"
+// @Param date_from query string false "Formed from (YYYY-MM-DD)"
+// @Param date_to query string false "Formed to, inclusive (YYYY-MM-DD)"
+// @Param from_city query string false "Origin city"
+// @Param to_city query string false "Destination city"
+// @Param creator_id query int false "Creator ID"
+// @Param moderator_id query int false "Moderator ID"
+// @Param min_cost query number false "Minimum total cost"
+// @Param max_cost query number false "Maximum total cost"
+// @Param sort query string false "Sort field: created, formed, total_cost (prefix - for descending)" default(-created)
+// @Param cursor query string false "Cursor from previous page"
+// @Param limit query int false "Page size (max 100)" default(20)
 // @Success 200 {object} map[string]interface{} "Logistic requests retrieved successfully"
+// @Failure 400 {object} map[string]string "Invalid filter"
 // @Failure 401 {object} map[string]string "Unauthorized"
-// @Failure 403 {object} map[string]string "Forbidden"
 // @Router /api/logistic-requests [get]
 func (h *Handler) GetOrders(ctx *gin.Context) {
-    userUUID, exists := middleware.GetUserUUID(ctx)
-    if !exists {
-        fail(ctx, http.StatusUnauthorized, "authentication required")
+    filter, ok := parseOrderFilter(ctx)
+    if !ok {
         return
     }
 
-    userRole, _ := middleware.GetUserRole(ctx)
-    status := ctx.Query("status")
-    dateFromStr := ctx.Query("date_from")
-    dateToStr := ctx.Query("date_to")
-
-    var dateFrom, dateTo *time.Time
-    if dateFromStr != "" {
-        if t, err := time.Parse("2006-01-02", dateFromStr); err == nil {
-            dateFrom = &t
-        }
-    }
-    if dateToStr != "" {
-        if t, err := time.Parse("2006-01-02", dateToStr); err == nil {
-            dateTo = &t
-        }
+    user, ok := h.currentUser(ctx)
+    if !ok {
+        return
     }
+    // Покупатель видит только свои заявки, водитель - только принятые к перевозке
+    scopeOrderFilter(user, &filter)
 
-    orders, err := h.Repository.GetOrders(status, dateFrom, dateTo)
+    page, err := h.Repository.ListOrders(filter)
     if err != nil {
+        if errors.Is(err, repository.ErrInvalidCursor) {
+            fail(ctx, http.StatusBadRequest, "invalid cursor")
+            return
+        }
         fail(ctx, http.StatusInternalServerError, "failed to get orders")
         return
     }
 
-    // Фильтрация по ролям
-    if userRole == ds.RoleBuyer {
-        // Buyer видит только свои заявки
-        user, err := h.Repository.GetUserByUUID(userUUID)
+    ctx.JSON(http.StatusOK, gin.H{
+        "status":      "ok",
+        "orders":      page.Orders,
+        "next_cursor": page.NextCursor,
+        "total":       page.Total,
+    })
+}
+
+// parseOrderFilter - разбор параметров списка заявок из query
+func parseOrderFilter(ctx *gin.Context) (repository.OrderFilter, bool) {
+    filter := repository.OrderFilter{
+        FromCity: strings.TrimSpace(ctx.Query("from_city")),
+        ToCity:   strings.TrimSpace(ctx.Query("to_city")),
+        Cursor:   ctx.Query("cursor"),
+        Sort:     repository.SortCreated,
+        Desc:     true,
+    }
+
+    if status := ctx.Query("status"); status != "" {
+        for _, s := range strings.Split(status, ",") {
+            s = strings.TrimSpace(s)
+            if !workflow.IsStatus(s) || s == ds.StatusDraft {
+                fail(ctx, http.StatusBadRequest, "invalid status: "+s)
+                return filter, false
+            }
+            filter.Statuses = append(filter.Statuses, s)
+        }
+    }
+
+    if v := ctx.Query("date_from"); v != "" {
+        t, err := time.Parse("2006-01-02", v)
         if err != nil {
-            fail(ctx, http.StatusInternalServerError, "failed to get user")
-            return
+            fail(ctx, http.StatusBadRequest, "invalid date_from, expected YYYY-MM-DD")
+            return filter, false
         }
-        
-        var userOrders []ds.Order
-        for _, order := range orders {
-            if order.CreatorID == user.ID {
-                userOrders = append(userOrders, order)
+        filter.DateFrom = &t
+    }
+    if v := ctx.Query("date_to"); v != "" {
+        t, err := time.Parse("2006-01-02", v)
+        if err != nil {
+            fail(ctx, http.StatusBadRequest, "invalid date_to, expected YYYY-MM-DD")
+            return filter, false
+        }
+        // Включительно: до начала следующего дня
+        t = t.AddDate(0, 0, 1)
+        filter.DateTo = &t
+    }
+
+    for param, dst := range map[string]**int{"creator_id": &filter.CreatorID, "moderator_id": &filter.ModeratorID} {
+        if v := ctx.Query(param); v != "" {
+            id, err := strconv.Atoi(v)
+            if err != nil {
+                fail(ctx, http.StatusBadRequest, "invalid "+param)
+                return filter, false
+            }
+            *dst = &id
+        }
+    }
+
+    for param, dst := range map[string]**float64{"min_cost": &filter.MinCost, "max_cost": &filter.MaxCost} {
+        if v := ctx.Query(param); v != "" {
+            cost, err := strconv.ParseFloat(v, 64)
+            if err != nil {
+                fail(ctx, http.StatusBadRequest, "invalid "+param)
+                return filter, false
             }
+            *dst = &cost
+        }
+    }
+
+    if sort := ctx.Query("sort"); sort != "" {
+        filter.Desc = strings.HasPrefix(sort, "-")
+        filter.Sort = strings.TrimPrefix(sort, "-")
+        if filter.Sort != repository.SortCreated && filter.Sort != repository.SortFormed && filter.Sort != repository.SortTotalCost {
+            fail(ctx, http.StatusBadRequest, "invalid sort. allowed: created, formed, total_cost")
+            return filter, false
+        }
+    }
+
+    if v := ctx.Query("limit"); v != "" {
+        limit, err := strconv.Atoi(v)
+        if err != nil || limit <= 0 {
+            fail(ctx, http.StatusBadRequest, "invalid limit")
+            return filter, false
         }
-        orders = userOrders
+        filter.Limit = limit
     }
-    // Manager и Admin видят все заявки
 
-    ctx.JSON(http.StatusOK, gin.H{"status": "ok", "orders": orders})
+    return filter, true
 }
 
 // GetOrder - получение заявки по ID
@@ -1019,7 +1259,17 @@ func (h *Handler) GetOrder(ctx *gin.Context) {
         return
     }
 
-    ctx.JSON(http.StatusOK, gin.H{"status": "ok", "order": order})
+    response := gin.H{"status": "ok", "order": order}
+    if order.Status == ds.StatusDraft {
+        response["price"] = h.orderPrice(id)
+    }
+    // Возвраты, созданные по заявке
+    if returns, err := h.Repository.GetOrderReturns(id); err == nil && len(returns) > 0 {
+        response["returns"] = returns
+    }
+
+    ctx.Header("ETag", orderETag(order.Version))
+    ctx.JSON(http.StatusOK, response)
 }
 
 // UpdateOrder - обновление заявки
@@ -1038,6 +1288,7 @@ func (h *Handler) UpdateOrder(ctx *gin.Context) {
         Length   float64 `json:"length"`
         Width    float64 `json:"width"`
         Height   float64 `json:"height"`
+        ServiceLevel string `json:"service_level"`
     }
 
     if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -1052,7 +1303,7 @@ func (h *Handler) UpdateOrder(ctx *gin.Context) {
     }
 
     if order.Status != ds.StatusDraft {
-        fail(ctx, http.StatusBadRequest, "can only update draft orders")
+        failOrder(ctx, repository.ErrNotDraft)
         return
     }
 
@@ -1074,13 +1325,44 @@ func (h *Handler) UpdateOrder(ctx *gin.Context) {
     if req.Height > 0 {
         order.Height = req.Height
     }
+    if req.ServiceLevel != "" {
+        if !ds.ValidServiceLevel(req.ServiceLevel) {
+            fail(ctx, http.StatusBadRequest, "unknown service level")
+            return
+        }
+        // Уровень должен быть доступен для каждого транспорта в заявке
+        calc := calculator.NewDeliveryCalculator()
+        for _, line := range order.Services {
+            if _, ok := calc.ServiceLevel(line.ServiceID, req.ServiceLevel); !ok {
+                fail(ctx, http.StatusBadRequest, "service level "+req.ServiceLevel+" is not available for "+line.Service.Name)
+                return
+            }
+        }
+        order.ServiceLevel = req.ServiceLevel
+    }
 
-    if err := h.Repository.UpdateOrder(&order); err != nil {
-        fail(ctx, http.StatusInternalServerError, "failed to update order")
+    version, ok := ifMatchVersion(ctx)
+    if !ok {
         return
     }
 
-    ctx.JSON(http.StatusOK, gin.H{"status": "ok", "order": order})
+    actor, ok := h.currentActor(ctx)
+    if !ok {
+        return
+    }
+
+    if err := h.Repository.UpdateOrder(&order, actor, version); err != nil {
+        failOrder(ctx, err)
+        return
+    }
+
+    // Итоги черновика пересчитаны после изменения груза
+    if updated, err := h.Repository.GetOrder(id); err == nil {
+        order = updated
+    }
+
+    ctx.Header("ETag", orderETag(order.Version))
+    ctx.JSON(http.StatusOK, gin.H{"status": "ok", "order": order, "price": h.orderPrice(id)})
 }
 
 
@@ -1092,11 +1374,14 @@ func (h *Handler) UpdateOrder(ctx *gin.Context) {
 // @Produce json
 // @Security BearerAuth
 // @Param id path int true "Logistic request ID"
+// @Param If-Match header string true "Order version from ETag"
 // @Param request body map[string]string true "Logistic request status (completed/rejected)"
 // @Success 200 {object} map[string]string "Logistic request completed successfully"
 // @Failure 400 {object} map[string]string "Invalid request"
 // @Failure 401 {object} map[string]string "Unauthorized"
 // @Failure 403 {object} map[string]string "Forbidden"
+// @Failure 412 {object} map[string]string "Order was modified by someone else"
+// @Failure 428 {object} map[string]string "If-Match header required"
 // @Router /api/logistic-requests/{id}/complete [put]
 func (h *Handler) CompleteOrder(ctx *gin.Context) {
     // Middleware уже проверил авторизацию и роль модератора
@@ -1109,6 +1394,7 @@ func (h *Handler) CompleteOrder(ctx *gin.Context) {
 
     var req struct {
         Status string `json:"status" binding:"required"`
+        Reason string `json:"reason"` // например, причина отклонения
     }
 
     if err := ctx.ShouldBindJSON(&req); err != nil {
@@ -1122,20 +1408,24 @@ func (h *Handler) CompleteOrder(ctx *gin.Context) {
         return
     }
 
-    // Получаем пользователя для moderatorID
-    userUUID, _ := middleware.GetUserUUID(ctx)
-    user, err := h.Repository.GetUserByUUID(userUUID)
-    if err != nil {
-        fail(ctx, http.StatusInternalServerError, "failed to get user")
+    version, ok := ifMatchVersion(ctx)
+    if !ok {
+        return
+    }
+
+    // Модератор - инициатор перехода
+    actor, ok := h.currentActor(ctx)
+    if !ok {
         return
     }
 
-    err = h.Repository.CompleteOrder(id, req.Status, user.ID)
+    err = h.Repository.CompleteOrder(id, req.Status, actor, version, req.Reason)
     if err != nil {
-        fail(ctx, http.StatusBadRequest, err.Error())
+        failOrder(ctx, err)
         return
     }
 
+    h.setOrderETag(ctx, id)
     ctx.JSON(http.StatusOK, gin.H{
         "status":  "success",
         "message": "Order completed successfully",
@@ -1151,9 +1441,19 @@ func (h *Handler) DeleteOrder(ctx *gin.Context) {
         return
     }
 
-    err = h.Repository.DeleteOrder(id)
+    version, ok := ifMatchVersion(ctx)
+    if !ok {
+        return
+    }
+
+    actor, ok := h.currentActor(ctx)
+    if !ok {
+        return
+    }
+
+    err = h.Repository.DeleteOrder(id, actor, version)
     if err != nil {
-        fail(ctx, http.StatusInternalServerError, "failed to delete order")
+        failOrder(ctx, err)
         return
     }
 
@@ -1173,6 +1473,7 @@ func (h *Handler) GetCartIcon(ctx *gin.Context) {
         "status":   "ok",
         "order_id": orderID,
         "count":    count,
+        "price":    h.orderPrice(orderID),
     })
 }
 
@@ -1180,8 +1481,13 @@ func (h *Handler) GetCartIcon(ctx *gin.Context) {
 
 // AddServiceToOrder - добавление услуги в заявку
 func (h *Handler) AddServiceToOrder(ctx *gin.Context) {
+    orderID, err := strconv.Atoi(ctx.Param("id"))
+    if err != nil {
+        fail(ctx, http.StatusBadRequest, "invalid order id")
+        return
+    }
+
     var req struct {
-        OrderID   int `json:"order_id" binding:"required"`
         ServiceID int `json:"service_id" binding:"required"`
     }
 
@@ -1190,74 +1496,68 @@ func (h *Handler) AddServiceToOrder(ctx *gin.Context) {
         return
     }
 
-    err := h.Repository.AddServiceToOrder(req.OrderID, req.ServiceID)
-    if err != nil {
-        fail(ctx, http.StatusBadRequest, err.Error())
+    version, ok := ifMatchVersion(ctx)
+    if !ok {
         return
     }
 
-    ctx.JSON(http.StatusOK, gin.H{"status": "ok", "message": "service added to order"})
-}
-
-// RemoveServiceFromOrder - удаление услуги из заявки
-func (h *Handler) RemoveServiceFromOrder(ctx *gin.Context) {
-    orderIDStr := ctx.Param("id")
-    serviceIDStr := ctx.Param("service_id")
-    
-    orderID, err := strconv.Atoi(orderIDStr)
-    if err != nil {
-        fail(ctx, http.StatusBadRequest, "invalid order id")
-        return
-    }
-    
-    serviceID, err := strconv.Atoi(serviceIDStr)
-    if err != nil {
-        fail(ctx, http.StatusBadRequest, "invalid service id")
+    actor, ok := h.currentActor(ctx)
+    if !ok {
         return
     }
 
-    err = h.Repository.RemoveServiceFromOrder(orderID, serviceID)
+    line, err := h.Repository.AddServiceToOrder(orderID, req.ServiceID, actor, version)
     if err != nil {
-        fail(ctx, http.StatusBadRequest, err.Error())
+        failOrder(ctx, err)
         return
     }
 
-    ctx.JSON(http.StatusOK, gin.H{"status": "ok", "message": "service removed from order"})
+    h.setOrderETag(ctx, orderID)
+    ctx.JSON(http.StatusOK, gin.H{"status": "ok", "message": "service added to order", "line": line, "price": h.orderPrice(orderID)})
 }
 
-// UpdateOrderService - обновление м-м
-func (h *Handler) UpdateOrderService(ctx *gin.Context) {
-    orderIDStr := ctx.Param("id")
-    serviceIDStr := ctx.Param("service_id")
-    
-    orderID, err := strconv.Atoi(orderIDStr)
-    if err != nil {
-        fail(ctx, http.StatusBadRequest, "invalid order id")
-        return
-    }
-    
-    serviceID, err := strconv.Atoi(serviceIDStr)
-    if err != nil {
-        fail(ctx, http.StatusBadRequest, "invalid service id")
-        return
-    }
-
-    var req struct {
-        Quantity int    `json:"quantity" binding:"required,min=1"`
-        Order    int    `json:"order"`
-        Comment  string `json:"comment"`
-    }
-
-    if err := ctx.ShouldBindJSON(&req); err != nil {
-        fail(ctx, http.StatusBadRequest, "invalid request body")
+// RemoveServiceFromOrder - удаление строки заявки по ID услуги (устарело, см. RemoveOrderLine)
+// @Summary Remove service from logistic request (deprecated)
+// @Description Deprecated: use DELETE /api/orders/{id}/lines/{line_id}. The service ID is resolved to the order line with this service; if several lines carry the service, the request is rejected with 409
+// @Tags logistic-requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param service_id path int true "Service ID"
+// @Param If-Match header string true "Order version from ETag"
+// @Success 200 {object} map[string]interface{} "Line removed"
+// @Failure 404 {object} map[string]string "Service is not in the order"
+// @Failure 409 {object} map[string]string "Several lines carry the service or order is not a draft"
+// @Deprecated
+// @Router /api/orders/{id}/services/{service_id} [delete]
+func (h *Handler) RemoveServiceFromOrder(ctx *gin.Context) {
+    orderID, lineID, ok := h.serviceLineParams(ctx)
+    if !ok {
         return
     }
+    h.removeOrderLine(ctx, orderID, lineID)
+}
 
-    err = h.Repository.UpdateOrderService(orderID, serviceID, req.Quantity, req.Order, req.Comment)
-    if err != nil {
-        fail(ctx, http.StatusBadRequest, err.Error())
+// UpdateOrderService - обновление строки заявки по ID услуги (устарело, см. UpdateOrderLine)
+// @Summary Update service in logistic request (deprecated)
+// @Description Deprecated: use PUT /api/orders/{id}/lines/{line_id}. The service ID is resolved to the order line with this service; if several lines carry the service, the request is rejected with 409
+// @Tags logistic-requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param service_id path int true "Service ID"
+// @Param If-Match header string true "Order version from ETag"
+// @Param request body OrderLineRequest true "Line changes"
+// @Success 200 {object} map[string]interface{} "Line updated"
+// @Failure 404 {object} map[string]string "Service is not in the order"
+// @Failure 409 {object} map[string]string "Several lines carry the service or order is not a draft"
+// @Deprecated
+// @Router /api/orders/{id}/services/{service_id} [put]
+func (h *Handler) UpdateOrderService(ctx *gin.Context) {
+    orderID, lineID, ok := h.serviceLineParams(ctx)
+    if !ok {
         return
     }
-
-    ctx.JSON(http.StatusOK, gin.H{"status": "ok", "message": "order service updated"})
+    h.updateOrderLine(ctx, orderID, lineID)
 }
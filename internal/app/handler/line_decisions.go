@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/repository"
+)
+
+// LineDecisionRequest - решение по строке заявки
+type LineDecisionRequest struct {
+	Decision  string `json:"decision" binding:"required"` // approved, rejected, substituted
+	Reason    string `json:"reason"`                      // обязательна для rejected
+	ServiceID int    `json:"service_id"`                  // услуга-замена для substituted
+}
+
+// FinalizeRequest - итог модерации по решениям о строках
+type FinalizeRequest struct {
+	Reason string `json:"reason"`
+}
+
+// DecideOrderLine - решение модератора по строке заявки
+// @Summary Decide logistic request line
+// @Description Approve, reject (reason required) or substitute (service_id required) a single service line of a formed logistic request. Order totals are recalculated from accepted lines
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param line_id path int true "Line ID"
+// @Param If-Match header string true "Order version from ETag"
+// @Param request body LineDecisionRequest true "Decision"
+// @Success 200 {object} map[string]interface{} "Updated logistic request"
+// @Failure 404 {object} map[string]string "Line not found"
+// @Failure 409 {object} map[string]string "Order is not formed or claimed by another manager"
+// @Router /api/orders/{id}/lines/{line_id}/decision [put]
+func (h *Handler) DecideOrderLine(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+	lineID, err := strconv.Atoi(ctx.Param("line_id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid line id")
+		return
+	}
+
+	var req LineDecisionRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	order, err := h.Repository.DecideOrderLine(id, lineID, repository.LineDecision{
+		Decision:  req.Decision,
+		Reason:    req.Reason,
+		ServiceID: req.ServiceID,
+	}, actor, version)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	ctx.Header("ETag", orderETag(order.Version))
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "order": order})
+}
+
+// FinalizeOrder - завершение модерации по решениям о строках
+// @Summary Finalize logistic request moderation
+// @Description Complete the logistic request if at least one line is accepted, otherwise reject it. All lines must have a decision
+// @Tags moderation
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param If-Match header string true "Order version from ETag"
+// @Param request body FinalizeRequest false "Reason"
+// @Success 200 {object} map[string]interface{} "Final status"
+// @Failure 409 {object} map[string]string "Some lines are undecided"
+// @Router /api/orders/{id}/finalize [post]
+func (h *Handler) FinalizeOrder(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	var req FinalizeRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			fail(ctx, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	status, err := h.Repository.FinalizeOrder(id, actor, version, req.Reason)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	h.setOrderETag(ctx, id)
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "order_id": id, "new_status": status})
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/repository"
+)
+
+// PostMessageRequest - новое сообщение в обсуждении заявки
+type PostMessageRequest struct {
+	Body     string `json:"body" binding:"required"`
+	ParentID *int   `json:"parent_id"`
+	Internal bool   `json:"internal"` // внутренняя заметка, видна только менеджерам
+}
+
+// GetOrderMessages - обсуждение заявки
+// @Summary Get logistic request messages
+// @Description Messages of the logistic request thread in writing order with authors and read receipts. Internal notes are returned to managers and admins only
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Messages"
+// @Router /api/orders/{id}/messages [get]
+func (h *Handler) GetOrderMessages(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	messages, err := h.Repository.GetMessages(id, isModerator(user.Role))
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get messages")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "messages": messages})
+}
+
+// PostOrderMessage - сообщение или ответ в обсуждении заявки
+// @Summary Post logistic request message
+// @Description Post message to the logistic request thread. parent_id makes it a reply; replies to internal notes are internal too. Only managers and admins may post internal notes
+// @Tags messages
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param request body PostMessageRequest true "Message"
+// @Success 201 {object} map[string]interface{} "Message posted"
+// @Failure 400 {object} map[string]string "Invalid message"
+// @Failure 403 {object} map[string]string "Internal notes are for managers only"
+// @Failure 404 {object} map[string]string "Parent message not found"
+// @Router /api/orders/{id}/messages [post]
+func (h *Handler) PostOrderMessage(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	var req PostMessageRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+	moderator := isModerator(user.Role)
+	if req.Internal && !moderator {
+		fail(ctx, http.StatusForbidden, "internal notes are available to managers only")
+		return
+	}
+
+	message, err := h.Repository.PostMessage(id, user.ID, req.ParentID, req.Body, req.Internal, moderator)
+	if err != nil {
+		if errors.Is(err, repository.ErrMessageNotFound) {
+			fail(ctx, http.StatusNotFound, err.Error())
+			return
+		}
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "ok", "message": message})
+}
+
+// MarkOrderMessagesRead - отметка сообщений заявки прочитанными
+// @Summary Mark logistic request messages read
+// @Description Mark all messages of the logistic request visible to the current user as read
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Marked"
+// @Router /api/orders/{id}/messages/read [post]
+func (h *Handler) MarkOrderMessagesRead(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	marked, err := h.Repository.MarkMessagesRead(id, user.ID, isModerator(user.Role))
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to mark messages read")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "marked": marked})
+}
+
+// GetUnreadMessages - число непрочитанных сообщений текущего пользователя
+// @Summary Get unread message count
+// @Description Number of unread messages in logistic requests available to the current user, total and per request
+// @Tags messages
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Unread count"
+// @Router /api/messages/unread [get]
+func (h *Handler) GetUnreadMessages(ctx *gin.Context) {
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	var scope repository.OrderFilter
+	scopeOrderFilter(user, &scope)
+
+	summary, err := h.Repository.UnreadCount(user.ID, scope, isModerator(user.Role))
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to count unread messages")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "unread": summary})
+}
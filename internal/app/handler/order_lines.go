@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OrderLineRequest - изменение строки заявки
+type OrderLineRequest struct {
+	Quantity int    `json:"quantity" binding:"required,min=1"`
+	Order    int    `json:"order"`
+	Comment  string `json:"comment"`
+}
+
+// RemoveOrderLine - удаление строки из заявки-черновика
+// @Summary Remove logistic request line
+// @Description Removes a single line of a draft logistic request by its line ID (the "id" of an item in "services"). Order totals are recalculated from the remaining lines
+// @Tags logistic-requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param line_id path int true "Line ID"
+// @Param If-Match header string true "Order version from ETag"
+// @Success 200 {object} map[string]interface{} "Line removed"
+// @Failure 404 {object} map[string]string "Line not found"
+// @Failure 409 {object} map[string]string "Order is not a draft"
+// @Router /api/orders/{id}/lines/{line_id} [delete]
+func (h *Handler) RemoveOrderLine(ctx *gin.Context) {
+	orderID, lineID, ok := lineParams(ctx)
+	if !ok {
+		return
+	}
+	h.removeOrderLine(ctx, orderID, lineID)
+}
+
+// UpdateOrderLine - изменение количества, порядка и комментария строки заявки-черновика
+// @Summary Update logistic request line
+// @Description Updates quantity, position and comment of a single line of a draft logistic request by its line ID (the "id" of an item in "services")
+// @Tags logistic-requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param line_id path int true "Line ID"
+// @Param If-Match header string true "Order version from ETag"
+// @Param request body OrderLineRequest true "Line changes"
+// @Success 200 {object} map[string]interface{} "Line updated"
+// @Failure 404 {object} map[string]string "Line not found"
+// @Failure 409 {object} map[string]string "Order is not a draft"
+// @Router /api/orders/{id}/lines/{line_id} [put]
+func (h *Handler) UpdateOrderLine(ctx *gin.Context) {
+	orderID, lineID, ok := lineParams(ctx)
+	if !ok {
+		return
+	}
+	h.updateOrderLine(ctx, orderID, lineID)
+}
+
+// lineParams - ID заявки и строки из пути /:id/lines/:line_id
+func lineParams(ctx *gin.Context) (int, int, bool) {
+	orderID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return 0, 0, false
+	}
+	lineID, err := strconv.Atoi(ctx.Param("line_id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid line id")
+		return 0, 0, false
+	}
+	return orderID, lineID, true
+}
+
+// serviceLineParams - ID заявки и строки для устаревшего пути /:id/services/:service_id.
+// Строка ищется по услуге; если услуга встречается в нескольких строках, запрос отклоняется (409),
+// чтобы старый клиент не изменил не ту строку. Ответ указывает на новый путь строки
+func (h *Handler) serviceLineParams(ctx *gin.Context) (int, int, bool) {
+	orderID, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return 0, 0, false
+	}
+	serviceID, err := strconv.Atoi(ctx.Param("service_id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid service id")
+		return 0, 0, false
+	}
+
+	ctx.Header("Deprecation", "true")
+	line, err := h.Repository.GetOrderLineByService(orderID, serviceID)
+	if err != nil {
+		failOrder(ctx, err)
+		return 0, 0, false
+	}
+
+	successor := strings.TrimSuffix(ctx.Request.URL.Path, "/services/"+ctx.Param("service_id")) + "/lines/" + strconv.Itoa(line.ID)
+	ctx.Header("Link", "<"+successor+`>; rel="successor-version"`)
+	return orderID, line.ID, true
+}
+
+// removeOrderLine - удаление строки lineID из заявки orderID
+func (h *Handler) removeOrderLine(ctx *gin.Context, orderID, lineID int) {
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.Repository.RemoveServiceFromOrder(orderID, lineID, actor, version); err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	h.setOrderETag(ctx, orderID)
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "message": "service removed from order", "price": h.orderPrice(orderID)})
+}
+
+// updateOrderLine - изменение строки lineID заявки orderID
+func (h *Handler) updateOrderLine(ctx *gin.Context, orderID, lineID int) {
+	var req OrderLineRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	err := h.Repository.UpdateOrderService(orderID, lineID, req.Quantity, req.Order, req.Comment, actor, version)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	h.setOrderETag(ctx, orderID)
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "message": "order service updated", "price": h.orderPrice(orderID)})
+}
@@ -0,0 +1,273 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/payments"
+	"rip-go-app/internal/app/repository"
+	"rip-go-app/internal/app/service"
+)
+
+// maxWebhookSize - ограничение размера уведомления провайдера
+const maxWebhookSize = 64 << 10
+
+// RefundRequest - возврат платежа
+type RefundRequest struct {
+	Amount float64 `json:"amount"` // 0 - весь остаток
+	Reason string  `json:"reason"`
+}
+
+// CreditRequest - отсрочка оплаты клиенту
+type CreditRequest struct {
+	CreditApproved bool `json:"credit_approved"`
+}
+
+// SetPaymentService - сервис оплаты; без него платежи недоступны
+func (h *Handler) SetPaymentService(s *service.PaymentService) {
+	h.Payments = s
+}
+
+// failPayment - ответ с ошибкой оплаты
+func failPayment(ctx *gin.Context, err error) {
+	switch {
+	case errors.Is(err, repository.ErrPaymentNotFound):
+		fail(ctx, http.StatusNotFound, err.Error())
+	case errors.Is(err, repository.ErrPaymentState):
+		fail(ctx, http.StatusConflict, err.Error())
+	case errors.Is(err, service.ErrPaymentProvider):
+		fail(ctx, http.StatusBadGateway, err.Error())
+	case errors.Is(err, payments.ErrInvalidSignature):
+		fail(ctx, http.StatusUnauthorized, err.Error())
+	default:
+		failOrder(ctx, err)
+	}
+}
+
+// paymentsEnabled - проверка, что оплата настроена
+func (h *Handler) paymentsEnabled(ctx *gin.Context) bool {
+	if h.Payments == nil {
+		fail(ctx, http.StatusServiceUnavailable, "payments are not configured")
+		return false
+	}
+	return true
+}
+
+// GetOrderPayments - платежи заявки
+// @Summary Get logistic request payments
+// @Tags payments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Payments"
+// @Router /api/orders/{id}/payments [get]
+func (h *Handler) GetOrderPayments(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	order, err := h.Repository.GetOrder(id)
+	if err != nil {
+		fail(ctx, http.StatusNotFound, "order not found")
+		return
+	}
+	list, err := h.Repository.GetPayments(id)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get payments")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "payment_status": order.PaymentStatus, "payments": list})
+}
+
+// PayOrder - создание платежа на стоимость заявки
+// @Summary Pay logistic request
+// @Description Create payment for the full cost of a completed or later logistic request. If confirmation_url is returned the customer has to confirm the payment there. Repeated call returns the pending payment
+// @Tags payments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 201 {object} map[string]interface{} "Payment created"
+// @Failure 409 {object} map[string]string "Already paid or cannot be paid in current status"
+// @Failure 502 {object} map[string]string "Payment provider error"
+// @Router /api/orders/{id}/payments [post]
+func (h *Handler) PayOrder(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+	if !h.paymentsEnabled(ctx) {
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	payment, err := h.Payments.Pay(ctx.Request.Context(), id, actor)
+	if err != nil {
+		failPayment(ctx, err)
+		return
+	}
+
+	h.setOrderETag(ctx, id)
+	ctx.JSON(http.StatusCreated, gin.H{"status": "ok", "payment": payment})
+}
+
+// CapturePayment - списание авторизованного платежа
+// @Summary Capture payment
+// @Description Capture the authorized payment of the logistic request. Shipping starts only for paid requests unless the customer is credit-approved
+// @Tags payments
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Payment captured"
+// @Failure 409 {object} map[string]string "Payment is not authorized"
+// @Router /api/orders/{id}/payments/capture [post]
+func (h *Handler) CapturePayment(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+	if !h.paymentsEnabled(ctx) {
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	payment, err := h.Payments.Capture(ctx.Request.Context(), id, actor)
+	if err != nil {
+		failPayment(ctx, err)
+		return
+	}
+
+	h.setOrderETag(ctx, id)
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "payment": payment})
+}
+
+// RefundPayment - возврат списанного платежа
+// @Summary Refund payment
+// @Description Refund the paid logistic request fully (amount 0) or partially. A pending cancellation refund is marked refunded once the amount refunded after cancellation covers it
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param request body RefundRequest false "Refund"
+// @Success 200 {object} map[string]interface{} "Payment refunded"
+// @Failure 409 {object} map[string]string "Payment is not paid or amount exceeds remaining"
+// @Router /api/orders/{id}/payments/refund [post]
+func (h *Handler) RefundPayment(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+	if !h.paymentsEnabled(ctx) {
+		return
+	}
+
+	var req RefundRequest
+	if ctx.Request.ContentLength != 0 {
+		if err := ctx.ShouldBindJSON(&req); err != nil {
+			fail(ctx, http.StatusBadRequest, "invalid request body")
+			return
+		}
+	}
+	if req.Amount < 0 {
+		fail(ctx, http.StatusBadRequest, "amount must not be negative")
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	payment, err := h.Payments.Refund(ctx.Request.Context(), id, req.Amount, req.Reason, actor)
+	if err != nil {
+		failPayment(ctx, err)
+		return
+	}
+
+	h.setOrderETag(ctx, id)
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "payment": payment})
+}
+
+// PaymentWebhook - уведомление платёжного провайдера
+// @Summary Payment provider webhook
+// @Description Payment status notification. Authenticity is checked by the provider signature
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param provider path string true "Provider code"
+// @Success 200 {object} map[string]string "Accepted"
+// @Failure 401 {object} map[string]string "Invalid signature"
+// @Router /api/payments/webhook/{provider} [post]
+func (h *Handler) PaymentWebhook(ctx *gin.Context) {
+	if !h.paymentsEnabled(ctx) {
+		return
+	}
+	if ctx.Param("provider") != h.Payments.Provider() {
+		fail(ctx, http.StatusNotFound, "unknown payment provider")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(ctx.Request.Body, maxWebhookSize))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "failed to read body")
+		return
+	}
+
+	payment, err := h.Payments.HandleWebhook(body, ctx.Request.Header)
+	if err != nil {
+		failPayment(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "payment_status": payment.Status})
+}
+
+// SetUserCredit - одобрение отсрочки оплаты клиенту
+// @Summary Set customer credit approval
+// @Description Credit-approved customers can have their logistic requests shipped before payment
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "User ID"
+// @Param request body CreditRequest true "Credit approval"
+// @Success 200 {object} map[string]interface{} "User updated"
+// @Failure 404 {object} map[string]string "User not found"
+// @Router /api/users/{id}/credit [put]
+func (h *Handler) SetUserCredit(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid user id")
+		return
+	}
+
+	var req CreditRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	user, err := h.Repository.SetCreditApproved(id, req.CreditApproved)
+	if err != nil {
+		fail(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	user.Password = ""
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "user": user})
+}
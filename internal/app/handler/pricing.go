@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"github.com/sirupsen/logrus"
+	"rip-go-app/internal/app/repository"
+)
+
+// orderPrice - расшифровка стоимости заявки для ответа; при ошибке nil
+func (h *Handler) orderPrice(orderID int) *repository.PriceBreakdown {
+	price, err := h.Repository.GetPriceBreakdown(orderID)
+	if err != nil {
+		logrus.Errorf("price breakdown for order %d: %v", orderID, err)
+		return nil
+	}
+	return &price
+}
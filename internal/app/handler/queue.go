@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/repository"
+)
+
+// GetQueue - очередь модерации: мои, неназначенные и просроченные заявки
+// @Summary Get moderation queue
+// @Description Formed logistic requests in arrival order. Views: mine (claimed by or assigned to me), unassigned (free or with expired claim), overdue (waiting longer than the configured limit)
+// @Tags queue
+// @Produce json
+// @Security BearerAuth
+// @Param view query string false "Queue view: mine, unassigned, overdue" default(unassigned)
+// @Success 200 {object} map[string]interface{} "Queue retrieved"
+// @Failure 400 {object} map[string]string "Invalid view"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Router /api/queue [get]
+func (h *Handler) GetQueue(ctx *gin.Context) {
+	view := ctx.DefaultQuery("view", repository.QueueUnassigned)
+	if view != repository.QueueMine && view != repository.QueueUnassigned && view != repository.QueueOverdue {
+		fail(ctx, http.StatusBadRequest, "invalid view. allowed: mine, unassigned, overdue")
+		return
+	}
+
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	orders, err := h.Repository.GetQueue(view, user.ID)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get queue")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "view": view, "orders": orders})
+}
+
+// ClaimOrder - менеджер берёт заявку в работу на срок захвата
+// @Summary Claim logistic request
+// @Description Claim formed logistic request for moderation. Other managers cannot claim or decide it until the lease expires. Claiming own request again extends the lease
+// @Tags queue
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Claimed"
+// @Failure 409 {object} map[string]string "Claimed by another manager or not in queue"
+// @Router /api/orders/{id}/claim [post]
+func (h *Handler) ClaimOrder(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	order, err := h.Repository.ClaimOrder(id, actor)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	ctx.Header("ETag", orderETag(order.Version))
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "order": order})
+}
+
+// ReleaseOrder - возврат заявки в общую очередь
+// @Summary Release logistic request
+// @Description Return claimed logistic request to the queue. Allowed to the assignee and admins
+// @Tags queue
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Released"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Router /api/orders/{id}/release [post]
+func (h *Handler) ReleaseOrder(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	order, err := h.Repository.ReleaseOrder(id, actor)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	ctx.Header("ETag", orderETag(order.Version))
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "order": order})
+}
+
+// AssignOrder - назначение ответственного менеджера администратором
+// @Summary Assign logistic request
+// @Description Assign or reassign formed logistic request to a manager (admin only). Assignment has no lease
+// @Tags queue
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param request body map[string]int true "Assignee: {\"assignee_id\": 5}"
+// @Success 200 {object} map[string]interface{} "Assigned"
+// @Failure 400 {object} map[string]string "Invalid assignee"
+// @Failure 403 {object} map[string]string "Forbidden"
+// @Router /api/orders/{id}/assignee [put]
+func (h *Handler) AssignOrder(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	var req struct {
+		AssigneeID int `json:"assignee_id" binding:"required"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	order, err := h.Repository.AssignOrder(id, req.AssigneeID, actor)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	ctx.Header("ETag", orderETag(order.Version))
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "order": order})
+}
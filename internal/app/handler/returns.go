@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/repository"
+)
+
+// CreateReturnOrder - черновик возврата груза по завершённой заявке
+// @Summary Create return shipment
+// @Description Create a linked draft with the route reversed and the original cargo (all or some lines and quantities). Requires a return reason; an optional special return tariff discounts the price and can be set only by a manager or admin
+// @Tags logistic-requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param request body repository.ReturnRequest true "Return reason, tariff and lines"
+// @Success 201 {object} map[string]interface{} "Return draft created"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 403 {object} map[string]string "Special tariff requested by a buyer"
+// @Failure 404 {object} map[string]string "Not found"
+// @Failure 409 {object} map[string]string "Order cannot be returned"
+// @Router /api/orders/{id}/return [post]
+func (h *Handler) CreateReturnOrder(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	var req repository.ReturnRequest
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	order, err := h.Repository.CreateReturnOrder(id, req, actor)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	ctx.Header("ETag", orderETag(order.Version))
+	ctx.JSON(http.StatusCreated, gin.H{"status": "ok", "order": order, "price": h.orderPrice(order.ID)})
+}
+
+// GetOrderReturns - возвраты по заявке и доступные тарифы возврата
+// @Summary Get return shipments of logistic request
+// @Description Return drafts and shipments created from the logistic request, with the available special return tariffs
+// @Tags logistic-requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Returns and tariffs"
+// @Router /api/orders/{id}/returns [get]
+func (h *Handler) GetOrderReturns(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	returns, err := h.Repository.GetOrderReturns(id)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get returns")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"returns": returns, "tariffs": h.Repository.GetReturnTariffs()})
+}
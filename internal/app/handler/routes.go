@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/ds"
+)
+
+// RegisterOrderRoutes - маршруты заявок на группе, которая уже требует авторизации (RequireAuth).
+// Маршруты конкретной заявки дополнительно проверяют права на неё
+func (h *Handler) RegisterOrderRoutes(group *gin.RouterGroup) {
+	group.GET("", h.GetOrders)
+
+	moderator := h.AuthMiddleware.RequireRole(ds.RoleManager, ds.RoleAdmin)
+	carrier := h.AuthMiddleware.RequireRole(ds.RoleManager, ds.RoleAdmin, ds.RoleDriver)
+
+	order := group.Group("/:id")
+	order.Use(h.RequireOrderAccess())
+	{
+		order.GET("", h.GetOrder)
+		order.DELETE("", h.DeleteOrder)
+		order.PUT("/form", h.FormOrder)
+		order.PUT("/update", h.UpdateOrder)
+		order.PUT("/status", h.UpdateOrderStatus)
+		order.PUT("/complete", moderator, h.CompleteOrder)
+
+		// М-М заявка-услуга
+		order.POST("/services", h.AddServiceToOrder)
+		order.DELETE("/lines/:line_id", h.RemoveOrderLine)
+		order.PUT("/lines/:line_id", h.UpdateOrderLine)
+		// Устаревшие пути: строка ищется по ID услуги
+		order.DELETE("/services/:service_id", h.RemoveServiceFromOrder)
+		order.PUT("/services/:service_id", h.UpdateOrderService)
+
+		order.PUT("/customs", h.UpdateOrderCustoms)
+		order.GET("/customs-declaration", h.GetCustomsDeclaration)
+		order.GET("/stops", h.GetOrderStops)
+		order.PUT("/stops", h.UpdateOrderStops)
+		order.PUT("/windows", h.UpdateOrderWindows)
+		order.GET("/feasibility", h.GetOrderFeasibility)
+		order.GET("/history", h.GetOrderHistory)
+		order.POST("/clone", h.AuthMiddleware.RequireRole(ds.RoleBuyer, ds.RoleManager, ds.RoleAdmin), h.CloneOrder)
+		order.POST("/return", h.AuthMiddleware.RequireRole(ds.RoleBuyer, ds.RoleManager, ds.RoleAdmin), h.CreateReturnOrder)
+		order.GET("/returns", h.GetOrderReturns)
+		order.GET("/tracking", h.GetOrderTracking)
+		order.POST("/tracking", carrier, h.AddTrackingEvent)
+
+		// Обсуждение заявки
+		order.GET("/messages", h.GetOrderMessages)
+		order.POST("/messages", h.PostOrderMessage)
+		order.POST("/messages/read", h.MarkOrderMessagesRead)
+
+		// Вложения
+		order.GET("/attachments", h.GetAttachments)
+		order.POST("/attachments", h.UploadAttachment)
+		order.GET("/attachments/:attachment_id/url", h.GetAttachmentURL)
+		order.DELETE("/attachments/:attachment_id", h.DeleteAttachment)
+
+		// Печатные документы: счёт и транспортная накладная
+		order.GET("/documents/:kind", h.GetOrderDocument)
+
+		// Оплата
+		order.GET("/payments", h.GetOrderPayments)
+		order.POST("/payments", h.AuthMiddleware.RequireRole(ds.RoleBuyer, ds.RoleAdmin), h.PayOrder)
+		order.POST("/payments/capture", moderator, h.CapturePayment)
+		order.POST("/payments/refund", moderator, h.RefundPayment)
+
+		// Отмена клиентом со штрафом и возвратом
+		order.POST("/cancel", h.CancelOrder)
+		order.GET("/cancellation", h.GetCancellation)
+		order.GET("/cancellation/quote", h.GetCancellationQuote)
+
+		// Решения по отдельным строкам и итог модерации
+		order.PUT("/lines/:line_id/decision", moderator, h.DecideOrderLine)
+		order.POST("/finalize", moderator, h.FinalizeOrder)
+
+		// Очередь модерации
+		order.POST("/claim", moderator, h.ClaimOrder)
+		order.POST("/release", moderator, h.ReleaseOrder)
+		order.PUT("/assignee", h.AuthMiddleware.RequireRole(ds.RoleAdmin), h.AssignOrder)
+	}
+}
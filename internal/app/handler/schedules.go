@@ -0,0 +1,280 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/repository"
+)
+
+// maxPreview - максимум дат в предпросмотре расписания
+const maxPreview = 50
+
+// loadSchedule - расписание из параметра :id, доступное владельцу и модераторам
+func (h *Handler) loadSchedule(ctx *gin.Context) (ds.Schedule, bool) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid schedule id")
+		return ds.Schedule{}, false
+	}
+
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return ds.Schedule{}, false
+	}
+
+	s, err := h.Repository.GetSchedule(id)
+	if err != nil {
+		fail(ctx, http.StatusNotFound, "schedule not found")
+		return ds.Schedule{}, false
+	}
+	if s.CreatorID != user.ID && !isModerator(user.Role) {
+		fail(ctx, http.StatusForbidden, "access to this schedule is forbidden")
+		return ds.Schedule{}, false
+	}
+	return s, true
+}
+
+// previewCount - число дат предпросмотра из ?n=
+func previewCount(ctx *gin.Context) (int, bool) {
+	n, err := strconv.Atoi(ctx.DefaultQuery("n", "5"))
+	if err != nil || n <= 0 || n > maxPreview {
+		fail(ctx, http.StatusBadRequest, "n must be between 1 and 50")
+		return 0, false
+	}
+	return n, true
+}
+
+// GetSchedules - расписания регулярных отправок
+// @Summary Get recurring shipment schedules
+// @Description Get own schedules (moderators see all)
+// @Tags schedules
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Schedules"
+// @Router /api/schedules [get]
+func (h *Handler) GetSchedules(ctx *gin.Context) {
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	var creatorID *int
+	if !isModerator(user.Role) {
+		creatorID = &user.ID
+	}
+
+	schedules, err := h.Repository.GetSchedules(creatorID)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get schedules")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "schedules": schedules})
+}
+
+// CreateSchedule - создание расписания регулярной отправки
+// @Summary Create recurring shipment schedule
+// @Description Create shipment template (route, cargo, services) with recurrence rule: weekly (weekdays "mon,thu" + time_of_day), monthly (month_day + time_of_day) or cron ("0 9 * * 1"). Orders are created lead_days ahead of each date, formed automatically when auto_form is set
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ds.Schedule true "Schedule"
+// @Success 201 {object} map[string]interface{} "Schedule created"
+// @Failure 400 {object} map[string]string "Invalid schedule"
+// @Router /api/schedules [post]
+func (h *Handler) CreateSchedule(ctx *gin.Context) {
+	var s ds.Schedule
+	if err := ctx.ShouldBindJSON(&s); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+	s.CreatorID = user.ID
+
+	if err := h.Repository.CreateSchedule(&s); err != nil {
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"status": "ok", "schedule": s})
+}
+
+// GetSchedule - расписание по ID
+// @Summary Get recurring shipment schedule
+// @Tags schedules
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]interface{} "Schedule"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/schedules/{id} [get]
+func (h *Handler) GetSchedule(ctx *gin.Context) {
+	s, ok := h.loadSchedule(ctx)
+	if !ok {
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "schedule": s})
+}
+
+// UpdateSchedule - изменение расписания
+// @Summary Update recurring shipment schedule
+// @Description Update template and recurrence rule. Next date is recalculated from now
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Schedule ID"
+// @Param request body ds.Schedule true "Schedule"
+// @Success 200 {object} map[string]interface{} "Schedule updated"
+// @Failure 400 {object} map[string]string "Invalid schedule"
+// @Router /api/schedules/{id} [put]
+func (h *Handler) UpdateSchedule(ctx *gin.Context) {
+	existing, ok := h.loadSchedule(ctx)
+	if !ok {
+		return
+	}
+
+	var s ds.Schedule
+	if err := ctx.ShouldBindJSON(&s); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	s.ID = existing.ID
+
+	if err := h.Repository.UpdateSchedule(&s); err != nil {
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "schedule": s})
+}
+
+// DeleteSchedule - удаление расписания
+// @Summary Delete recurring shipment schedule
+// @Description Delete schedule. Already created orders are kept
+// @Tags schedules
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]string "Schedule deleted"
+// @Router /api/schedules/{id} [delete]
+func (h *Handler) DeleteSchedule(ctx *gin.Context) {
+	s, ok := h.loadSchedule(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.Repository.DeleteSchedule(s.ID); err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to delete schedule")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "message": "schedule deleted"})
+}
+
+// PauseSchedule - приостановка расписания
+// @Summary Pause recurring shipment schedule
+// @Tags schedules
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]interface{} "Schedule paused"
+// @Router /api/schedules/{id}/pause [post]
+func (h *Handler) PauseSchedule(ctx *gin.Context) {
+	h.setSchedulePaused(ctx, true)
+}
+
+// ResumeSchedule - возобновление расписания (пропущенные за время паузы даты не создаются)
+// @Summary Resume recurring shipment schedule
+// @Tags schedules
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Schedule ID"
+// @Success 200 {object} map[string]interface{} "Schedule resumed"
+// @Router /api/schedules/{id}/resume [post]
+func (h *Handler) ResumeSchedule(ctx *gin.Context) {
+	h.setSchedulePaused(ctx, false)
+}
+
+func (h *Handler) setSchedulePaused(ctx *gin.Context, paused bool) {
+	s, ok := h.loadSchedule(ctx)
+	if !ok {
+		return
+	}
+
+	s, err := h.Repository.SetSchedulePaused(s.ID, paused)
+	if err != nil {
+		if errors.Is(err, repository.ErrScheduleNotFound) {
+			fail(ctx, http.StatusNotFound, "schedule not found")
+			return
+		}
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "schedule": s})
+}
+
+// PreviewSchedule - ближайшие даты отправки сохранённого расписания
+// @Summary Preview schedule occurrences
+// @Description Next N shipment dates of schedule (holidays excluded when skip_holidays is set)
+// @Tags schedules
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Schedule ID"
+// @Param n query int false "Number of dates (max 50)" default(5)
+// @Success 200 {object} map[string]interface{} "Dates"
+// @Router /api/schedules/{id}/preview [get]
+func (h *Handler) PreviewSchedule(ctx *gin.Context) {
+	s, ok := h.loadSchedule(ctx)
+	if !ok {
+		return
+	}
+	n, ok := previewCount(ctx)
+	if !ok {
+		return
+	}
+
+	dates, err := h.Repository.PreviewSchedule(&s, n)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "dates": dates})
+}
+
+// PreviewScheduleRule - даты отправки для правила до сохранения расписания
+// @Summary Preview recurrence rule
+// @Description Next N shipment dates for unsaved schedule rule
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param n query int false "Number of dates (max 50)" default(5)
+// @Param request body ds.Schedule true "Schedule rule"
+// @Success 200 {object} map[string]interface{} "Dates"
+// @Failure 400 {object} map[string]string "Invalid rule"
+// @Router /api/schedules/preview [post]
+func (h *Handler) PreviewScheduleRule(ctx *gin.Context) {
+	n, ok := previewCount(ctx)
+	if !ok {
+		return
+	}
+
+	var s ds.Schedule
+	if err := ctx.ShouldBindJSON(&s); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	dates, err := h.Repository.PreviewSchedule(&s, n)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "dates": dates})
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// minSearchQuery - минимальная длина запроса для нечёткого поиска по триграммам
+const minSearchQuery = 2
+
+// Search - полнотекстовый поиск по заявкам, комментариям и услугам
+// @Summary Search
+// @Description Full-text (russian/english) and fuzzy search over logistic requests (route, tracking number, customer name and login), request comments and services. Results are grouped by entity type with highlighted snippets. Buyers find only their own requests
+// @Tags search
+// @Produce json
+// @Security BearerAuth
+// @Param q query string true "Search query"
+// @Success 200 {object} map[string]interface{} "Search results"
+// @Failure 400 {object} map[string]string "Invalid query"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Router /api/search [get]
+func (h *Handler) Search(ctx *gin.Context) {
+	q := strings.TrimSpace(ctx.Query("q"))
+	if utf8.RuneCountInString(q) < minSearchQuery {
+		fail(ctx, http.StatusBadRequest, "query must be at least 2 characters")
+		return
+	}
+
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	// Модераторы ищут по всем заявкам, остальные - только по своим
+	var creatorID *int
+	if !isModerator(user.Role) {
+		id := user.ID
+		creatorID = &id
+	}
+
+	results, err := h.Repository.Search(q, creatorID)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "search failed")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "query": q, "results": results})
+}
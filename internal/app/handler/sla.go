@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/repository"
+)
+
+// GetSLAPolicies - список политик SLA
+// @Summary Get SLA policies
+// @Description Get SLA policies: response time in business hours per service or customer, warning threshold and escalation actions
+// @Tags sla
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Policies retrieved"
+// @Router /api/sla/policies [get]
+func (h *Handler) GetSLAPolicies(ctx *gin.Context) {
+	policies, err := h.Repository.GetSLAPolicies()
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get sla policies")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "policies": policies})
+}
+
+// CreateSLAPolicy - создание политики SLA
+// @Summary Create SLA policy
+// @Description Create SLA policy. Escalation is a comma separated list of: reassign, notify_admin, priority
+// @Tags sla
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body ds.SLAPolicy true "Policy"
+// @Success 201 {object} map[string]interface{} "Policy created"
+// @Failure 400 {object} map[string]string "Invalid policy"
+// @Router /api/sla/policies [post]
+func (h *Handler) CreateSLAPolicy(ctx *gin.Context) {
+	var policy ds.SLAPolicy
+	if err := ctx.ShouldBindJSON(&policy); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	if err := h.Repository.CreateSLAPolicy(&policy); err != nil {
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusCreated, gin.H{"status": "ok", "policy": policy})
+}
+
+// UpdateSLAPolicy - изменение политики SLA
+// @Summary Update SLA policy
+// @Description Update SLA policy. Deadlines of already formed requests are not recalculated
+// @Tags sla
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Policy ID"
+// @Param request body ds.SLAPolicy true "Policy"
+// @Success 200 {object} map[string]interface{} "Policy updated"
+// @Failure 400 {object} map[string]string "Invalid policy"
+// @Router /api/sla/policies/{id} [put]
+func (h *Handler) UpdateSLAPolicy(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid policy id")
+		return
+	}
+
+	var policy ds.SLAPolicy
+	if err := ctx.ShouldBindJSON(&policy); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	policy.ID = id
+
+	if err := h.Repository.UpdateSLAPolicy(&policy); err != nil {
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "policy": policy})
+}
+
+// DeleteSLAPolicy - удаление политики SLA
+// @Summary Delete SLA policy
+// @Tags sla
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Policy ID"
+// @Success 200 {object} map[string]string "Policy deleted"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/sla/policies/{id} [delete]
+func (h *Handler) DeleteSLAPolicy(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid policy id")
+		return
+	}
+
+	if err := h.Repository.DeleteSLAPolicy(id); err != nil {
+		fail(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "message": "policy deleted"})
+}
+
+// GetSLAReport - отчёт о соблюдении SLA
+// @Summary SLA compliance report
+// @Description SLA compliance of processed logistic requests per moderator or per period (day, week, month). Response time is measured in business hours
+// @Tags sla
+// @Produce json
+// @Security BearerAuth
+// @Param date_from query string true "Period start (YYYY-MM-DD)"
+// @Param date_to query string true "Period end, inclusive (YYYY-MM-DD)"
+// @Param group query string false "Grouping: moderator, period" default(moderator)
+// @Param interval query string false "Interval for period grouping: day, week, month" default(week)
+// @Success 200 {object} map[string]interface{} "Report"
+// @Failure 400 {object} map[string]string "Invalid parameters"
+// @Router /api/sla/report [get]
+func (h *Handler) GetSLAReport(ctx *gin.Context) {
+	from, err := time.Parse("2006-01-02", ctx.Query("date_from"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid date_from, expected YYYY-MM-DD")
+		return
+	}
+	to, err := time.Parse("2006-01-02", ctx.Query("date_to"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid date_to, expected YYYY-MM-DD")
+		return
+	}
+
+	group := ctx.DefaultQuery("group", repository.SLAByModerator)
+	interval := ctx.DefaultQuery("interval", "week")
+
+	rows, err := h.Repository.SLAReport(from, to.AddDate(0, 0, 1), group, interval)
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "group": group, "report": rows})
+}
+
+// GetNotifications - уведомления текущего пользователя
+// @Summary Get notifications
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param unread query bool false "Only unread"
+// @Success 200 {object} map[string]interface{} "Notifications"
+// @Router /api/notifications [get]
+func (h *Handler) GetNotifications(ctx *gin.Context) {
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	notifications, err := h.Repository.GetNotifications(user.ID, ctx.Query("unread") == "true")
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get notifications")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "notifications": notifications})
+}
+
+// MarkNotificationRead - отметка уведомления прочитанным
+// @Summary Mark notification read
+// @Tags notifications
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Notification ID"
+// @Success 200 {object} map[string]string "Marked"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/notifications/{id}/read [put]
+func (h *Handler) MarkNotificationRead(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid notification id")
+		return
+	}
+
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.Repository.MarkNotificationRead(id, user.ID); err != nil {
+		fail(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
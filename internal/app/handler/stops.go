@@ -0,0 +1,91 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/ds"
+)
+
+// GetOrderStops - остановки маршрута заявки
+// @Summary Get route stops of logistic request
+// @Description Ordered pickup and drop-off stops with arrival windows and cargo loaded or unloaded
+// @Tags logistic-requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Route stops"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/logistic-requests/{id}/stops [get]
+func (h *Handler) GetOrderStops(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	stops, err := h.Repository.GetOrderStops(id)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"stops": stops})
+}
+
+// UpdateOrderStops - замена маршрута черновика списком остановок
+// @Summary Set route stops of logistic request
+// @Description Replace the route of a draft logistic request with ordered stops; an empty list returns it to a single from/to route. Lines are repriced segment by segment with capacity check
+// @Tags logistic-requests
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param If-Match header string true "Order version from ETag"
+// @Param request body map[string]interface{} true "Stops"
+// @Success 200 {object} map[string]interface{} "Route updated"
+// @Failure 400 {object} map[string]string "Invalid route"
+// @Failure 409 {object} map[string]string "Order is not a draft"
+// @Failure 412 {object} map[string]string "Order was modified by someone else"
+// @Failure 428 {object} map[string]string "If-Match header required"
+// @Router /api/logistic-requests/{id}/stops [put]
+func (h *Handler) UpdateOrderStops(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	var req struct {
+		Stops []ds.OrderStop `json:"stops"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	version, ok := ifMatchVersion(ctx)
+	if !ok {
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	if err := h.Repository.SetOrderStops(id, req.Stops, actor, version); err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	order, err := h.Repository.GetOrder(id)
+	if err != nil {
+		fail(ctx, http.StatusNotFound, "order not found")
+		return
+	}
+
+	ctx.Header("ETag", orderETag(order.Version))
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "order": order, "price": h.orderPrice(id)})
+}
@@ -0,0 +1,163 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/ds"
+)
+
+// PublicTrackingEvent - этап доставки в публичном представлении. Заметка водителя не публикуется:
+// в ней бывают имена, телефоны и адреса
+type PublicTrackingEvent struct {
+	Milestone  string    `json:"milestone"`
+	Location   string    `json:"location"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// PublicTracking - публичная информация об отправлении (без персональных данных клиента)
+type PublicTracking struct {
+	TrackingNumber     string                `json:"tracking_number"`
+	Status             string                `json:"status"`
+	FromCity           string                `json:"from_city"`
+	ToCity             string                `json:"to_city"`
+	OriginCountry      string                `json:"origin_country"`
+	DestinationCountry string                `json:"destination_country"`
+	TotalDays          int                   `json:"total_days"`
+	Events             []PublicTrackingEvent `json:"events"`
+}
+
+// AddTrackingEvent - отметка этапа доставки модератором или водителем
+// @Summary Post tracking milestone
+// @Description Post shipment milestone (picked_up, in_transit, arrived_hub, customs, out_for_delivery, delivered, exception) with location, time and note
+// @Tags tracking
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Param request body map[string]interface{} true "Milestone data"
+// @Success 201 {object} map[string]interface{} "Milestone recorded"
+// @Failure 400 {object} map[string]string "Invalid request"
+// @Failure 409 {object} map[string]string "Milestone not allowed in current status"
+// @Router /api/orders/{id}/tracking [post]
+func (h *Handler) AddTrackingEvent(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	var req struct {
+		Milestone  string     `json:"milestone" binding:"required"`
+		Location   string     `json:"location"`
+		OccurredAt *time.Time `json:"occurred_at"`
+		Note       string     `json:"note"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	event := ds.TrackingEvent{Milestone: req.Milestone, Location: req.Location, Note: req.Note}
+	if req.OccurredAt != nil {
+		event.OccurredAt = *req.OccurredAt
+	}
+
+	event, err = h.Repository.AddTrackingEvent(id, event, actor)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	ctx.JSON(http.StatusCreated, gin.H{"status": "ok", "event": event})
+}
+
+// GetOrderTracking - этапы доставки заявки для клиента и модератора
+func (h *Handler) GetOrderTracking(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	order, err := h.Repository.GetOrder(id)
+	if err != nil {
+		fail(ctx, http.StatusNotFound, "order not found")
+		return
+	}
+
+	events, err := h.Repository.GetTrackingEvents(id)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get tracking events")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{
+		"status":          "ok",
+		"tracking_number": order.TrackingNumber,
+		"order_status":    order.Status,
+		"events":          events,
+	})
+}
+
+// TrackShipment - публичное отслеживание по номеру (JSON или HTML-страница)
+// @Summary Track shipment
+// @Description Public shipment timeline by tracking number. Returns HTML when requested by a browser, JSON otherwise
+// @Tags tracking
+// @Produce json,html
+// @Param number path string true "Tracking number"
+// @Success 200 {object} handler.PublicTracking "Shipment timeline"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /track/{number} [get]
+func (h *Handler) TrackShipment(ctx *gin.Context) {
+	number := strings.ToUpper(strings.TrimSpace(ctx.Param("number")))
+	wantsHTML := ctx.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML
+
+	order, err := h.Repository.GetOrderByTrackingNumber(number)
+	if err != nil {
+		if wantsHTML {
+			ctx.HTML(http.StatusNotFound, "error.html", gin.H{"error": "Отправление не найдено"})
+		} else {
+			fail(ctx, http.StatusNotFound, "shipment not found")
+		}
+		return
+	}
+
+	events, err := h.Repository.GetTrackingEvents(order.ID)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get tracking events")
+		return
+	}
+
+	tracking := PublicTracking{
+		TrackingNumber:     number,
+		Status:             order.Status,
+		FromCity:           order.FromCity,
+		ToCity:             order.ToCity,
+		OriginCountry:      order.OriginCountry,
+		DestinationCountry: order.DestinationCountry,
+		TotalDays:          order.TotalDays,
+		Events:             make([]PublicTrackingEvent, 0, len(events)),
+	}
+	for _, e := range events {
+		tracking.Events = append(tracking.Events, PublicTrackingEvent{
+			Milestone:  e.Milestone,
+			Location:   e.Location,
+			OccurredAt: e.OccurredAt,
+		})
+	}
+
+	if wantsHTML {
+		ctx.HTML(http.StatusOK, "tracking.html", gin.H{"tracking": tracking})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "tracking": tracking})
+}
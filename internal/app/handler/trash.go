@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/repository"
+)
+
+// GetTrashedOrders - удалённые заявки в корзине
+// @Summary Get deleted logistic requests
+// @Description Deleted logistic requests with deletion date and the date they will be purged
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Deleted logistic requests"
+// @Router /api/trash/orders [get]
+func (h *Handler) GetTrashedOrders(ctx *gin.Context) {
+	items, err := h.Repository.GetTrashedOrders()
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get deleted orders")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "orders": items})
+}
+
+// RestoreOrder - восстановление заявки из корзины
+// @Summary Restore deleted logistic request
+// @Description Restore the logistic request to the status it had before deletion
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "Restored logistic request"
+// @Failure 404 {object} map[string]string "Not in trash"
+// @Router /api/trash/orders/{id}/restore [post]
+func (h *Handler) RestoreOrder(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	order, err := h.Repository.RestoreOrder(id, actor)
+	if errors.Is(err, repository.ErrNotInTrash) {
+		fail(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	ctx.Header("ETag", orderETag(order.Version))
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "order": order})
+}
+
+// GetTrashedServices - удалённые услуги в корзине
+// @Summary Get deleted transport types
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{} "Deleted transport types"
+// @Router /api/trash/services [get]
+func (h *Handler) GetTrashedServices(ctx *gin.Context) {
+	items, err := h.Repository.GetTrashedServices()
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get deleted services")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "services": items})
+}
+
+// RestoreService - восстановление услуги из корзины
+// @Summary Restore deleted transport type
+// @Tags trash
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Transport type ID"
+// @Success 200 {object} map[string]interface{} "Restored transport type"
+// @Failure 404 {object} map[string]string "Not in trash"
+// @Router /api/trash/services/{id}/restore [post]
+func (h *Handler) RestoreService(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid service id")
+		return
+	}
+
+	svc, err := h.Repository.RestoreService(id)
+	if errors.Is(err, repository.ErrNotInTrash) {
+		fail(ctx, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to restore service")
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "service": svc})
+}
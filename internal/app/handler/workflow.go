@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/middleware"
+	"rip-go-app/internal/app/repository"
+	"rip-go-app/internal/app/workflow"
+)
+
+// currentUser - пользователь из контекста авторизации
+func (h *Handler) currentUser(ctx *gin.Context) (ds.User, bool) {
+	if cached, exists := ctx.Get(ctxCurrentUser); exists {
+		if user, ok := cached.(ds.User); ok {
+			return user, true
+		}
+	}
+
+	userUUID, exists := middleware.GetUserUUID(ctx)
+	if !exists {
+		fail(ctx, http.StatusUnauthorized, "authentication required")
+		return ds.User{}, false
+	}
+
+	user, err := h.Repository.GetUserByUUID(userUUID)
+	if err != nil {
+		fail(ctx, http.StatusUnauthorized, "user not found")
+		return ds.User{}, false
+	}
+	ctx.Set(ctxCurrentUser, user)
+	return user, true
+}
+
+// currentActor - инициатор перехода для машины состояний заявки
+func (h *Handler) currentActor(ctx *gin.Context) (workflow.Actor, bool) {
+	user, ok := h.currentUser(ctx)
+	if !ok {
+		return workflow.Actor{}, false
+	}
+	return workflow.Actor{UserID: user.ID, Role: user.Role}, true
+}
+
+// failOrder - единое отображение ошибок операций над заявкой в HTTP-коды
+func failOrder(ctx *gin.Context, err error) {
+	var transitionErr *workflow.TransitionError
+	switch {
+	case errors.Is(err, repository.ErrOrderNotFound):
+		fail(ctx, http.StatusNotFound, "order not found")
+	case errors.Is(err, repository.ErrLineNotFound):
+		fail(ctx, http.StatusNotFound, err.Error())
+	case errors.Is(err, repository.ErrVersionConflict):
+		fail(ctx, http.StatusPreconditionFailed, err.Error())
+	case errors.Is(err, workflow.ErrForbidden):
+		fail(ctx, http.StatusForbidden, err.Error())
+	case errors.As(err, &transitionErr), errors.Is(err, repository.ErrTrackingNotActive),
+		errors.Is(err, repository.ErrOrderClaimed), errors.Is(err, repository.ErrNotInQueue),
+		errors.Is(err, repository.ErrLinesUndecided), errors.Is(err, repository.ErrReturnNotAllowed),
+		errors.Is(err, repository.ErrNotDraft), errors.Is(err, repository.ErrAmbiguousLine):
+		fail(ctx, http.StatusConflict, err.Error())
+	default:
+		fail(ctx, http.StatusBadRequest, err.Error())
+	}
+}
+
+// ifMatchVersion - версия заявки из заголовка If-Match ("3" или W/"3").
+// Без заголовка изменение заявки запрещено (428), чтобы не затереть чужие правки
+func ifMatchVersion(ctx *gin.Context) (int, bool) {
+	header := strings.TrimSpace(ctx.GetHeader("If-Match"))
+	if header == "" {
+		fail(ctx, http.StatusPreconditionRequired, "If-Match header with order version is required")
+		return 0, false
+	}
+
+	tag := strings.Trim(strings.TrimPrefix(header, "W/"), `"`)
+	version, err := strconv.Atoi(tag)
+	if err != nil || version <= 0 {
+		fail(ctx, http.StatusBadRequest, "invalid If-Match header")
+		return 0, false
+	}
+	return version, true
+}
+
+// setOrderETag - текущая версия заявки в заголовке ETag
+func (h *Handler) setOrderETag(ctx *gin.Context, orderID int) {
+	if version, err := h.Repository.GetOrderVersion(orderID); err == nil {
+		ctx.Header("ETag", orderETag(version))
+	}
+}
+
+// orderETag - значение ETag для версии заявки
+func orderETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// GetOrderHistory - журнал изменений заявки
+// @Summary Get logistic request history
+// @Description Get audit trail of logistic request: status transitions and field edits with actor, time and reason
+// @Tags logistic-requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 200 {object} map[string]interface{} "History retrieved"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/orders/{id}/history [get]
+func (h *Handler) GetOrderHistory(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	if _, err := h.Repository.GetOrder(id); err != nil {
+		fail(ctx, http.StatusNotFound, "order not found")
+		return
+	}
+
+	events, err := h.Repository.GetOrderHistory(id)
+	if err != nil {
+		fail(ctx, http.StatusInternalServerError, "failed to get order history")
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gin.H{"status": "ok", "history": events})
+}
+
+// CloneOrder - копирование заявки в новый черновик текущего пользователя
+// @Summary Clone logistic request
+// @Description Copy route, cargo, customs items and services (quantities, comments, ordering) into a new draft of the current user. Prices are recalculated with current tariffs; the response reports the difference from the original, per order and per line (original and current cost and days)
+// @Tags logistic-requests
+// @Produce json
+// @Security BearerAuth
+// @Param id path int true "Logistic request ID"
+// @Success 201 {object} map[string]interface{} "Draft created"
+// @Failure 404 {object} map[string]string "Not found"
+// @Router /api/orders/{id}/clone [post]
+func (h *Handler) CloneOrder(ctx *gin.Context) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if err != nil {
+		fail(ctx, http.StatusBadRequest, "invalid order id")
+		return
+	}
+
+	actor, ok := h.currentActor(ctx)
+	if !ok {
+		return
+	}
+
+	order, diff, err := h.Repository.CloneOrder(id, actor)
+	if err != nil {
+		failOrder(ctx, err)
+		return
+	}
+
+	ctx.Header("ETag", orderETag(order.Version))
+	ctx.JSON(http.StatusCreated, gin.H{"status": "ok", "order": order, "diff": diff})
+}
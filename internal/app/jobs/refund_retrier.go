@@ -0,0 +1,68 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/repository"
+	"rip-go-app/internal/app/service"
+	"rip-go-app/internal/app/workflow"
+)
+
+// RefundRetrier - повтор возвратов по отменённым заявкам, которые не удалось выполнить сразу
+type RefundRetrier struct {
+	Repository *repository.Repository
+	Payments   *service.PaymentService
+	Interval   time.Duration
+}
+
+// NewRefundRetrier - создание повтора возвратов с периодом interval (по умолчанию 10 минут)
+func NewRefundRetrier(repo *repository.Repository, payments *service.PaymentService, interval time.Duration) *RefundRetrier {
+	if interval <= 0 {
+		interval = 10 * time.Minute
+	}
+	return &RefundRetrier{Repository: repo, Payments: payments, Interval: interval}
+}
+
+// Run - периодический повтор до отмены контекста
+func (r *RefundRetrier) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *RefundRetrier) run(ctx context.Context) {
+	orderIDs, err := r.Repository.GetPendingRefundOrders()
+	if err != nil {
+		logrus.Errorf("pending refunds: %v", err)
+		return
+	}
+
+	refunded := 0
+	for _, id := range orderIDs {
+		if ctx.Err() != nil {
+			return
+		}
+		cancellation, err := r.Payments.SettleCancellation(ctx, id, workflow.Actor{})
+		if err != nil {
+			logrus.Warnf("cancellation refund for order %d: %v", id, err)
+			continue
+		}
+		if cancellation.RefundStatus == ds.RefundComplete {
+			refunded++
+		}
+	}
+	if refunded > 0 {
+		logrus.Infof("refunds: %d cancellations refunded", refunded)
+	}
+}
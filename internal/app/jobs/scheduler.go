@@ -0,0 +1,48 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"rip-go-app/internal/app/repository"
+)
+
+// Scheduler - создание заявок по расписаниям регулярных отправок
+type Scheduler struct {
+	Repository *repository.Repository
+	Interval   time.Duration
+}
+
+// NewScheduler - создание планировщика с периодом interval (по умолчанию 5 минут)
+func NewScheduler(repo *repository.Repository, interval time.Duration) *Scheduler {
+	if interval <= 0 {
+		interval = 5 * time.Minute
+	}
+	return &Scheduler{Repository: repo, Interval: interval}
+}
+
+// Run - периодический запуск до отмены контекста
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.Interval)
+	defer ticker.Stop()
+
+	for {
+		s.run()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *Scheduler) run() {
+	created, err := s.Repository.RunSchedules(time.Now())
+	if err != nil {
+		logrus.Errorf("schedules run failed: %v", err)
+	}
+	if created > 0 {
+		logrus.Infof("schedules: %d orders created", created)
+	}
+}
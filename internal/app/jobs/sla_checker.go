@@ -0,0 +1,49 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"rip-go-app/internal/app/repository"
+)
+
+// SLAChecker - фоновая проверка сроков SLA сформированных заявок
+type SLAChecker struct {
+	Repository *repository.Repository
+	Interval   time.Duration
+}
+
+// NewSLAChecker - создание проверки с периодом interval (по умолчанию минута)
+func NewSLAChecker(repo *repository.Repository, interval time.Duration) *SLAChecker {
+	if interval <= 0 {
+		interval = time.Minute
+	}
+	return &SLAChecker{Repository: repo, Interval: interval}
+}
+
+// Run - периодическая проверка до отмены контекста
+func (c *SLAChecker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.Interval)
+	defer ticker.Stop()
+
+	for {
+		c.check()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (c *SLAChecker) check() {
+	result, err := c.Repository.CheckSLA(time.Now())
+	if err != nil {
+		logrus.Errorf("sla check failed: %v", err)
+		return
+	}
+	if result.Warned > 0 || result.Breached > 0 {
+		logrus.Infof("sla check: %d warned, %d breached", result.Warned, result.Breached)
+	}
+}
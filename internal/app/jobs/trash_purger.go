@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	"rip-go-app/internal/app/repository"
+	"rip-go-app/internal/app/storage"
+)
+
+// TrashPurger - окончательное удаление заявок и услуг, срок хранения которых в корзине истёк
+type TrashPurger struct {
+	Repository *repository.Repository
+	Blobs      storage.Store // файлы удалённых заявок; nil - файлы не удаляются
+	Interval   time.Duration
+}
+
+// NewTrashPurger - создание очистки корзины с периодом interval (по умолчанию час)
+func NewTrashPurger(repo *repository.Repository, blobs storage.Store, interval time.Duration) *TrashPurger {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	return &TrashPurger{Repository: repo, Blobs: blobs, Interval: interval}
+}
+
+// Run - периодическая очистка до отмены контекста
+func (p *TrashPurger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.Interval)
+	defer ticker.Stop()
+
+	for {
+		p.run(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (p *TrashPurger) run(ctx context.Context) {
+	result, err := p.Repository.PurgeTrash(time.Now())
+	if err != nil {
+		logrus.Errorf("trash purge failed: %v", err)
+	}
+
+	if p.Blobs != nil {
+		for _, key := range result.StorageKeys {
+			if err := p.Blobs.Delete(ctx, key); err != nil {
+				logrus.Errorf("orphan file %s: %v", key, err)
+			}
+		}
+	}
+	if result.Orders > 0 || result.Services > 0 {
+		logrus.Infof("trash purged: %d orders, %d services", result.Orders, result.Services)
+	}
+}
@@ -0,0 +1,94 @@
+package payments
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/google/uuid"
+	"rip-go-app/internal/app/ds"
+)
+
+// MockSignatureHeader - заголовок с подписью уведомлений тестового провайдера
+const MockSignatureHeader = "X-Mock-Signature"
+
+// Mock - тестовый провайдер для разработки: платёж сразу авторизуется,
+// списание и возврат всегда успешны, уведомления подписываются HMAC-SHA256
+type Mock struct {
+	secret []byte
+
+	mu          sync.Mutex
+	idempotency map[string]Result
+}
+
+// NewMock - тестовый провайдер с ключом подписи уведомлений secret
+func NewMock(secret string) *Mock {
+	return &Mock{secret: []byte(secret), idempotency: map[string]Result{}}
+}
+
+// Name - код провайдера
+func (m *Mock) Name() string {
+	return "mock"
+}
+
+// Create - платёж в статусе authorized
+func (m *Mock) Create(ctx context.Context, req CreateRequest) (Result, error) {
+	if req.Amount <= 0 {
+		return Result{}, fmt.Errorf("сумма платежа должна быть положительной")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if req.IdempotencyKey != "" {
+		if res, ok := m.idempotency[req.IdempotencyKey]; ok {
+			return res, nil
+		}
+	}
+
+	res := Result{ExternalID: "mock_" + uuid.NewString(), Status: ds.PaymentAuthorized, Amount: req.Amount}
+	if req.IdempotencyKey != "" {
+		m.idempotency[req.IdempotencyKey] = res
+	}
+	return res, nil
+}
+
+// Capture - списание
+func (m *Mock) Capture(ctx context.Context, externalID string, amount float64) (Result, error) {
+	return Result{ExternalID: externalID, Status: ds.PaymentPaid, Amount: amount}, nil
+}
+
+// Refund - возврат; статус итогового платежа определяет вызывающий по сумме возвратов
+func (m *Mock) Refund(ctx context.Context, externalID string, amount float64) (Result, error) {
+	if amount <= 0 {
+		return Result{}, fmt.Errorf("сумма возврата должна быть положительной")
+	}
+	return Result{ExternalID: externalID, Status: ds.PaymentRefunded, Amount: amount}, nil
+}
+
+// ParseWebhook - уведомление вида {"id": "...", "status": "...", "amount": 0}
+func (m *Mock) ParseWebhook(body []byte, header http.Header) (Result, error) {
+	if !hmac.Equal([]byte(m.Sign(body)), []byte(header.Get(MockSignatureHeader))) {
+		return Result{}, ErrInvalidSignature
+	}
+
+	var res Result
+	if err := json.Unmarshal(body, &res); err != nil {
+		return Result{}, fmt.Errorf("некорректное уведомление: %w", err)
+	}
+	if res.ExternalID == "" || res.Status == "" {
+		return Result{}, fmt.Errorf("в уведомлении нет id или status")
+	}
+	return res, nil
+}
+
+// Sign - подпись тела уведомления (для отправки тестовых уведомлений)
+func (m *Mock) Sign(body []byte) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
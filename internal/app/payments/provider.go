@@ -0,0 +1,42 @@
+package payments
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+// ErrInvalidSignature - подпись уведомления провайдера неверна
+var ErrInvalidSignature = errors.New("неверная подпись уведомления")
+
+// Provider - платёжный провайдер. Состояние платежа хранит приложение,
+// провайдер лишь выполняет операции и сообщает их результат
+type Provider interface {
+	// Name - код провайдера, сохраняется в платеже
+	Name() string
+	// Create - новый платёж; повтор с тем же ключом идемпотентности возвращает тот же платёж
+	Create(ctx context.Context, req CreateRequest) (Result, error)
+	// Capture - списание ранее заблокированной суммы
+	Capture(ctx context.Context, externalID string, amount float64) (Result, error)
+	// Refund - возврат (в том числе частичный) списанной суммы
+	Refund(ctx context.Context, externalID string, amount float64) (Result, error)
+	// ParseWebhook - проверка подписи и разбор уведомления об изменении платежа
+	ParseWebhook(body []byte, header http.Header) (Result, error)
+}
+
+// CreateRequest - параметры нового платежа
+type CreateRequest struct {
+	OrderID        int
+	Amount         float64
+	Currency       string
+	Description    string
+	IdempotencyKey string
+}
+
+// Result - состояние платежа у провайдера после операции
+type Result struct {
+	ExternalID      string  `json:"id"`
+	Status          string  `json:"status"` // статусы ds.Payment*
+	Amount          float64 `json:"amount"` // сумма операции: платежа, списания или возврата
+	ConfirmationURL string  `json:"confirmation_url,omitempty"`
+}
@@ -0,0 +1,266 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"sort"
+	"strings"
+	"unicode/utf16"
+)
+
+// Размер страницы A4 в пунктах
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Document - PDF-документ с одним встроенным шрифтом. Координаты отсчитываются
+// от левого верхнего угла страницы, ось Y направлена вниз
+type Document struct {
+	Title string
+
+	font  *Font
+	pages []*Page
+	used  map[uint16]rune // использованные глифы для таблиц ширин и ToUnicode
+}
+
+// Page - страница документа
+type Page struct {
+	doc     *Document
+	content bytes.Buffer
+}
+
+// New - пустой документ со шрифтом font
+func New(font *Font) *Document {
+	return &Document{font: font, used: map[uint16]rune{}}
+}
+
+// Font - шрифт документа
+func (d *Document) Font() *Font {
+	return d.font
+}
+
+// AddPage - новая страница в конце документа
+func (d *Document) AddPage() *Page {
+	p := &Page{doc: d}
+	d.pages = append(d.pages, p)
+	return p
+}
+
+// Text - строка текста; y - базовая линия
+func (p *Page) Text(x, y, size float64, s string) {
+	p.text(x, y, size, s, false)
+}
+
+// BoldText - полужирный текст (обводка контура глифов)
+func (p *Page) BoldText(x, y, size float64, s string) {
+	p.text(x, y, size, s, true)
+}
+
+// TextRight - текст, выровненный по правому краю right
+func (p *Page) TextRight(right, y, size float64, s string) {
+	p.text(right-p.doc.font.Width(s, size), y, size, s, false)
+}
+
+func (p *Page) text(x, y, size float64, s string, bold bool) {
+	if s == "" {
+		return
+	}
+
+	var hex strings.Builder
+	for _, r := range s {
+		g := p.doc.font.glyph(r)
+		if _, ok := p.doc.used[g]; !ok && g != 0 {
+			p.doc.used[g] = r
+		}
+		fmt.Fprintf(&hex, "%04X", g)
+	}
+
+	mode := 0
+	if bold {
+		mode = 2
+	}
+	fmt.Fprintf(&p.content, "BT /F1 %s Tf %d Tr %s w %s %s Td <%s> Tj ET\n",
+		num(size), mode, num(size/30), num(x), num(PageHeight-y), hex.String())
+}
+
+// Line - отрезок толщиной width
+func (p *Page) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s m %s %s l S\n",
+		num(width), num(x1), num(PageHeight-y1), num(x2), num(PageHeight-y2))
+}
+
+// Rect - рамка с левым верхним углом (x, y)
+func (p *Page) Rect(x, y, w, h, width float64) {
+	fmt.Fprintf(&p.content, "%s w %s %s %s %s re S\n",
+		num(width), num(x), num(PageHeight-y-h), num(w), num(h))
+}
+
+// Bytes - сериализация документа. Результат детерминирован: одинаковое содержимое даёт одинаковые байты
+func (d *Document) Bytes() ([]byte, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	w := &writer{}
+	w.buf.WriteString("%PDF-1.4\n%\xE2\xE3\xCF\xD3\n")
+
+	// Номера объектов: 1 - каталог, 2 - дерево страниц, 3-7 - шрифт, 8 - сведения, далее страницы
+	const catalog, pagesObj, fontObj, cidObj, descObj, fileObj, cmapObj, infoObj = 1, 2, 3, 4, 5, 6, 7, 8
+	firstPage := 9
+
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+i*2)
+	}
+
+	w.object(catalog, fmt.Sprintf("<< /Type /Catalog /Pages %d 0 R >>", pagesObj))
+	w.object(pagesObj, fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	f := d.font
+	w.object(fontObj, fmt.Sprintf("<< /Type /Font /Subtype /Type0 /BaseFont /%s /Encoding /Identity-H /DescendantFonts [%d 0 R] /ToUnicode %d 0 R >>",
+		f.Name, cidObj, cmapObj))
+	w.object(cidObj, fmt.Sprintf("<< /Type /Font /Subtype /CIDFontType2 /BaseFont /%s /CIDSystemInfo << /Registry (Adobe) /Ordering (Identity) /Supplement 0 >> /FontDescriptor %d 0 R /W [%s] /CIDToGIDMap /Identity >>",
+		f.Name, descObj, d.widths()))
+	w.object(descObj, fmt.Sprintf("<< /Type /FontDescriptor /FontName /%s /Flags 32 /FontBBox [%d %d %d %d] /ItalicAngle 0 /Ascent %d /Descent %d /CapHeight %d /StemV 80 /FontFile2 %d 0 R >>",
+		f.Name, f.scale(f.bbox[0]), f.scale(f.bbox[1]), f.scale(f.bbox[2]), f.scale(f.bbox[3]),
+		f.scale(f.ascent), f.scale(f.descent), f.scale(f.capHeight), fileObj))
+	if err := w.stream(fileObj, fmt.Sprintf("/Length1 %d", len(f.data)), f.data); err != nil {
+		return nil, err
+	}
+	if err := w.stream(cmapObj, "", []byte(d.toUnicode())); err != nil {
+		return nil, err
+	}
+	w.object(infoObj, fmt.Sprintf("<< /Title %s /Producer (rip-go-app) >>", textString(d.Title)))
+
+	for i, p := range d.pages {
+		pageObj, contentObj := firstPage+i*2, firstPage+i*2+1
+		w.object(pageObj, fmt.Sprintf("<< /Type /Page /Parent %d 0 R /MediaBox [0 0 %s %s] /Resources << /Font << /F1 %d 0 R >> >> /Contents %d 0 R >>",
+			pagesObj, num(PageWidth), num(PageHeight), fontObj, contentObj))
+		if err := w.stream(contentObj, "", p.content.Bytes()); err != nil {
+			return nil, err
+		}
+	}
+
+	w.finish(catalog, infoObj)
+	return w.buf.Bytes(), nil
+}
+
+// sortedGlyphs - использованные глифы по возрастанию номера
+func (d *Document) sortedGlyphs() []uint16 {
+	glyphs := make([]uint16, 0, len(d.used))
+	for g := range d.used {
+		glyphs = append(glyphs, g)
+	}
+	sort.Slice(glyphs, func(i, j int) bool { return glyphs[i] < glyphs[j] })
+	return glyphs
+}
+
+// widths - массив ширин /W для использованных глифов
+func (d *Document) widths() string {
+	var b strings.Builder
+	for _, g := range d.sortedGlyphs() {
+		fmt.Fprintf(&b, "%d [%d] ", g, d.font.advance(g))
+	}
+	return strings.TrimSpace(b.String())
+}
+
+// toUnicode - таблица соответствия глифов символам для поиска и копирования текста
+func (d *Document) toUnicode() string {
+	var b strings.Builder
+	b.WriteString("/CIDInit /ProcSet findresource begin\n12 dict begin\nbegincmap\n")
+	b.WriteString("/CIDSystemInfo << /Registry (Adobe) /Ordering (UCS) /Supplement 0 >> def\n")
+	b.WriteString("/CMapName /Adobe-Identity-UCS def\n/CMapType 2 def\n")
+	b.WriteString("1 begincodespacerange\n<0000> <FFFF>\nendcodespacerange\n")
+
+	glyphs := d.sortedGlyphs()
+	for start := 0; start < len(glyphs); start += 100 {
+		end := start + 100
+		if end > len(glyphs) {
+			end = len(glyphs)
+		}
+		fmt.Fprintf(&b, "%d beginbfchar\n", end-start)
+		for _, g := range glyphs[start:end] {
+			b.WriteString(fmt.Sprintf("<%04X> <", g))
+			for _, u := range utf16.Encode([]rune{d.used[g]}) {
+				fmt.Fprintf(&b, "%04X", u)
+			}
+			b.WriteString(">\n")
+		}
+		b.WriteString("endbfchar\n")
+	}
+
+	b.WriteString("endcmap\nCMapName currentdict /CMap defineresource pop\nend\nend\n")
+	return b.String()
+}
+
+// writer - запись объектов с учётом смещений для таблицы xref
+type writer struct {
+	buf     bytes.Buffer
+	offsets []int
+}
+
+func (w *writer) begin(id int) {
+	for len(w.offsets) < id {
+		w.offsets = append(w.offsets, 0)
+	}
+	w.offsets[id-1] = w.buf.Len()
+	fmt.Fprintf(&w.buf, "%d 0 obj\n", id)
+}
+
+func (w *writer) object(id int, body string) {
+	w.begin(id)
+	w.buf.WriteString(body)
+	w.buf.WriteString("\nendobj\n")
+}
+
+// stream - объект-поток, сжатый Flate
+func (w *writer) stream(id int, extra string, data []byte) error {
+	var compressed bytes.Buffer
+	zw := zlib.NewWriter(&compressed)
+	if _, err := zw.Write(data); err != nil {
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	w.begin(id)
+	fmt.Fprintf(&w.buf, "<< /Length %d /Filter /FlateDecode %s>>\nstream\n", compressed.Len(), extra)
+	w.buf.Write(compressed.Bytes())
+	w.buf.WriteString("\nendstream\nendobj\n")
+	return nil
+}
+
+// finish - таблица xref и трейлер
+func (w *writer) finish(root, info int) {
+	xref := w.buf.Len()
+	fmt.Fprintf(&w.buf, "xref\n0 %d\n0000000000 65535 f \n", len(w.offsets)+1)
+	for _, off := range w.offsets {
+		fmt.Fprintf(&w.buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&w.buf, "trailer\n<< /Size %d /Root %d 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n",
+		len(w.offsets)+1, root, info, xref)
+}
+
+// num - число без лишних нулей
+func num(v float64) string {
+	s := fmt.Sprintf("%.2f", v)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "-0" || s == "" {
+		return "0"
+	}
+	return s
+}
+
+// textString - строка PDF в UTF-16BE (для заголовка документа)
+func textString(s string) string {
+	var b strings.Builder
+	b.WriteString("<FEFF")
+	for _, u := range utf16.Encode([]rune(s)) {
+		fmt.Fprintf(&b, "%04X", u)
+	}
+	b.WriteString(">")
+	return b.String()
+}
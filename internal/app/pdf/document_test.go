@@ -0,0 +1,170 @@
+package pdf
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testDocument(t *testing.T) []byte {
+	t.Helper()
+	doc := New(testFont(t))
+	doc.Title = "Счёт №1"
+	page := doc.AddPage()
+	page.Text(40, 40, 12, "Бя A")
+	page.BoldText(40, 60, 12, "А")
+	page.Rect(40, 80, 100, 20, 0.5)
+	doc.AddPage().TextRight(500, 40, 10, "я")
+
+	data, err := doc.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	return data
+}
+
+// xrefEntries - смещения объектов из таблицы xref по startxref
+func xrefEntries(t *testing.T, data []byte) (offsets []int, trailer string) {
+	t.Helper()
+	if !bytes.HasPrefix(data, []byte("%PDF-1.4\n")) {
+		t.Fatalf("missing PDF header")
+	}
+	tail := regexp.MustCompile(`startxref\n(\d+)\n%%EOF\n$`).FindSubmatch(data)
+	if tail == nil {
+		t.Fatalf("missing startxref")
+	}
+	start, _ := strconv.Atoi(string(tail[1]))
+	if !bytes.HasPrefix(data[start:], []byte("xref\n")) {
+		t.Fatalf("startxref %d does not point to xref", start)
+	}
+
+	lines := strings.Split(string(data[start:]), "\n")
+	var first, count int
+	if _, err := fmt.Sscanf(lines[1], "%d %d", &first, &count); err != nil || first != 0 {
+		t.Fatalf("bad xref subsection %q", lines[1])
+	}
+	if lines[2] != "0000000000 65535 f " {
+		t.Errorf("bad free entry %q", lines[2])
+	}
+	for i := 1; i < count; i++ {
+		entry := lines[2+i]
+		if len(entry) != 19 || !strings.HasSuffix(entry, " 00000 n ") {
+			t.Fatalf("bad xref entry %q", entry)
+		}
+		off, _ := strconv.Atoi(entry[:10])
+		offsets = append(offsets, off)
+	}
+	if lines[2+count] != "trailer" {
+		t.Fatalf("missing trailer after %d xref entries", count)
+	}
+	trailer = lines[3+count]
+	if want := fmt.Sprintf("/Size %d ", count); !strings.Contains(trailer, want) {
+		t.Errorf("trailer %q, want %s", trailer, want)
+	}
+	return offsets, trailer
+}
+
+func TestDocumentXref(t *testing.T) {
+	data := testDocument(t)
+	offsets, trailer := xrefEntries(t, data)
+
+	// 8 служебных объектов и по два объекта на каждую из двух страниц
+	if len(offsets) != 12 {
+		t.Fatalf("xref has %d objects, want 12", len(offsets))
+	}
+	for i, off := range offsets {
+		want := fmt.Sprintf("%d 0 obj\n", i+1)
+		if off <= 0 || off >= len(data) || !bytes.HasPrefix(data[off:], []byte(want)) {
+			t.Errorf("object %d: offset %d does not point to %q", i+1, off, want)
+		}
+	}
+	if !strings.Contains(trailer, "/Root 1 0 R") || !strings.Contains(trailer, "/Info 8 0 R") {
+		t.Errorf("trailer %q", trailer)
+	}
+	if !bytes.Contains(data, []byte("/Count 2")) {
+		t.Errorf("page tree does not count two pages")
+	}
+}
+
+// objectStream - распакованный поток объекта id
+func objectStream(t *testing.T, data []byte, id int) string {
+	t.Helper()
+	offsets, _ := xrefEntries(t, data)
+	obj := data[offsets[id-1]:]
+	m := regexp.MustCompile(`^\d+ 0 obj\n<< /Length (\d+) [^>]*>>\nstream\n`).FindSubmatch(obj)
+	if m == nil {
+		t.Fatalf("object %d is not a stream", id)
+	}
+	length, _ := strconv.Atoi(string(m[1]))
+	body := obj[len(m[0]) : len(m[0])+length]
+	if !bytes.HasPrefix(obj[len(m[0])+length:], []byte("\nendstream\nendobj\n")) {
+		t.Fatalf("object %d: /Length %d does not match stream", id, length)
+	}
+	zr, err := zlib.NewReader(bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("object %d: %v", id, err)
+	}
+	out, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("object %d: %v", id, err)
+	}
+	return string(out)
+}
+
+func TestDocumentFont(t *testing.T) {
+	data := testDocument(t)
+
+	// Ширины только использованных глифов в единицах 1/1000 кегля
+	if !bytes.Contains(data, []byte("/W [1 [600] 2 [700] 3 [550] 4 [250] 5 [250]]")) {
+		t.Errorf("unexpected /W array")
+	}
+
+	// ToUnicode: глифы обратно в символы для копирования кириллицы
+	cmap := objectStream(t, data, 7)
+	for _, want := range []string{"5 beginbfchar", "<0001> <0041>", "<0002> <0411>", "<0003> <044F>", "<0005> <0410>"} {
+		if !strings.Contains(cmap, want) {
+			t.Errorf("ToUnicode has no %q", want)
+		}
+	}
+
+	// Текст выводится номерами глифов, y отсчитывается от верха страницы
+	content := objectStream(t, data, 10)
+	if !strings.Contains(content, "40 801.89 Td <0002000300040001> Tj") {
+		t.Errorf("unexpected page content %q", content)
+	}
+	if !strings.Contains(content, "2 Tr") {
+		t.Errorf("bold text is not stroked")
+	}
+	if second := objectStream(t, data, 12); !strings.Contains(second, "494.5 801.89 Td <0003> Tj") {
+		t.Errorf("right-aligned text %q", second)
+	}
+}
+
+func TestDocumentDeterministic(t *testing.T) {
+	if !bytes.Equal(testDocument(t), testDocument(t)) {
+		t.Errorf("same content produced different bytes")
+	}
+}
+
+func TestDocumentEmpty(t *testing.T) {
+	data, err := New(testFont(t)).Bytes()
+	if err != nil {
+		t.Fatalf("Bytes: %v", err)
+	}
+	if offsets, _ := xrefEntries(t, data); len(offsets) != 10 {
+		t.Errorf("empty document has %d objects, want 10 (one blank page)", len(offsets))
+	}
+}
+
+func TestNum(t *testing.T) {
+	for v, want := range map[float64]string{0: "0", 1.5: "1.5", 2.004: "2", -0.001: "0", 841.89: "841.89", 10: "10"} {
+		if got := num(v); got != want {
+			t.Errorf("num(%v) = %q, want %q", v, got, want)
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package pdf
+
+import (
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+)
+
+// Font - шрифт TrueType для встраивания в документ (нужен для кириллицы)
+type Font struct {
+	Name string
+
+	data       []byte
+	unitsPerEm int
+	ascent     int
+	descent    int
+	capHeight  int
+	bbox       [4]int
+	advances   []int // ширина по номеру глифа
+	cmap       map[rune]uint16
+}
+
+// LoadFont - загрузка шрифта TrueType из файла
+func LoadFont(path string) (*Font, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := ParseFont(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	f.Name = strings.Map(func(r rune) rune {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return r
+		}
+		return -1
+	}, strings.TrimSuffix(filepath.Base(path), filepath.Ext(path)))
+	if f.Name == "" {
+		f.Name = "EmbeddedFont"
+	}
+	return f, nil
+}
+
+// ParseFont - разбор таблиц head, hhea, hmtx, OS/2 и cmap
+func ParseFont(data []byte) (*Font, error) {
+	tables, err := fontTables(data)
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range []string{"head", "hhea", "hmtx", "maxp", "cmap"} {
+		if tables[tag] == nil {
+			return nil, fmt.Errorf("в шрифте нет таблицы %s", tag)
+		}
+	}
+
+	head, hhea, maxp := tables["head"], tables["hhea"], tables["maxp"]
+	if len(head) < 54 || len(hhea) < 36 || len(maxp) < 6 {
+		return nil, fmt.Errorf("повреждённый шрифт")
+	}
+
+	f := &Font{
+		data:       data,
+		unitsPerEm: int(u16(head, 18)),
+		bbox:       [4]int{int(i16(head, 36)), int(i16(head, 38)), int(i16(head, 40)), int(i16(head, 42))},
+		ascent:     int(i16(hhea, 4)),
+		descent:    int(i16(hhea, 6)),
+	}
+	if f.unitsPerEm == 0 {
+		return nil, fmt.Errorf("повреждённый шрифт")
+	}
+	f.capHeight = f.ascent
+	if os2 := tables["OS/2"]; len(os2) >= 90 && u16(os2, 0) >= 2 {
+		f.capHeight = int(i16(os2, 88))
+	}
+
+	numGlyphs := int(u16(maxp, 4))
+	numMetrics := int(u16(hhea, 34))
+	hmtx := tables["hmtx"]
+	if numMetrics == 0 || len(hmtx) < numMetrics*4 {
+		return nil, fmt.Errorf("повреждённая таблица hmtx")
+	}
+	f.advances = make([]int, numGlyphs)
+	for g := range f.advances {
+		if g < numMetrics {
+			f.advances[g] = int(u16(hmtx, g*4))
+		} else {
+			f.advances[g] = f.advances[numMetrics-1]
+		}
+	}
+
+	if f.cmap, err = parseCmap(tables["cmap"]); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// fontTables - таблицы шрифта по тегам
+func fontTables(data []byte) (map[string][]byte, error) {
+	if len(data) < 12 {
+		return nil, fmt.Errorf("файл не является шрифтом TrueType")
+	}
+	if v := binary.BigEndian.Uint32(data); v != 0x00010000 && v != 0x74727565 {
+		return nil, fmt.Errorf("поддерживаются только шрифты TrueType")
+	}
+
+	n := int(u16(data, 4))
+	tables := make(map[string][]byte, n)
+	for i := 0; i < n; i++ {
+		rec := 12 + i*16
+		if rec+16 > len(data) {
+			return nil, fmt.Errorf("повреждённый каталог таблиц")
+		}
+		offset, length := int(u32(data, rec+8)), int(u32(data, rec+12))
+		if offset+length > len(data) {
+			return nil, fmt.Errorf("повреждённый каталог таблиц")
+		}
+		tables[string(data[rec:rec+4])] = data[offset : offset+length]
+	}
+	return tables, nil
+}
+
+// parseCmap - соответствие символов глифам (Unicode, форматы 4 и 12)
+func parseCmap(cmap []byte) (map[rune]uint16, error) {
+	if len(cmap) < 4 {
+		return nil, fmt.Errorf("повреждённая таблица cmap")
+	}
+
+	var format4, format12 []byte
+	n := int(u16(cmap, 2))
+	for i := 0; i < n; i++ {
+		rec := 4 + i*8
+		if rec+8 > len(cmap) {
+			break
+		}
+		platform, encoding, offset := u16(cmap, rec), u16(cmap, rec+2), int(u32(cmap, rec+4))
+		if offset+4 > len(cmap) {
+			continue
+		}
+		unicodeTable := platform == 0 || (platform == 3 && (encoding == 1 || encoding == 10))
+		if !unicodeTable {
+			continue
+		}
+		switch u16(cmap, offset) {
+		case 4:
+			format4 = cmap[offset:]
+		case 12:
+			format12 = cmap[offset:]
+		}
+	}
+
+	m := make(map[rune]uint16)
+	switch {
+	case format12 != nil && len(format12) >= 16:
+		groups := int(u32(format12, 12))
+		for i := 0; i < groups && 16+i*12+12 <= len(format12); i++ {
+			g := 16 + i*12
+			start, end, glyph := u32(format12, g), u32(format12, g+4), u32(format12, g+8)
+			for c := start; c <= end && c <= unicode.MaxRune; c++ {
+				m[rune(c)] = uint16(glyph + c - start)
+			}
+		}
+	case format4 != nil && len(format4) >= 14:
+		segs := int(u16(format4, 6)) / 2
+		ends, starts := 14, 16+segs*2
+		deltas, ranges := starts+segs*2, starts+segs*4
+		if ranges+segs*2 > len(format4) {
+			return nil, fmt.Errorf("повреждённая таблица cmap")
+		}
+		for s := 0; s < segs; s++ {
+			start, end := int(u16(format4, starts+s*2)), int(u16(format4, ends+s*2))
+			delta, rangeOffset := int(u16(format4, deltas+s*2)), int(u16(format4, ranges+s*2))
+			for c := start; c <= end && c != 0xFFFF; c++ {
+				var g int
+				if rangeOffset == 0 {
+					g = (c + delta) & 0xFFFF
+				} else {
+					addr := ranges + s*2 + rangeOffset + (c-start)*2
+					if addr+2 > len(format4) {
+						continue
+					}
+					if g = int(u16(format4, addr)); g != 0 {
+						g = (g + delta) & 0xFFFF
+					}
+				}
+				if g != 0 {
+					m[rune(c)] = uint16(g)
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("в шрифте нет таблицы символов Unicode")
+	}
+	return m, nil
+}
+
+// glyph - номер глифа символа (0 - нет в шрифте)
+func (f *Font) glyph(r rune) uint16 {
+	return f.cmap[r]
+}
+
+// advance - ширина глифа в единицах 1/1000 кегля
+func (f *Font) advance(g uint16) int {
+	if int(g) >= len(f.advances) {
+		return 0
+	}
+	return f.advances[g] * 1000 / f.unitsPerEm
+}
+
+// scale - перевод единиц шрифта в 1/1000 кегля
+func (f *Font) scale(v int) int {
+	return v * 1000 / f.unitsPerEm
+}
+
+// Width - ширина строки в пунктах при кегле size
+func (f *Font) Width(s string, size float64) float64 {
+	total := 0
+	for _, r := range s {
+		total += f.advance(f.glyph(r))
+	}
+	return float64(total) * size / 1000
+}
+
+// Wrap - перенос текста по словам в строки шириной не более width
+func (f *Font) Wrap(s string, size, width float64) []string {
+	var lines []string
+	for _, paragraph := range strings.Split(s, "\n") {
+		line := ""
+		for _, word := range strings.Fields(paragraph) {
+			candidate := word
+			if line != "" {
+				candidate = line + " " + word
+			}
+			if line != "" && f.Width(candidate, size) > width {
+				lines = append(lines, line)
+				candidate = word
+			}
+			line = candidate
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func u16(b []byte, off int) uint16 { return binary.BigEndian.Uint16(b[off:]) }
+func i16(b []byte, off int) int16  { return int16(binary.BigEndian.Uint16(b[off:])) }
+func u32(b []byte, off int) uint32 { return binary.BigEndian.Uint32(b[off:]) }
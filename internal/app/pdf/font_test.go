@@ -0,0 +1,255 @@
+package pdf
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// Глифы тестового шрифта: 0 - .notdef, 1 - A, 2 - Б, 3 - я, 4 - пробел, 5 - А (ширина последней метрики)
+var testAdvances = []int{1000, 1200, 1400, 1100, 500}
+
+const testNumGlyphs = 6
+
+// testCmap4 - cmap формата 4: A и пробел через idDelta, А-Б через glyphIdArray, я через idDelta
+func testCmap4() []byte {
+	type segment struct {
+		start, end  uint16
+		delta       int
+		rangeOffset uint16
+	}
+	segs := []segment{
+		{0x20, 0x20, 4 - 0x20, 0},
+		{0x41, 0x41, 1 - 0x41, 0},
+		{0x410, 0x411, 0, 0}, // rangeOffset задаётся ниже
+		{0x44F, 0x44F, 3 - 0x44F, 0},
+		{0xFFFF, 0xFFFF, 1, 0},
+	}
+	glyphIDs := []uint16{5, 2}
+	segs[2].rangeOffset = uint16((len(segs) - 2) * 2)
+
+	var b bytes.Buffer
+	w := func(v uint16) { binary.Write(&b, binary.BigEndian, v) }
+	w(4)
+	w(uint16(16 + len(segs)*8 + len(glyphIDs)*2))
+	w(0)
+	w(uint16(len(segs) * 2))
+	w(0)
+	w(0)
+	w(0)
+	for _, s := range segs {
+		w(s.end)
+	}
+	w(0)
+	for _, s := range segs {
+		w(s.start)
+	}
+	for _, s := range segs {
+		w(uint16(s.delta))
+	}
+	for _, s := range segs {
+		w(s.rangeOffset)
+	}
+	for _, g := range glyphIDs {
+		w(g)
+	}
+	return b.Bytes()
+}
+
+// testCmap12 - cmap формата 12 с теми же символами
+func testCmap12() []byte {
+	groups := [][3]uint32{{0x20, 0x20, 4}, {0x41, 0x41, 1}, {0x410, 0x410, 5}, {0x411, 0x411, 2}, {0x44F, 0x44F, 3}}
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint16(12))
+	binary.Write(&b, binary.BigEndian, uint16(0))
+	binary.Write(&b, binary.BigEndian, uint32(16+len(groups)*12))
+	binary.Write(&b, binary.BigEndian, uint32(0))
+	binary.Write(&b, binary.BigEndian, uint32(len(groups)))
+	for _, g := range groups {
+		binary.Write(&b, binary.BigEndian, g)
+	}
+	return b.Bytes()
+}
+
+// testFontData - минимальный шрифт TrueType с таблицами, которые читает ParseFont
+func testFontData(subtable []byte) []byte {
+	descent := int16(-400)
+
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint16(head[18:], 2000) // unitsPerEm
+	for i, v := range []int16{-100, descent, 1800, 1900} {
+		binary.BigEndian.PutUint16(head[36+i*2:], uint16(v))
+	}
+
+	hhea := make([]byte, 36)
+	binary.BigEndian.PutUint16(hhea[4:], 1600)
+	binary.BigEndian.PutUint16(hhea[6:], uint16(descent))
+	binary.BigEndian.PutUint16(hhea[34:], uint16(len(testAdvances)))
+
+	maxp := make([]byte, 6)
+	binary.BigEndian.PutUint16(maxp[4:], testNumGlyphs)
+
+	hmtx := make([]byte, len(testAdvances)*4)
+	for i, adv := range testAdvances {
+		binary.BigEndian.PutUint16(hmtx[i*4:], uint16(adv))
+	}
+
+	os2 := make([]byte, 96)
+	binary.BigEndian.PutUint16(os2[0:], 2)
+	binary.BigEndian.PutUint16(os2[88:], 1400)
+
+	cmap := make([]byte, 12)
+	binary.BigEndian.PutUint16(cmap[2:], 1)
+	binary.BigEndian.PutUint16(cmap[4:], 3)  // Windows
+	binary.BigEndian.PutUint16(cmap[6:], 10) // Unicode full
+	binary.BigEndian.PutUint32(cmap[8:], 12)
+	cmap = append(cmap, subtable...)
+
+	tables := []struct {
+		tag  string
+		data []byte
+	}{{"OS/2", os2}, {"cmap", cmap}, {"head", head}, {"hhea", hhea}, {"hmtx", hmtx}, {"maxp", maxp}}
+
+	var b bytes.Buffer
+	binary.Write(&b, binary.BigEndian, uint32(0x00010000))
+	binary.Write(&b, binary.BigEndian, uint16(len(tables)))
+	b.Write(make([]byte, 6))
+	offset := 12 + len(tables)*16
+	for _, t := range tables {
+		b.WriteString(t.tag)
+		binary.Write(&b, binary.BigEndian, uint32(0))
+		binary.Write(&b, binary.BigEndian, uint32(offset))
+		binary.Write(&b, binary.BigEndian, uint32(len(t.data)))
+		offset += len(t.data)
+	}
+	for _, t := range tables {
+		b.Write(t.data)
+	}
+	return b.Bytes()
+}
+
+func testFont(t *testing.T) *Font {
+	t.Helper()
+	f, err := ParseFont(testFontData(testCmap4()))
+	if err != nil {
+		t.Fatalf("ParseFont: %v", err)
+	}
+	f.Name = "TestFont"
+	return f
+}
+
+func TestParseFont(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		table []byte
+	}{{"format 4", testCmap4()}, {"format 12", testCmap12()}} {
+		f, err := ParseFont(testFontData(tc.table))
+		if err != nil {
+			t.Fatalf("%s: ParseFont: %v", tc.name, err)
+		}
+
+		glyphs := []struct {
+			r       rune
+			glyph   uint16
+			advance int
+		}{
+			{'A', 1, 600},
+			{'Б', 2, 700},
+			{'я', 3, 550},
+			{' ', 4, 250},
+			{'А', 5, 250}, // глиф за пределами hmtx берёт ширину последней метрики
+			{'Ж', 0, 500}, // нет в шрифте - .notdef
+		}
+		for _, g := range glyphs {
+			if got := f.glyph(g.r); got != g.glyph {
+				t.Errorf("%s: glyph(%q) = %d, want %d", tc.name, g.r, got, g.glyph)
+			}
+			if got := f.advance(f.glyph(g.r)); got != g.advance {
+				t.Errorf("%s: advance(%q) = %d, want %d", tc.name, g.r, got, g.advance)
+			}
+		}
+
+		if got := f.Width("Бя A", 10); got != 21 {
+			t.Errorf("%s: Width = %v, want 21", tc.name, got)
+		}
+		if f.scale(f.ascent) != 800 || f.scale(f.descent) != -200 || f.scale(f.capHeight) != 700 {
+			t.Errorf("%s: metrics = %d/%d/%d", tc.name, f.scale(f.ascent), f.scale(f.descent), f.scale(f.capHeight))
+		}
+		if f.bbox != [4]int{-100, -400, 1800, 1900} {
+			t.Errorf("%s: bbox = %v", tc.name, f.bbox)
+		}
+	}
+}
+
+func TestParseFontErrors(t *testing.T) {
+	valid := testFontData(testCmap4())
+
+	noCmap := append([]byte(nil), valid...)
+	copy(noCmap[12+16:], "xxxx") // вторая запись каталога - cmap
+
+	truncated := valid[:len(valid)-40]
+
+	for name, data := range map[string][]byte{
+		"empty":     nil,
+		"not ttf":   []byte("OTTO0000000000000000"),
+		"no cmap":   noCmap,
+		"truncated": truncated,
+	} {
+		if _, err := ParseFont(data); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestWrap(t *testing.T) {
+	f := testFont(t)
+	// Ширина "ББ" при кегле 10 - 14 пт, с пробелом "ББ ББ" - 30.5
+	got := f.Wrap("ББ ББ ББ\nя", 10, 31)
+	want := []string{"ББ ББ", "ББ", "я"}
+	if len(got) != len(want) {
+		t.Fatalf("Wrap = %q, want %q", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Wrap = %q, want %q", got, want)
+		}
+	}
+}
+
+func TestLoadFontName(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Deja Vu-Sans.ttf")
+	if err := os.WriteFile(path, testFontData(testCmap4()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	f, err := LoadFont(path)
+	if err != nil {
+		t.Fatalf("LoadFont: %v", err)
+	}
+	if f.Name != "DejaVuSans" {
+		t.Errorf("Name = %q, want DejaVuSans", f.Name)
+	}
+}
+
+// TestSystemFont - шрифт из config.toml, если он установлен
+func TestSystemFont(t *testing.T) {
+	const path = "/usr/share/fonts/truetype/dejavu/DejaVuSans.ttf"
+	if _, err := os.Stat(path); err != nil {
+		t.Skip("DejaVuSans.ttf не установлен")
+	}
+	f, err := LoadFont(path)
+	if err != nil {
+		t.Fatalf("LoadFont: %v", err)
+	}
+	for _, r := range "АБВЖЩЯабвжщяЁё№" {
+		g := f.glyph(r)
+		if g == 0 {
+			t.Errorf("glyph(%q) = 0", r)
+			continue
+		}
+		if adv := f.advance(g); adv <= 0 || adv > 1500 {
+			t.Errorf("advance(%q) = %d", r, adv)
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+
+	"rip-go-app/internal/app/ds"
+)
+
+// ErrAttachmentNotFound - вложение не найдено
+var ErrAttachmentNotFound = errors.New("вложение не найдено")
+
+// CreateAttachment - запись о файле, уже сохранённом в хранилище
+func (r *Repository) CreateAttachment(a *ds.Attachment) error {
+	if !ds.ValidAttachmentKind(a.Kind) {
+		return fmt.Errorf("неизвестный вид вложения: %s", a.Kind)
+	}
+	a.ID = 0
+	if err := r.db.Create(a).Error; err != nil {
+		return err
+	}
+	return r.db.Preload("Uploader").First(a, a.ID).Error
+}
+
+// GetAttachments - вложения заявки, новые сверху
+func (r *Repository) GetAttachments(orderID int) ([]ds.Attachment, error) {
+	var attachments []ds.Attachment
+	err := r.db.Preload("Uploader").Where("order_id = ?", orderID).
+		Order("created_at DESC, id DESC").Find(&attachments).Error
+	return attachments, err
+}
+
+// GetAttachment - вложение заявки по ID
+func (r *Repository) GetAttachment(orderID, id int) (ds.Attachment, error) {
+	var a ds.Attachment
+	if err := r.db.Where("id = ? AND order_id = ?", id, orderID).First(&a).Error; err != nil {
+		return ds.Attachment{}, ErrAttachmentNotFound
+	}
+	return a, nil
+}
+
+// DeleteAttachment - удаление записи о вложении (файл удаляет вызывающий)
+func (r *Repository) DeleteAttachment(orderID, id int) error {
+	res := r.db.Where("id = ? AND order_id = ?", id, orderID).Delete(&ds.Attachment{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrAttachmentNotFound
+	}
+	return nil
+}
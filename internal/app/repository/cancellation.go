@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"gorm.io/gorm"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/workflow"
+)
+
+// CancellationRule - штраф за отмену заявки в статусе Status
+type CancellationRule struct {
+	Status     string  // статус заявки на момент отмены
+	Percent    float64 // штраф в процентах от стоимости
+	MinFee     float64 // минимальный штраф, если штраф вообще начисляется
+	GraceHours int     // бесплатная отмена в течение часов после перехода в статус
+}
+
+// CancellationPolicy - правила отмены; в статусах без правила отмена запрещена
+type CancellationPolicy struct {
+	Rules []CancellationRule
+}
+
+// defaultCancellationPolicy - бесплатно до модерации, 10% после принятия заявки
+var defaultCancellationPolicy = CancellationPolicy{Rules: []CancellationRule{
+	{Status: ds.StatusFormed},
+	{Status: ds.StatusCompleted, Percent: 10},
+}}
+
+// ErrCancellationNotFound - заявка не отменялась
+var ErrCancellationNotFound = errors.New("заявка не отменялась")
+
+// CancellationQuote - расчёт отмены до её выполнения
+type CancellationQuote struct {
+	Status       string  `json:"status"`
+	FeePercent   float64 `json:"fee_percent"`
+	Fee          float64 `json:"fee"`
+	PaidAmount   float64 `json:"paid_amount"`
+	RefundAmount float64 `json:"refund_amount"`
+}
+
+// SetCancellationPolicy - настройка штрафов за отмену
+func (r *Repository) SetCancellationPolicy(policy CancellationPolicy) {
+	r.cancellation = policy
+}
+
+// cancellationPolicy - действующие правила отмены
+func (r *Repository) cancellationPolicy() CancellationPolicy {
+	if len(r.cancellation.Rules) == 0 {
+		return defaultCancellationPolicy
+	}
+	return r.cancellation
+}
+
+// statusSince - момент перехода заявки в текущий статус
+func statusSince(order *ds.Order) *time.Time {
+	switch order.Status {
+	case ds.StatusFormed:
+		return order.FormedAt
+	case ds.StatusCompleted:
+		return order.CompletedAt
+	}
+	return nil
+}
+
+// fee - штраф за отмену заявки в момент now
+func (p CancellationPolicy) fee(order *ds.Order, now time.Time) (float64, float64, error) {
+	for _, rule := range p.Rules {
+		if rule.Status != order.Status {
+			continue
+		}
+		if rule.Percent <= 0 && rule.MinFee <= 0 {
+			return 0, 0, nil
+		}
+		if since := statusSince(order); since != nil && now.Sub(*since) < time.Duration(rule.GraceHours)*time.Hour {
+			return 0, 0, nil
+		}
+
+		fee := math.Max(order.TotalCost*rule.Percent/100, rule.MinFee)
+		fee = math.Min(math.Round(fee*100)/100, order.TotalCost)
+		return rule.Percent, fee, nil
+	}
+	return 0, 0, &workflow.TransitionError{From: order.Status, To: ds.StatusCancelled, Reason: "отмена в этом статусе невозможна"}
+}
+
+// paidAmount - сумма действующего платежа за вычетом возвратов
+func paidAmount(tx *gorm.DB, orderID int) (float64, error) {
+	p, err := activePaymentTx(tx, orderID)
+	if errors.Is(err, ErrPaymentNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	if p.Status != ds.PaymentAuthorized && p.Status != ds.PaymentPaid {
+		return 0, nil
+	}
+	return p.Amount - p.RefundedAmount, nil
+}
+
+// quoteCancellation - штраф и возврат для заявки в текущем состоянии
+func (r *Repository) quoteCancellation(tx *gorm.DB, order *ds.Order) (CancellationQuote, error) {
+	percent, fee, err := r.cancellationPolicy().fee(order, time.Now())
+	if err != nil {
+		return CancellationQuote{}, err
+	}
+	paid, err := paidAmount(tx, order.ID)
+	if err != nil {
+		return CancellationQuote{}, err
+	}
+	return CancellationQuote{
+		Status:       order.Status,
+		FeePercent:   percent,
+		Fee:          fee,
+		PaidAmount:   paid,
+		RefundAmount: math.Max(0, math.Round((paid-fee)*100)/100),
+	}, nil
+}
+
+// QuoteCancellation - предварительный расчёт штрафа и возврата
+func (r *Repository) QuoteCancellation(orderID int) (CancellationQuote, error) {
+	var order ds.Order
+	if err := r.db.Where("id = ? AND deleted_at IS NULL", orderID).First(&order).Error; err != nil {
+		return CancellationQuote{}, ErrOrderNotFound
+	}
+	return r.quoteCancellation(r.db, &order)
+}
+
+// CancelOrder - отмена заявки с расчётом штрафа по правилам и суммы к возврату
+func (r *Repository) CancelOrder(orderID int, actor workflow.Actor, version int, reason string) (ds.Cancellation, error) {
+	var cancellation ds.Cancellation
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var quote CancellationQuote
+		_, err := r.transitionOrderTx(tx, orderID, ds.StatusCancelled, actor, version, reason, func(order *ds.Order) error {
+			if order.Status == ds.StatusCancelled {
+				return nil // повторную отмену отсечёт машина состояний
+			}
+			var err error
+			quote, err = r.quoteCancellation(tx, order)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+
+		cancellation = ds.Cancellation{
+			OrderID:      orderID,
+			ActorID:      actorID(actor),
+			Reason:       reason,
+			FromStatus:   quote.Status,
+			FeePercent:   quote.FeePercent,
+			Fee:          quote.Fee,
+			PaidAmount:   quote.PaidAmount,
+			RefundAmount: quote.RefundAmount,
+			RefundStatus: ds.RefundNone,
+		}
+		if cancellation.RefundAmount > 0 {
+			cancellation.RefundStatus = ds.RefundPending
+		}
+		return tx.Create(&cancellation).Error
+	})
+	return cancellation, err
+}
+
+// GetCancellation - сведения об отмене заявки
+func (r *Repository) GetCancellation(orderID int) (ds.Cancellation, error) {
+	var c ds.Cancellation
+	if err := r.db.Where("order_id = ?", orderID).First(&c).Error; err != nil {
+		return ds.Cancellation{}, ErrCancellationNotFound
+	}
+	return c, nil
+}
+
+// MarkCancellationRefunded - возврат по отмене выполнен
+func (r *Repository) MarkCancellationRefunded(orderID int) error {
+	res := r.db.Model(&ds.Cancellation{}).
+		Where("order_id = ? AND refund_status = ?", orderID, ds.RefundPending).
+		Updates(map[string]interface{}{"refund_status": ds.RefundComplete, "refunded_at": time.Now()})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("%w: нет ожидающего возврата", ErrPaymentState)
+	}
+	return nil
+}
+
+// GetPendingRefundOrders - заявки, возврат по отмене которых ещё не выполнен
+func (r *Repository) GetPendingRefundOrders() ([]int, error) {
+	var ids []int
+	err := r.db.Model(&ds.Cancellation{}).
+		Where("refund_status = ?", ds.RefundPending).
+		Order("created_at").Pluck("order_id", &ids).Error
+	return ids, err
+}
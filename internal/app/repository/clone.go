@@ -0,0 +1,160 @@
+package repository
+
+import (
+	"strconv"
+
+	"gorm.io/gorm"
+	"rip-go-app/internal/app/calculator"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/workflow"
+)
+
+// CloneLine - строка исходной заявки: её стоимость и срок в исходной заявке и по текущему тарифу
+type CloneLine struct {
+	SourceLineID int     `json:"source_line_id"`
+	ServiceID    int     `json:"service_id"`
+	Name         string  `json:"name"`
+	Quantity     int     `json:"quantity"`
+	OriginalCost float64 `json:"original_cost"`
+	OriginalDays int     `json:"original_days"`
+	CurrentCost  float64 `json:"current_cost"`
+	CurrentDays  int     `json:"current_days"`
+	Skipped      bool    `json:"skipped,omitempty"` // услуга не перенесена в копию
+	SkipReason   string  `json:"skip_reason,omitempty"`
+}
+
+// CloneDiff - разница между исходной заявкой и копией по текущим тарифам
+type CloneDiff struct {
+	OriginalCost        float64     `json:"original_cost"`
+	CloneCost           float64     `json:"clone_cost"`
+	CostDelta           float64     `json:"cost_delta"`
+	OriginalDays        int         `json:"original_days"`
+	CloneDays           int         `json:"clone_days"`
+	DaysDelta           int         `json:"days_delta"`
+	OriginalCustomsCost float64     `json:"original_customs_cost"`
+	CloneCustomsCost    float64     `json:"clone_customs_cost"`
+	Lines               []CloneLine `json:"lines"`
+}
+
+// CloneOrder - копия заявки в новый черновик пользователя: маршрут с остановками, груз, таможенные позиции
+// и услуги с количеством, комментариями и порядком. Цены пересчитываются по текущим тарифам
+func (r *Repository) CloneOrder(orderID int, actor workflow.Actor) (ds.Order, CloneDiff, error) {
+	var clone ds.Order
+	var diff CloneDiff
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var source ds.Order
+		err := tx.Preload("Services", func(db *gorm.DB) *gorm.DB { return db.Order("\"order\", id") }).
+			Preload("Services.Service", withDeleted).Preload("CustomsItems").Preload("Stops", orderedStops).
+			Where("id = ? AND deleted_at IS NULL", orderID).First(&source).Error
+		if err != nil {
+			return ErrOrderNotFound
+		}
+
+		clone = ds.Order{
+			SessionID:          "guest",
+			IsDraft:            true,
+			Status:             ds.StatusDraft,
+			CreatorID:          actor.UserID,
+			FromCity:           source.FromCity,
+			ToCity:             source.ToCity,
+			Weight:             source.Weight,
+			Length:             source.Length,
+			Width:              source.Width,
+			Height:             source.Height,
+			OriginCountry:      source.OriginCountry,
+			DestinationCountry: source.DestinationCountry,
+			Incoterms:          source.Incoterms,
+			ServiceLevel:       source.ServiceLevel,
+			ClonedFromID:       &source.ID,
+		}
+		if err := tx.Omit("Services", "CustomsItems", "Stops").Create(&clone).Error; err != nil {
+			return err
+		}
+
+		for _, item := range source.CustomsItems {
+			item.ID = 0
+			item.OrderID = clone.ID
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+			clone.CustomsItems = append(clone.CustomsItems, item)
+		}
+
+		for _, stop := range source.Stops {
+			stop.ID = 0
+			stop.OrderID = clone.ID
+			// Окна прибытия относятся к исходной перевозке и в копию не переносятся
+			stop.WindowFrom, stop.WindowTo = nil, nil
+			if err := tx.Create(&stop).Error; err != nil {
+				return err
+			}
+			clone.Stops = append(clone.Stops, stop)
+		}
+
+		calc := calculator.NewDeliveryCalculator()
+		for _, line := range source.Services {
+			cl := CloneLine{
+				SourceLineID: line.ID,
+				ServiceID:    line.ServiceID,
+				Name:         line.Service.Name,
+				Quantity:     line.Quantity,
+				OriginalCost: line.Cost,
+				OriginalDays: line.Days,
+			}
+
+			// Услуга могла быть снята с продажи после создания исходной заявки
+			if line.Service.DeletedAt.Valid || line.Service.ID == 0 {
+				cl.Skipped, cl.SkipReason = true, "услуга больше не доступна"
+				diff.Lines = append(diff.Lines, cl)
+				continue
+			}
+
+			orderService := ds.OrderService{
+				OrderID:   clone.ID,
+				ServiceID: line.ServiceID,
+				Quantity:  line.Quantity,
+				Comment:   line.Comment,
+				Order:     line.Order,
+				FromCity:  line.FromCity,
+				ToCity:    line.ToCity,
+				Weight:    line.Weight,
+				Length:    line.Length,
+				Width:     line.Width,
+				Height:    line.Height,
+				Service:   line.Service,
+			}
+			if _, err := priceLine(calc, &clone, &orderService); err == nil {
+				cl.CurrentCost, cl.CurrentDays = orderService.Cost, orderService.Days
+			}
+			if err := tx.Omit("Service").Create(&orderService).Error; err != nil {
+				return err
+			}
+			clone.Services = append(clone.Services, orderService)
+			diff.Lines = append(diff.Lines, cl)
+		}
+
+		sumOrderLines(&clone)
+		if err := tx.Model(&clone).Select("total_cost", "total_days", "customs_cost", "customs_days").Updates(&clone).Error; err != nil {
+			return err
+		}
+
+		diff.OriginalCost, diff.CloneCost = source.TotalCost, clone.TotalCost
+		diff.CostDelta = clone.TotalCost - source.TotalCost
+		diff.OriginalDays, diff.CloneDays = source.TotalDays, clone.TotalDays
+		diff.DaysDelta = clone.TotalDays - source.TotalDays
+		diff.OriginalCustomsCost, diff.CloneCustomsCost = source.CustomsCost, clone.CustomsCost
+
+		return recordOrderEvents(tx, ds.OrderEvent{
+			OrderID:  clone.ID,
+			ActorID:  actorID(actor),
+			Type:     ds.EventCloned,
+			Field:    "cloned_from_id",
+			NewValue: strconv.Itoa(source.ID),
+		})
+	})
+	if err != nil {
+		return ds.Order{}, CloneDiff{}, err
+	}
+	return clone, diff, nil
+}
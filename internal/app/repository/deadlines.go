@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"rip-go-app/internal/app/calculator"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/workflow"
+)
+
+// DeliveryWindows - запрошенные клиентом окна погрузки и доставки (любая граница может быть не задана)
+type DeliveryWindows struct {
+	PickupFrom   *time.Time `json:"pickup_from"`
+	PickupTo     *time.Time `json:"pickup_to"`
+	DeliveryFrom *time.Time `json:"delivery_from"`
+	DeliveryTo   *time.Time `json:"delivery_to"`
+}
+
+// Empty - окна не заданы
+func (w DeliveryWindows) Empty() bool {
+	return w.PickupFrom == nil && w.PickupTo == nil && w.DeliveryFrom == nil && w.DeliveryTo == nil
+}
+
+func (w DeliveryWindows) validate() error {
+	if w.PickupFrom != nil && w.PickupTo != nil && w.PickupTo.Before(*w.PickupFrom) {
+		return fmt.Errorf("окно погрузки заканчивается раньше начала")
+	}
+	if w.DeliveryFrom != nil && w.DeliveryTo != nil && w.DeliveryTo.Before(*w.DeliveryFrom) {
+		return fmt.Errorf("окно доставки заканчивается раньше начала")
+	}
+	if w.PickupFrom != nil && w.DeliveryTo != nil && w.DeliveryTo.Before(*w.PickupFrom) {
+		return fmt.Errorf("срок доставки раньше начала погрузки")
+	}
+	return nil
+}
+
+// ServiceSuggestion - самая быстрая услуга, успевающая к сроку доставки
+type ServiceSuggestion struct {
+	ServiceID         int       `json:"service_id"`
+	Service           string    `json:"service"`
+	Cost              float64   `json:"cost"`
+	Days              int       `json:"days"`
+	EstimatedDelivery time.Time `json:"estimated_delivery"`
+}
+
+// Feasibility - выполнимость окон погрузки и доставки по сроку перевозки и рабочему календарю
+type Feasibility struct {
+	Checked           bool               `json:"checked"` // окна заданы и проверены
+	Feasible          bool               `json:"feasible"`
+	TransitDays       int                `json:"transit_days"`
+	EarliestPickup    *time.Time         `json:"earliest_pickup,omitempty"`
+	EstimatedDelivery *time.Time         `json:"estimated_delivery,omitempty"`
+	Reason            string             `json:"reason,omitempty"`
+	Suggestion        *ServiceSuggestion `json:"suggestion,omitempty"`
+}
+
+// Status - отметка выполнимости срока для заявки ("" - окна не заданы)
+func (f Feasibility) Status() string {
+	switch {
+	case !f.Checked:
+		return ""
+	case f.Feasible:
+		return ds.DeadlineFeasible
+	default:
+		return ds.DeadlineInfeasible
+	}
+}
+
+// ServicePricer - стоимость и срок перевозки груза другой услугой; ok = false, если груз ей не подходит
+type ServicePricer func(service ds.Service) (cost float64, days int, ok bool)
+
+// SetRejectInfeasibleDeadlines - запрет формирования заявок с невыполнимым сроком доставки
+// (по умолчанию такие заявки только отмечаются)
+func (r *Repository) SetRejectInfeasibleDeadlines(reject bool) {
+	r.rejectInfeasible = reject
+}
+
+// estimateDelivery - ожидаемые погрузка и доставка: погрузка и выгрузка выполняются в рабочее время,
+// в пути груз находится календарные сутки
+func (r *Repository) estimateDelivery(w DeliveryWindows, transitDays int, now time.Time) (time.Time, time.Time) {
+	cal := r.businessCalendar()
+	start := now
+	if w.PickupFrom != nil && w.PickupFrom.After(start) {
+		start = *w.PickupFrom
+	}
+	pickup := cal.AddBusinessHours(start, 0)
+	delivery := cal.AddBusinessHours(pickup.AddDate(0, 0, transitDays), 0)
+	// Груз, пришедший раньше, ожидает открытия окна доставки
+	if w.DeliveryFrom != nil && delivery.Before(*w.DeliveryFrom) {
+		delivery = cal.AddBusinessHours(*w.DeliveryFrom, 0)
+	}
+	return pickup, delivery
+}
+
+// CheckFeasibility - проверка окон для срока перевозки transitDays. Если к сроку доставки не успеть,
+// предлагается самая быстрая услуга, которая успевает (price может быть nil - без предложения)
+func (r *Repository) CheckFeasibility(w DeliveryWindows, transitDays int, price ServicePricer) Feasibility {
+	return r.checkFeasibility(w, transitDays, price, time.Now())
+}
+
+func (r *Repository) checkFeasibility(w DeliveryWindows, transitDays int, price ServicePricer, now time.Time) Feasibility {
+	f := Feasibility{TransitDays: transitDays}
+	if w.Empty() {
+		f.Feasible = true
+		return f
+	}
+
+	f.Checked = true
+	pickup, delivery := r.estimateDelivery(w, transitDays, now)
+	f.EarliestPickup, f.EstimatedDelivery = &pickup, &delivery
+
+	if w.PickupTo != nil && pickup.After(*w.PickupTo) {
+		f.Reason = fmt.Sprintf("окно погрузки недоступно: ближайшее рабочее время %s", pickup.Format("02.01.2006 15:04"))
+		return f
+	}
+	if w.DeliveryTo != nil && delivery.After(*w.DeliveryTo) {
+		f.Reason = fmt.Sprintf("к сроку доставки не успеть: ожидаемая доставка %s", delivery.Format("02.01.2006 15:04"))
+		if price != nil {
+			f.Suggestion = r.fastestService(w, price, now)
+		}
+		return f
+	}
+	f.Feasible = true
+	return f
+}
+
+// fastestService - самая быстрая из действующих услуг, успевающая к сроку доставки; при равном сроке - дешевле
+func (r *Repository) fastestService(w DeliveryWindows, price ServicePricer, now time.Time) *ServiceSuggestion {
+	services, err := r.GetServices("")
+	if err != nil {
+		return nil
+	}
+
+	var best *ServiceSuggestion
+	for _, svc := range services {
+		cost, days, ok := price(svc)
+		if !ok {
+			continue
+		}
+		_, delivery := r.estimateDelivery(w, days, now)
+		if delivery.After(*w.DeliveryTo) {
+			continue
+		}
+		if best == nil || days < best.Days || (days == best.Days && cost < best.Cost) {
+			best = &ServiceSuggestion{ServiceID: svc.ID, Service: svc.Name, Cost: cost, Days: days, EstimatedDelivery: delivery}
+		}
+	}
+	return best
+}
+
+// orderWindows - окна заявки; незаданные берутся из окон первой и последней остановки маршрута
+func orderWindows(order *ds.Order) DeliveryWindows {
+	w := DeliveryWindows{
+		PickupFrom:   order.PickupFrom,
+		PickupTo:     order.PickupTo,
+		DeliveryFrom: order.DeliveryFrom,
+		DeliveryTo:   order.DeliveryTo,
+	}
+	if len(order.Stops) == 0 {
+		return w
+	}
+	first, last := order.Stops[0], order.Stops[len(order.Stops)-1]
+	if w.PickupFrom == nil && w.PickupTo == nil {
+		w.PickupFrom, w.PickupTo = first.WindowFrom, first.WindowTo
+	}
+	if w.DeliveryFrom == nil && w.DeliveryTo == nil {
+		w.DeliveryFrom, w.DeliveryTo = last.WindowFrom, last.WindowTo
+	}
+	return w
+}
+
+// orderPricer - расчёт груза заявки другой услугой: все принятые строки перевозятся ею, таможня - как в заявке
+func orderPricer(order *ds.Order) ServicePricer {
+	calc := calculator.NewDeliveryCalculator()
+	return func(svc ds.Service) (float64, int, bool) {
+		// груз маршрута с остановками делится между строками уже с новой услугой
+		alt := *order
+		alt.Services = make([]ds.OrderService, 0, len(order.Services))
+		for _, line := range order.Services {
+			line.ServiceID, line.Service = svc.ID, svc
+			alt.Services = append(alt.Services, line)
+		}
+		if len(alt.Services) == 0 {
+			alt.Services = []ds.OrderService{{ServiceID: svc.ID, Service: svc, Quantity: 1, Decision: ds.LinePending}}
+		}
+
+		cost := 0.0
+		for i := range alt.Services {
+			line := &alt.Services[i]
+			if !line.Accepted() {
+				continue
+			}
+			if _, err := priceLine(calc, &alt, line); err != nil {
+				return 0, 0, false
+			}
+			cost += line.Cost
+		}
+		return cost + order.CustomsCost, transitDays(&alt) + order.CustomsDays, true
+	}
+}
+
+// orderFeasibility - выполнимость окон заявки по её текущему сроку
+func (r *Repository) orderFeasibility(order *ds.Order, withSuggestion bool) Feasibility {
+	var price ServicePricer
+	if withSuggestion {
+		price = orderPricer(order)
+	}
+	return r.checkFeasibility(orderWindows(order), order.TotalDays, price, time.Now())
+}
+
+// GetOrderFeasibility - проверка окон заявки с предложением более быстрой услуги
+func (r *Repository) GetOrderFeasibility(orderID int) (Feasibility, error) {
+	order, err := r.GetOrder(orderID)
+	if err != nil {
+		return Feasibility{}, ErrOrderNotFound
+	}
+	return r.orderFeasibility(&order, true), nil
+}
+
+// SetOrderWindows - окна погрузки и доставки черновика; выполнимость срока сразу отмечается в заявке
+func (r *Repository) SetOrderWindows(orderID int, w DeliveryWindows, actor workflow.Actor, version int) (Feasibility, error) {
+	if err := w.validate(); err != nil {
+		return Feasibility{}, err
+	}
+
+	var f Feasibility
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var order ds.Order
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Services.Service", withDeleted).Preload("Stops", orderedStops).
+			Where("id = ? AND deleted_at IS NULL", orderID).First(&order).Error
+		if err != nil {
+			return ErrOrderNotFound
+		}
+		if order.Status != ds.StatusDraft {
+			return fmt.Errorf("%w: окна погрузки и доставки можно менять только в черновике", ErrNotDraft)
+		}
+
+		before := order
+		order.PickupFrom, order.PickupTo = w.PickupFrom, w.PickupTo
+		order.DeliveryFrom, order.DeliveryTo = w.DeliveryFrom, w.DeliveryTo
+		f = r.orderFeasibility(&order, true)
+		order.DeadlineStatus = f.Status()
+		if err := saveOrder(tx, &order, version); err != nil {
+			return err
+		}
+		return recordOrderEvents(tx, orderFieldChanges(&before, &order, actor)...)
+	})
+	return f, err
+}
@@ -0,0 +1,29 @@
+package repository
+
+import (
+	"gorm.io/gorm/clause"
+	"rip-go-app/internal/app/ds"
+)
+
+// GetOrderDocument - сохранённый документ заявки вида kind
+func (r *Repository) GetOrderDocument(orderID int, kind string) (ds.OrderDocument, bool, error) {
+	var doc ds.OrderDocument
+	res := r.db.Where("order_id = ? AND kind = ?", orderID, kind).Limit(1).Find(&doc)
+	return doc, res.RowsAffected > 0, res.Error
+}
+
+// SaveOrderDocument - запись о сформированном документе. Если документ этого вида уже
+// сохранён параллельным запросом, возвращается он и created = false
+func (r *Repository) SaveOrderDocument(doc ds.OrderDocument) (ds.OrderDocument, bool, error) {
+	doc.ID = 0
+	res := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&doc)
+	if res.Error != nil {
+		return ds.OrderDocument{}, false, res.Error
+	}
+	if res.RowsAffected > 0 {
+		return doc, true, nil
+	}
+
+	existing, _, err := r.GetOrderDocument(doc.OrderID, doc.Kind)
+	return existing, false, err
+}
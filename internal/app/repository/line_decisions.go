@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"rip-go-app/internal/app/calculator"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/workflow"
+)
+
+var (
+	// ErrLineNotFound - строки нет в заявке
+	ErrLineNotFound = errors.New("строка не найдена в заявке")
+	// ErrAmbiguousLine - услуга встречается в нескольких строках, строку нужно указать по её ID
+	ErrAmbiguousLine = errors.New("услуга встречается в нескольких строках заявки, укажите строку по её ID")
+	// ErrLinesUndecided - по части строк решение ещё не принято
+	ErrLinesUndecided = errors.New("не по всем строкам заявки принято решение")
+)
+
+// LineDecision - решение модератора по строке заявки
+type LineDecision struct {
+	Decision  string // approved, rejected, substituted
+	Reason    string // обязательна для rejected
+	ServiceID int    // услуга-замена для substituted
+}
+
+// validate - проверка решения
+func (d *LineDecision) validate() error {
+	d.Reason = strings.TrimSpace(d.Reason)
+	switch d.Decision {
+	case ds.LineApproved:
+	case ds.LineRejected:
+		if d.Reason == "" {
+			return fmt.Errorf("укажите причину отклонения строки")
+		}
+	case ds.LineSubstituted:
+		if d.ServiceID <= 0 {
+			return fmt.Errorf("укажите услугу-замену")
+		}
+	default:
+		return fmt.Errorf("неизвестное решение по строке: %s", d.Decision)
+	}
+	return nil
+}
+
+// DecideOrderLine - решение модератора по строке сформированной заявки; итоги заявки пересчитываются по принятым строкам
+func (r *Repository) DecideOrderLine(orderID, lineID int, decision LineDecision, actor workflow.Actor, version int) (ds.Order, error) {
+	if err := decision.validate(); err != nil {
+		return ds.Order{}, err
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var order ds.Order
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Preload("Services.Service", withDeleted).Preload("CustomsItems").Preload("Stops", orderedStops).
+			Where("id = ?", orderID).First(&order).Error
+		if err != nil {
+			return ErrOrderNotFound
+		}
+		if order.Status != ds.StatusFormed {
+			return &workflow.TransitionError{From: order.Status, To: order.Status, Reason: "решения по строкам принимаются только в сформированной заявке"}
+		}
+		if actor.Role != ds.RoleAdmin && order.ClaimedByOther(actor.UserID, time.Now()) {
+			return ErrOrderClaimed
+		}
+
+		var line *ds.OrderService
+		for i := range order.Services {
+			if order.Services[i].ID == lineID {
+				line = &order.Services[i]
+			}
+		}
+		if line == nil {
+			return ErrLineNotFound
+		}
+
+		before := *line
+		now := time.Now()
+		line.Decision, line.DecisionReason = decision.Decision, decision.Reason
+		line.DecidedByID, line.DecidedAt = actorID(actor), &now
+
+		if decision.Decision == ds.LineSubstituted && decision.ServiceID != line.ServiceID {
+			svc, err := r.GetService(decision.ServiceID)
+			if err != nil {
+				return fmt.Errorf("услуга-замена не найдена")
+			}
+			// Исходной остаётся заявленная клиентом услуга, даже после нескольких замен
+			if line.OriginalServiceID == nil {
+				original := line.ServiceID
+				line.OriginalServiceID = &original
+			}
+			line.ServiceID, line.Service = svc.ID, svc
+			if _, err := priceLine(calculator.NewDeliveryCalculator(), &order, line); err != nil {
+				return fmt.Errorf("услуга-замена не подходит для груза: %w", err)
+			}
+			if err := tx.Model(line).Select("service_id", "original_service_id").Updates(line).Error; err != nil {
+				return err
+			}
+		}
+
+		// Итоги пересчитываются по текущим тарифам, отклонённые строки в них не входят
+		priceOrder(&order)
+		if err := saveLines(tx, order.Services); err != nil {
+			return err
+		}
+		if err := saveOrder(tx, &order, version); err != nil {
+			return err
+		}
+
+		newValue := line.Decision
+		if line.Decision == ds.LineSubstituted {
+			newValue += ":" + strconv.Itoa(line.ServiceID)
+		}
+		return recordOrderEvents(tx, ds.OrderEvent{
+			OrderID:  orderID,
+			ActorID:  actorID(actor),
+			Type:     ds.EventLineDecision,
+			Field:    "services." + strconv.Itoa(line.ID) + ".decision",
+			OldValue: before.Decision,
+			NewValue: newValue,
+			Reason:   line.DecisionReason,
+		})
+	})
+	if err != nil {
+		return ds.Order{}, err
+	}
+	return r.GetOrder(orderID)
+}
+
+// FinalizeOrder - итог модерации по решениям о строках: заявка принимается, если принята хотя бы одна строка,
+// иначе отклоняется. Возвращает итоговый статус
+func (r *Repository) FinalizeOrder(orderID int, actor workflow.Actor, version int, reason string) (string, error) {
+	var status string
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var lines []ds.OrderService
+		if err := tx.Where("order_id = ?", orderID).Find(&lines).Error; err != nil {
+			return err
+		}
+		if len(lines) == 0 {
+			return ErrOrderNotFound
+		}
+
+		status = ds.StatusRejected
+		for _, line := range lines {
+			if line.Decision == ds.LinePending || line.Decision == "" {
+				return ErrLinesUndecided
+			}
+			if line.Accepted() {
+				status = ds.StatusCompleted
+			}
+		}
+		return r.completeOrderTx(tx, orderID, status, actor, version, reason)
+	})
+	return status, err
+}
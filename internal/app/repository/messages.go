@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"rip-go-app/internal/app/ds"
+)
+
+// maxMessageLength - максимальная длина сообщения
+const maxMessageLength = 4000
+
+// ErrMessageNotFound - сообщение не найдено
+var ErrMessageNotFound = errors.New("сообщение не найдено")
+
+// UnreadSummary - число непрочитанных сообщений всего и по заявкам
+type UnreadSummary struct {
+	Total   int64         `json:"total"`
+	ByOrder []OrderUnread `json:"by_order"`
+}
+
+// OrderUnread - непрочитанные сообщения одной заявки
+type OrderUnread struct {
+	OrderID int   `json:"order_id"`
+	Count   int64 `json:"count"`
+}
+
+// PostMessage - новое сообщение в обсуждении заявки. Ответ на внутреннюю заметку тоже внутренний;
+// без includeInternal внутренние заметки считаются несуществующими
+func (r *Repository) PostMessage(orderID, authorID int, parentID *int, body string, internal, includeInternal bool) (ds.OrderMessage, error) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return ds.OrderMessage{}, fmt.Errorf("пустое сообщение")
+	}
+	if len([]rune(body)) > maxMessageLength {
+		return ds.OrderMessage{}, fmt.Errorf("сообщение длиннее %d символов", maxMessageLength)
+	}
+
+	message := ds.OrderMessage{
+		OrderID:  orderID,
+		AuthorID: authorID,
+		ParentID: parentID,
+		Body:     body,
+		Internal: internal,
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if parentID != nil {
+			var parent ds.OrderMessage
+			err := tx.Where("id = ? AND order_id = ?", *parentID, orderID).First(&parent).Error
+			if err != nil || (parent.Internal && !includeInternal) {
+				return ErrMessageNotFound
+			}
+			if parent.Internal {
+				message.Internal = true
+			}
+		}
+
+		if err := tx.Create(&message).Error; err != nil {
+			return err
+		}
+		// Автор своё сообщение уже прочитал
+		return tx.Create(&ds.MessageRead{MessageID: message.ID, UserID: authorID, ReadAt: message.CreatedAt}).Error
+	})
+	if err != nil {
+		return ds.OrderMessage{}, err
+	}
+
+	return message, r.db.Preload("Author").Preload("Reads").First(&message, message.ID).Error
+}
+
+// GetMessages - сообщения заявки в порядке написания; внутренние заметки - только для менеджеров
+func (r *Repository) GetMessages(orderID int, includeInternal bool) ([]ds.OrderMessage, error) {
+	query := r.db.Preload("Author").Preload("Reads").Where("order_id = ?", orderID)
+	if !includeInternal {
+		query = query.Where("internal = ?", false)
+	}
+
+	var messages []ds.OrderMessage
+	err := query.Order("created_at, id").Find(&messages).Error
+	return messages, err
+}
+
+// MarkMessagesRead - отметка всех видимых пользователю сообщений заявки прочитанными
+func (r *Repository) MarkMessagesRead(orderID, userID int, includeInternal bool) (int64, error) {
+	sql := `INSERT INTO order_message_reads (message_id, user_id, read_at)
+		SELECT m.id, ?, ? FROM order_messages m
+		WHERE m.order_id = ? AND m.author_id <> ?`
+	if !includeInternal {
+		sql += " AND m.internal = false"
+	}
+	sql += " ON CONFLICT DO NOTHING"
+
+	res := r.db.Exec(sql, userID, time.Now(), orderID, userID)
+	return res.RowsAffected, res.Error
+}
+
+// UnreadCount - непрочитанные пользователем сообщения в доступных ему заявках.
+// scope ограничивает заявки по создателю и статусам так же, как в списке заявок
+func (r *Repository) UnreadCount(userID int, scope OrderFilter, includeInternal bool) (UnreadSummary, error) {
+	query := r.db.Table("order_messages AS m").
+		Select("m.order_id, COUNT(*) AS count").
+		Joins("JOIN orders o ON o.id = m.order_id").
+		Where("m.author_id <> ?", userID).
+		Where("NOT EXISTS (SELECT 1 FROM order_message_reads mr WHERE mr.message_id = m.id AND mr.user_id = ?)", userID).
+		Where("o.status <> ?", ds.StatusDeleted)
+	if !includeInternal {
+		query = query.Where("m.internal = ?", false)
+	}
+	if scope.CreatorID != nil {
+		query = query.Where("o.creator_id = ?", *scope.CreatorID)
+	}
+	if len(scope.Statuses) > 0 {
+		query = query.Where("o.status IN ?", scope.Statuses)
+	}
+
+	summary := UnreadSummary{ByOrder: []OrderUnread{}}
+	if err := query.Group("m.order_id").Order("m.order_id").Scan(&summary.ByOrder).Error; err != nil {
+		return UnreadSummary{}, err
+	}
+	for _, o := range summary.ByOrder {
+		summary.Total += o.Count
+	}
+	return summary, nil
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/workflow"
+)
+
+// orderField - отслеживаемое в журнале поле заявки
+type orderField struct {
+	Name  string
+	Value func(o *ds.Order) string
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+// trackedOrderFields - поля, изменения которых попадают в журнал
+var trackedOrderFields = []orderField{
+	{"from_city", func(o *ds.Order) string { return o.FromCity }},
+	{"to_city", func(o *ds.Order) string { return o.ToCity }},
+	{"weight", func(o *ds.Order) string { return formatFloat(o.Weight) }},
+	{"length", func(o *ds.Order) string { return formatFloat(o.Length) }},
+	{"width", func(o *ds.Order) string { return formatFloat(o.Width) }},
+	{"height", func(o *ds.Order) string { return formatFloat(o.Height) }},
+	{"origin_country", func(o *ds.Order) string { return o.OriginCountry }},
+	{"destination_country", func(o *ds.Order) string { return o.DestinationCountry }},
+	{"incoterms", func(o *ds.Order) string { return o.Incoterms }},
+	{"service_level", func(o *ds.Order) string { return o.ServiceLevel }},
+	{"total_cost", func(o *ds.Order) string { return formatFloat(o.TotalCost) }},
+	{"total_days", func(o *ds.Order) string { return strconv.Itoa(o.TotalDays) }},
+	{"pickup_from", func(o *ds.Order) string { return formatTime(o.PickupFrom) }},
+	{"pickup_to", func(o *ds.Order) string { return formatTime(o.PickupTo) }},
+	{"delivery_from", func(o *ds.Order) string { return formatTime(o.DeliveryFrom) }},
+	{"delivery_to", func(o *ds.Order) string { return formatTime(o.DeliveryTo) }},
+}
+
+// actorID - ID пользователя для журнала (nil для системных изменений)
+func actorID(actor workflow.Actor) *int {
+	if actor.UserID == 0 {
+		return nil
+	}
+	id := actor.UserID
+	return &id
+}
+
+// orderFieldChanges - события по изменённым полям заявки
+func orderFieldChanges(before, after *ds.Order, actor workflow.Actor) []ds.OrderEvent {
+	var events []ds.OrderEvent
+	for _, f := range trackedOrderFields {
+		oldValue, newValue := f.Value(before), f.Value(after)
+		if oldValue == newValue {
+			continue
+		}
+		events = append(events, ds.OrderEvent{
+			OrderID:  after.ID,
+			ActorID:  actorID(actor),
+			Type:     ds.EventFieldChange,
+			Field:    f.Name,
+			OldValue: oldValue,
+			NewValue: newValue,
+		})
+	}
+	return events
+}
+
+// recordOrderEvents - запись событий журнала в рамках транзакции изменения
+func recordOrderEvents(tx *gorm.DB, events ...ds.OrderEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return tx.Create(&events).Error
+}
+
+// GetOrderHistory - журнал изменений заявки в хронологическом порядке
+func (r *Repository) GetOrderHistory(orderID int) ([]ds.OrderEvent, error) {
+	var events []ds.OrderEvent
+	err := r.db.Preload("Actor").Where("order_id = ?", orderID).
+		Order("created_at ASC, id ASC").Find(&events).Error
+	return events, err
+}
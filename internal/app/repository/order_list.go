@@ -0,0 +1,208 @@
+package repository
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"rip-go-app/internal/app/ds"
+)
+
+// Поля сортировки списка заявок
+const (
+	SortCreated   = "created"
+	SortFormed    = "formed"
+	SortTotalCost = "total_cost"
+)
+
+// Размер страницы списка заявок
+const (
+	DefaultPageSize = 20
+	MaxPageSize     = 100
+)
+
+// sortColumns - SQL-выражения для полей сортировки
+var sortColumns = map[string]string{
+	SortCreated:   "created_at",
+	SortFormed:    "COALESCE(formed_at, created_at)",
+	SortTotalCost: "COALESCE(total_cost, 0)",
+}
+
+// ErrInvalidCursor - курсор повреждён или получен для другого поля или направления сортировки
+var ErrInvalidCursor = errors.New("некорректный курсор страницы")
+
+// OrderFilter - фильтры, сортировка и страница списка заявок
+type OrderFilter struct {
+	Statuses    []string
+	DateFrom    *time.Time // по дате формирования, включительно
+	DateTo      *time.Time // по дате формирования, не включая
+	FromCity    string
+	ToCity      string
+	CreatorID   *int
+	ModeratorID *int
+	MinCost     *float64
+	MaxCost     *float64
+
+	Sort   string // created, formed, total_cost
+	Desc   bool
+	Cursor string
+	Limit  int
+}
+
+// OrderPage - страница списка заявок
+type OrderPage struct {
+	Orders     []ds.Order `json:"orders"`
+	NextCursor string     `json:"next_cursor,omitempty"`
+	Total      int64      `json:"total"`
+}
+
+// orderCursor - позиция последней заявки страницы (значение сортировки + ID)
+// вместе с полем и направлением сортировки, для которых она получена
+type orderCursor struct {
+	Sort  string `json:"s"`
+	Desc  bool   `json:"d"`
+	Value string `json:"v"`
+	ID    int    `json:"id"`
+}
+
+func encodeCursor(c orderCursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string) (orderCursor, error) {
+	var c orderCursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return c, ErrInvalidCursor
+	}
+	return c, nil
+}
+
+// cursorValue - значение сортировки заявки для курсора
+func cursorValue(sort string, order ds.Order) string {
+	switch sort {
+	case SortFormed:
+		if order.FormedAt != nil {
+			return order.FormedAt.Format(time.RFC3339Nano)
+		}
+		return order.CreatedAt.Format(time.RFC3339Nano)
+	case SortTotalCost:
+		return formatFloat(order.TotalCost)
+	default:
+		return order.CreatedAt.Format(time.RFC3339Nano)
+	}
+}
+
+// parseCursorValue - значение курсора в типе колонки сортировки
+func parseCursorValue(sort, value string) (interface{}, error) {
+	if sort == SortTotalCost {
+		var cost float64
+		if _, err := fmt.Sscan(value, &cost); err != nil {
+			return nil, ErrInvalidCursor
+		}
+		return cost, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	return t, nil
+}
+
+// applyOrderFilter - фильтры списка заявок (без курсора и сортировки)
+func applyOrderFilter(query *gorm.DB, f OrderFilter) *gorm.DB {
+	query = query.Where("deleted_at IS NULL AND status != ?", ds.StatusDraft)
+
+	if len(f.Statuses) > 0 {
+		query = query.Where("status IN ?", f.Statuses)
+	}
+	if f.DateFrom != nil {
+		query = query.Where("formed_at >= ?", *f.DateFrom)
+	}
+	if f.DateTo != nil {
+		query = query.Where("formed_at < ?", *f.DateTo)
+	}
+	if f.FromCity != "" {
+		query = query.Where("LOWER(from_city) = LOWER(?)", f.FromCity)
+	}
+	if f.ToCity != "" {
+		query = query.Where("LOWER(to_city) = LOWER(?)", f.ToCity)
+	}
+	if f.CreatorID != nil {
+		query = query.Where("creator_id = ?", *f.CreatorID)
+	}
+	if f.ModeratorID != nil {
+		query = query.Where("moderator_id = ?", *f.ModeratorID)
+	}
+	if f.MinCost != nil {
+		query = query.Where("total_cost >= ?", *f.MinCost)
+	}
+	if f.MaxCost != nil {
+		query = query.Where("total_cost <= ?", *f.MaxCost)
+	}
+	return query
+}
+
+// ListOrders - постраничный список сформированных заявок.
+// Пагинация по ключу (значение сортировки, ID), фильтрация и подсчёт в БД
+func (r *Repository) ListOrders(f OrderFilter) (OrderPage, error) {
+	if f.Sort == "" {
+		f.Sort = SortCreated
+	}
+	column, ok := sortColumns[f.Sort]
+	if !ok {
+		return OrderPage{}, fmt.Errorf("неизвестное поле сортировки: %s", f.Sort)
+	}
+	if f.Limit <= 0 {
+		f.Limit = DefaultPageSize
+	}
+	if f.Limit > MaxPageSize {
+		f.Limit = MaxPageSize
+	}
+
+	page := OrderPage{Orders: []ds.Order{}}
+	if err := applyOrderFilter(r.db.Model(&ds.Order{}), f).Count(&page.Total).Error; err != nil {
+		return OrderPage{}, err
+	}
+
+	query := applyOrderFilter(r.db.Preload("Creator").Preload("Moderator"), f)
+
+	direction, cmp := "ASC", ">"
+	if f.Desc {
+		direction, cmp = "DESC", "<"
+	}
+
+	if f.Cursor != "" {
+		cursor, err := decodeCursor(f.Cursor)
+		if err != nil || cursor.Sort != f.Sort || cursor.Desc != f.Desc {
+			return OrderPage{}, ErrInvalidCursor
+		}
+		value, err := parseCursorValue(f.Sort, cursor.Value)
+		if err != nil {
+			return OrderPage{}, err
+		}
+		query = query.Where(fmt.Sprintf("(%s, id) %s (?, ?)", column, cmp), value, cursor.ID)
+	}
+
+	// Берём на одну запись больше, чтобы понять, есть ли следующая страница
+	err := query.Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(f.Limit + 1).Find(&page.Orders).Error
+	if err != nil {
+		return OrderPage{}, err
+	}
+
+	if len(page.Orders) > f.Limit {
+		page.Orders = page.Orders[:f.Limit]
+		last := page.Orders[len(page.Orders)-1]
+		page.NextCursor = encodeCursor(orderCursor{Sort: f.Sort, Desc: f.Desc, Value: cursorValue(f.Sort, last), ID: last.ID})
+	}
+
+	return page, nil
+}
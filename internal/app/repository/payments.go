@@ -0,0 +1,167 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/workflow"
+)
+
+// ErrPaymentNotFound - платёж не найден
+var ErrPaymentNotFound = errors.New("платёж не найден")
+
+// ErrPaymentState - операция недоступна в текущем состоянии оплаты
+var ErrPaymentState = errors.New("операция недоступна в текущем состоянии оплаты")
+
+// inactivePayment - статусы платежей, не влияющих на оплату заявки
+var inactivePayment = []string{ds.PaymentCancelled, ds.PaymentFailed}
+
+// GetPayments - платежи заявки, новые сверху
+func (r *Repository) GetPayments(orderID int) ([]ds.Payment, error) {
+	var payments []ds.Payment
+	err := r.db.Where("order_id = ?", orderID).Order("id DESC").Find(&payments).Error
+	return payments, err
+}
+
+// GetActivePayment - последний действующий (не отменённый и не отклонённый) платёж заявки
+func (r *Repository) GetActivePayment(orderID int) (ds.Payment, error) {
+	return activePaymentTx(r.db, orderID)
+}
+
+func activePaymentTx(tx *gorm.DB, orderID int) (ds.Payment, error) {
+	var p ds.Payment
+	res := tx.Where("order_id = ? AND status NOT IN ?", orderID, inactivePayment).Order("id DESC").Limit(1).Find(&p)
+	if res.Error != nil {
+		return ds.Payment{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ds.Payment{}, ErrPaymentNotFound
+	}
+	return p, nil
+}
+
+// GetPaymentByExternalID - платёж по ID у провайдера
+func (r *Repository) GetPaymentByExternalID(provider, externalID string) (ds.Payment, error) {
+	var p ds.Payment
+	if err := r.db.Where("provider = ? AND external_id = ?", provider, externalID).First(&p).Error; err != nil {
+		return ds.Payment{}, ErrPaymentNotFound
+	}
+	return p, nil
+}
+
+// SavePayment - сохранение платежа и пересчёт статуса оплаты заявки по действующему платежу
+func (r *Repository) SavePayment(p *ds.Payment, actor workflow.Actor, reason string) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		order, err := lockPaymentOrderTx(tx, p.OrderID)
+		if err != nil {
+			return err
+		}
+		return savePaymentTx(tx, &order, p, actor, reason)
+	})
+}
+
+// UpdateActivePayment - изменение действующего платежа заявки под блокировкой. Заявка и платёж
+// заблокированы от чтения до сохранения, поэтому параллельные списания и возвраты выполняются по очереди
+// и видят результат друг друга. update проверяет состояние, обращается к провайдеру и меняет платёж;
+// ошибка update отменяет сохранение
+func (r *Repository) UpdateActivePayment(orderID int, actor workflow.Actor, reason string, update func(p *ds.Payment) error) (ds.Payment, error) {
+	return r.updatePayment(orderID, func(tx *gorm.DB) (ds.Payment, error) {
+		return activePaymentTx(tx, orderID)
+	}, actor, reason, update)
+}
+
+// UpdatePaymentByExternalID - изменение платежа по ID у провайдера под блокировкой (для уведомлений)
+func (r *Repository) UpdatePaymentByExternalID(provider, externalID string, actor workflow.Actor, reason string, update func(p *ds.Payment) error) (ds.Payment, error) {
+	p, err := r.GetPaymentByExternalID(provider, externalID)
+	if err != nil {
+		return ds.Payment{}, err
+	}
+	return r.updatePayment(p.OrderID, func(tx *gorm.DB) (ds.Payment, error) {
+		var locked ds.Payment
+		if err := tx.Where("id = ?", p.ID).First(&locked).Error; err != nil {
+			return ds.Payment{}, ErrPaymentNotFound
+		}
+		return locked, nil
+	}, actor, reason, update)
+}
+
+// updatePayment - блокировка заявки, затем платежа (в том же порядке, что и SavePayment), изменение и сохранение
+func (r *Repository) updatePayment(orderID int, find func(tx *gorm.DB) (ds.Payment, error), actor workflow.Actor, reason string, update func(p *ds.Payment) error) (ds.Payment, error) {
+	var p ds.Payment
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		order, err := lockPaymentOrderTx(tx, orderID)
+		if err != nil {
+			return err
+		}
+		if p, err = find(tx.Clauses(clause.Locking{Strength: "UPDATE"})); err != nil {
+			return err
+		}
+		if err := update(&p); err != nil {
+			return err
+		}
+		return savePaymentTx(tx, &order, &p, actor, reason)
+	})
+	return p, err
+}
+
+// lockPaymentOrderTx - заявка платежа с блокировкой строки до конца транзакции
+func lockPaymentOrderTx(tx *gorm.DB, orderID int) (ds.Order, error) {
+	var order ds.Order
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND deleted_at IS NULL", orderID).First(&order).Error
+	if err != nil {
+		return ds.Order{}, ErrOrderNotFound
+	}
+	return order, nil
+}
+
+// savePaymentTx - сохранение платежа и статуса оплаты заблокированной заявки
+func savePaymentTx(tx *gorm.DB, order *ds.Order, p *ds.Payment, actor workflow.Actor, reason string) error {
+	if err := tx.Save(p).Error; err != nil {
+		return err
+	}
+
+	status := ds.PaymentUnpaid
+	if active, err := activePaymentTx(tx, order.ID); err == nil {
+		status = ds.OrderPaymentStatus(active)
+	} else if !errors.Is(err, ErrPaymentNotFound) {
+		return err
+	}
+	if status == order.PaymentStatus {
+		return nil
+	}
+
+	before := order.PaymentStatus
+	order.PaymentStatus = status
+	if status == ds.PaymentPaid && order.PaidAt == nil {
+		now := time.Now()
+		order.PaidAt = &now
+	}
+	if err := saveOrder(tx, order, 0); err != nil {
+		return err
+	}
+	return recordOrderEvents(tx, ds.OrderEvent{
+		OrderID:  order.ID,
+		ActorID:  actorID(actor),
+		Type:     ds.EventPayment,
+		Field:    "payment_status",
+		OldValue: before,
+		NewValue: status,
+		Reason:   reason,
+	})
+}
+
+// SetCreditApproved - одобрение или отмена отсрочки оплаты клиенту
+func (r *Repository) SetCreditApproved(userID int, approved bool) (ds.User, error) {
+	res := r.db.Model(&ds.User{}).Where("id = ?", userID).Update("credit_approved", approved)
+	if res.Error != nil {
+		return ds.User{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ds.User{}, errors.New("пользователь не найден")
+	}
+	return r.GetUser(userID)
+}
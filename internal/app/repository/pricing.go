@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"rip-go-app/internal/app/calculator"
+	"rip-go-app/internal/app/ds"
+)
+
+// LinePrice - расчёт строки заявки
+type LinePrice struct {
+	LineID    int     `json:"line_id"`
+	ServiceID int     `json:"service_id"`
+	Service   string  `json:"service"`
+	FromCity  string  `json:"from_city"`
+	ToCity    string  `json:"to_city"`
+	Quantity  int     `json:"quantity"`
+	Cost      float64 `json:"cost"`
+	Days      int     `json:"days"`
+	Decision  string  `json:"decision"`
+	Error     string  `json:"error,omitempty"` // почему строка не рассчитана (груз не подходит под услугу)
+	// Участки маршрута с остановками: груз на борту и стоимость пробега
+	Legs []calculator.LegResult `json:"legs,omitempty"`
+}
+
+// PriceBreakdown - стоимость и сроки заявки по строкам и таможне
+type PriceBreakdown struct {
+	Lines         []LinePrice `json:"lines"`
+	TransportCost float64     `json:"transport_cost"`
+	CustomsCost   float64     `json:"customs_cost"`
+	CustomsDays   int         `json:"customs_days"`
+	TotalCost     float64     `json:"total_cost"`
+	TotalDays     int         `json:"total_days"`
+	ServiceLevel  string      `json:"service_level"`
+}
+
+// breakdown - расшифровка текущих итогов заявки; lineErrors - ошибки расчёта по индексам строк
+func breakdown(order *ds.Order, lineErrors map[int]string) PriceBreakdown {
+	b := PriceBreakdown{
+		Lines:         make([]LinePrice, 0, len(order.Services)),
+		TransportCost: order.TotalCost - order.CustomsCost,
+		CustomsCost:   order.CustomsCost,
+		CustomsDays:   order.CustomsDays,
+		TotalCost:     order.TotalCost,
+		TotalDays:     order.TotalDays,
+		ServiceLevel:  order.ServiceLevel,
+	}
+	for i, line := range order.Services {
+		cargo := line.WithOrderDefaults(*order)
+		b.Lines = append(b.Lines, LinePrice{
+			LineID:    line.ID,
+			ServiceID: line.ServiceID,
+			Service:   line.Service.Name,
+			FromCity:  cargo.FromCity,
+			ToCity:    cargo.ToCity,
+			Quantity:  line.Quantity,
+			Cost:      line.Cost,
+			Days:      line.Days,
+			Decision:  line.Decision,
+			Error:     lineErrors[i],
+		})
+	}
+	return b
+}
+
+// repriceDraftTx - пересчёт строк и итогов черновика после изменения услуг, количеств или груза.
+// Заявки в других статусах не пересчитываются
+func repriceDraftTx(tx *gorm.DB, orderID int) error {
+	var order ds.Order
+	err := tx.Preload("Services.Service", withDeleted).Preload("CustomsItems").Preload("Stops", orderedStops).
+		Where("id = ?", orderID).First(&order).Error
+	if err != nil {
+		return ErrOrderNotFound
+	}
+	if order.Status != ds.StatusDraft {
+		return nil
+	}
+
+	priceOrder(&order)
+	if err := saveLines(tx, order.Services); err != nil {
+		return err
+	}
+	return tx.Model(&ds.Order{}).Where("id = ?", orderID).Updates(map[string]interface{}{
+		"total_cost":   order.TotalCost,
+		"total_days":   order.TotalDays,
+		"customs_cost": order.CustomsCost,
+		"customs_days": order.CustomsDays,
+	}).Error
+}
+
+// GetPriceBreakdown - расшифровка стоимости заявки; черновик рассчитывается по текущим тарифам
+func (r *Repository) GetPriceBreakdown(orderID int) (PriceBreakdown, error) {
+	order, err := r.GetOrder(orderID)
+	if err != nil {
+		return PriceBreakdown{}, ErrOrderNotFound
+	}
+	if order.Status == ds.StatusDraft {
+		return priceOrder(&order), nil
+	}
+	return breakdown(&order, nil), nil
+}
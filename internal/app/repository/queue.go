@@ -0,0 +1,238 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/workflow"
+)
+
+// Стратегии автоназначения сформированных заявок
+const (
+	AssignRoundRobin = "round_robin" // менеджеру, дольше всех не получавшему заявок
+	AssignWorkload   = "workload"    // менеджеру с наименьшим числом заявок в работе
+)
+
+// Представления очереди модерации
+const (
+	QueueMine       = "mine"
+	QueueUnassigned = "unassigned"
+	QueueOverdue    = "overdue"
+)
+
+// QueuePolicy - настройки очереди модерации
+type QueuePolicy struct {
+	Lease        time.Duration // срок захвата заявки менеджером
+	OverdueAfter time.Duration // срок, после которого необработанная заявка просрочена
+	AutoAssign   string        // стратегия автоназначения ("" - выключено)
+}
+
+// defaultQueuePolicy - значения по умолчанию для незаданных настроек
+var defaultQueuePolicy = QueuePolicy{Lease: 30 * time.Minute, OverdueAfter: 24 * time.Hour}
+
+var (
+	// ErrOrderClaimed - заявка в работе у другого менеджера
+	ErrOrderClaimed = errors.New("заявка взята в работу другим менеджером")
+	// ErrNotInQueue - в очереди только сформированные заявки
+	ErrNotInQueue = errors.New("заявка не находится в очереди модерации")
+)
+
+// SetQueuePolicy - настройка очереди модерации
+func (r *Repository) SetQueuePolicy(policy QueuePolicy) {
+	if policy.Lease <= 0 {
+		policy.Lease = defaultQueuePolicy.Lease
+	}
+	if policy.OverdueAfter <= 0 {
+		policy.OverdueAfter = defaultQueuePolicy.OverdueAfter
+	}
+	r.queue = policy
+}
+
+// queuePolicy - действующие настройки очереди
+func (r *Repository) queuePolicy() QueuePolicy {
+	if r.queue.Lease <= 0 {
+		return defaultQueuePolicy
+	}
+	return r.queue
+}
+
+// ClaimOrder - взять сформированную заявку в работу на срок захвата.
+// Повторный захват своей заявки продлевает срок
+func (r *Repository) ClaimOrder(orderID int, actor workflow.Actor) (ds.Order, error) {
+	var order ds.Order
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = lockQueuedOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if order.ClaimedByOther(actor.UserID, now) {
+			return ErrOrderClaimed
+		}
+
+		expires := now.Add(r.queuePolicy().Lease)
+		return assignOrderTx(tx, &order, &actor.UserID, &expires, actor, "claim")
+	})
+	return order, err
+}
+
+// ReleaseOrder - вернуть заявку в очередь (ответственный менеджер или администратор)
+func (r *Repository) ReleaseOrder(orderID int, actor workflow.Actor) (ds.Order, error) {
+	var order ds.Order
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		order, err = lockQueuedOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
+
+		if order.AssigneeID == nil {
+			return nil
+		}
+		if *order.AssigneeID != actor.UserID && actor.Role != ds.RoleAdmin {
+			return workflow.ErrForbidden
+		}
+		return assignOrderTx(tx, &order, nil, nil, actor, "release")
+	})
+	return order, err
+}
+
+// AssignOrder - назначение ответственного администратором (без срока захвата)
+func (r *Repository) AssignOrder(orderID, assigneeID int, actor workflow.Actor) (ds.Order, error) {
+	var order ds.Order
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var assignee ds.User
+		if err := tx.Where("id = ?", assigneeID).First(&assignee).Error; err != nil {
+			return fmt.Errorf("пользователь не найден")
+		}
+		if assignee.Role != ds.RoleManager && assignee.Role != ds.RoleAdmin {
+			return fmt.Errorf("назначить можно только менеджера или администратора")
+		}
+
+		var err error
+		order, err = lockQueuedOrder(tx, orderID)
+		if err != nil {
+			return err
+		}
+		return assignOrderTx(tx, &order, &assigneeID, nil, actor, "assign")
+	})
+	return order, err
+}
+
+// GetQueue - заявки очереди модерации: приоритетные, затем в порядке поступления
+func (r *Repository) GetQueue(view string, userID int) ([]ds.Order, error) {
+	now := time.Now()
+	query := r.db.Preload("Creator").Preload("Assignee").
+		Where("deleted_at IS NULL AND status = ?", ds.StatusFormed)
+
+	switch view {
+	case QueueMine:
+		query = query.Where("assignee_id = ? AND (claim_expires_at IS NULL OR claim_expires_at > ?)", userID, now)
+	case QueueUnassigned:
+		query = query.Where("(assignee_id IS NULL OR claim_expires_at <= ?)", now)
+	case QueueOverdue:
+		query = query.Where("formed_at < ?", now.Add(-r.queuePolicy().OverdueAfter))
+	default:
+		return nil, fmt.Errorf("неизвестное представление очереди: %s", view)
+	}
+
+	var orders []ds.Order
+	err := query.Order("priority DESC, formed_at ASC, id ASC").Find(&orders).Error
+	return orders, err
+}
+
+// lockQueuedOrder - сформированная заявка с блокировкой строки до конца транзакции
+func lockQueuedOrder(tx *gorm.DB, orderID int) (ds.Order, error) {
+	var order ds.Order
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND deleted_at IS NULL", orderID).First(&order).Error
+	if err != nil {
+		return ds.Order{}, ErrOrderNotFound
+	}
+	if order.Status != ds.StatusFormed {
+		return ds.Order{}, ErrNotInQueue
+	}
+	return order, nil
+}
+
+// assignOrderTx - смена ответственного с записью в журнал
+func assignOrderTx(tx *gorm.DB, order *ds.Order, assigneeID *int, expires *time.Time, actor workflow.Actor, reason string) error {
+	oldValue := assigneeValue(order.AssigneeID)
+
+	order.AssigneeID = assigneeID
+	order.ClaimExpiresAt = expires
+	order.ClaimedAt = nil
+	if assigneeID != nil {
+		now := time.Now()
+		order.ClaimedAt = &now
+	}
+
+	if err := saveOrder(tx, order, 0); err != nil {
+		return err
+	}
+	return recordOrderEvents(tx, ds.OrderEvent{
+		OrderID:  order.ID,
+		ActorID:  actorID(actor),
+		Type:     ds.EventAssignment,
+		Field:    "assignee_id",
+		OldValue: oldValue,
+		NewValue: assigneeValue(order.AssigneeID),
+		Reason:   reason,
+	})
+}
+
+func assigneeValue(id *int) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.Itoa(*id)
+}
+
+// autoAssignTx - выбор ответственного для только что сформированной заявки
+func (r *Repository) autoAssignTx(tx *gorm.DB, order *ds.Order) error {
+	strategy := r.queuePolicy().AutoAssign
+	if strategy != AssignRoundRobin && strategy != AssignWorkload {
+		return nil
+	}
+
+	managerID, err := pickManagerTx(tx, strategy, nil)
+	if err != nil || managerID == 0 {
+		// Без менеджеров заявка остаётся в общей очереди
+		return err
+	}
+
+	now := time.Now()
+	order.AssigneeID = &managerID
+	order.ClaimedAt = &now
+	order.ClaimExpiresAt = nil
+	return nil
+}
+
+// pickManagerTx - менеджер по стратегии назначения, кроме exclude (0 - менеджеров нет)
+func pickManagerTx(tx *gorm.DB, strategy string, exclude *int) (int, error) {
+	orderBy := clause.Expr{
+		SQL:  "(SELECT COUNT(*) FROM orders o WHERE o.assignee_id = users.id AND o.status = ? AND o.deleted_at IS NULL) ASC, users.id",
+		Vars: []interface{}{ds.StatusFormed},
+	}
+	if strategy == AssignRoundRobin {
+		orderBy = clause.Expr{SQL: "(SELECT MAX(o.claimed_at) FROM orders o WHERE o.assignee_id = users.id) ASC NULLS FIRST, users.id"}
+	}
+
+	query := tx.Where("role = ?", ds.RoleManager)
+	if exclude != nil {
+		query = query.Where("id != ?", *exclude)
+	}
+
+	var manager ds.User
+	if err := query.Order(orderBy).Limit(1).Find(&manager).Error; err != nil {
+		return 0, err
+	}
+	return manager.ID, nil
+}
@@ -2,19 +2,32 @@ package repository
 
 import (
     "database/sql"
+    "errors"
     "fmt"
+    "sort"
+    "strconv"
     "strings"
     "time"
 
     "github.com/google/uuid"
     "gorm.io/driver/postgres"
     "gorm.io/gorm"
+    "gorm.io/gorm/clause"
     "rip-go-app/internal/app/ds"
     "rip-go-app/internal/app/calculator"
+    "rip-go-app/internal/app/calendar"
+    "rip-go-app/internal/app/customs"
+    "rip-go-app/internal/app/workflow"
 )
 
 type Repository struct {
-	db *gorm.DB
+	db               *gorm.DB
+	queue            QueuePolicy        // настройки очереди модерации
+	calendar         *calendar.Calendar // рабочий календарь для сроков SLA
+	cancellation     CancellationPolicy // штрафы за отмену заявок
+	trashRetention   time.Duration      // срок хранения удалённых записей
+	rejectInfeasible bool               // запрет формирования заявок с невыполнимым сроком доставки
+	returnTariffs    []ReturnTariff     // специальные тарифы возврата
 }
 
 func New(dsn string) (*Repository, error) {
@@ -128,6 +141,7 @@ func (r *Repository) UpdateService(s *ds.Service) error {
 }
 
 func (r *Repository) DeleteService(id int) error {
+    // Услуга попадает в корзину, строки заявок продолжают на неё ссылаться
     return r.db.Delete(&ds.Service{}, id).Error
 }
 
@@ -142,92 +156,169 @@ type CargoOrderItem struct {
     Weight    float64
 }
 
-func (r *Repository) CreateCargoOrder(items []CargoOrderItem, creatorID int) (int, error) {
-    if len(items) == 0 {
-        return 0, fmt.Errorf("no items provided")
-    }
+// CustomsData - параметры международной перевозки заявки
+type CustomsData struct {
+    OriginCountry      string
+    DestinationCountry string
+    Incoterms          string
+    Items              []ds.CustomsItem
+}
 
-    return r.createCargoOrderTx(items, creatorID)
+// normalize - проверка и нормализация данных для таможни
+func (d *CustomsData) normalize() error {
+    var err error
+    if d.OriginCountry, err = customs.NormalizeCountry(d.OriginCountry); err != nil {
+        return err
+    }
+    if d.DestinationCountry, err = customs.NormalizeCountry(d.DestinationCountry); err != nil {
+        return err
+    }
+    d.Incoterms = strings.ToUpper(strings.TrimSpace(d.Incoterms))
+    if err := customs.ValidateIncoterms(d.Incoterms); err != nil {
+        return err
+    }
+    for i := range d.Items {
+        d.Items[i].ID = 0
+        d.Items[i].HSCode = strings.ReplaceAll(d.Items[i].HSCode, " ", "")
+        if d.Items[i].Currency == "" {
+            d.Items[i].Currency = "RUB"
+        }
+        if d.Items[i].OriginCountry != "" {
+            if d.Items[i].OriginCountry, err = customs.NormalizeCountry(d.Items[i].OriginCountry); err != nil {
+                return err
+            }
+        }
+    }
+    if err := customs.ValidateItems(d.Items); err != nil {
+        return err
+    }
+    if d.OriginCountry != d.DestinationCountry && len(d.Items) == 0 {
+        return fmt.Errorf("для международной перевозки нужны товарные позиции с кодами ТН ВЭД")
+    }
+    return nil
 }
 
-func (r *Repository) createCargoOrderTx(items []CargoOrderItem, creatorID int) (int, error) {
-    calc := calculator.NewDeliveryCalculator()
+// CreateCargoOrder - черновик заявки по перечню транспортов на уровне сервиса level, выбранном при расчёте
+// (пустой - стандартный)
+func (r *Repository) CreateCargoOrder(items []CargoOrderItem, customsData CustomsData, level string, creatorID int) (int, error) {
+    if len(items) == 0 {
+        return 0, fmt.Errorf("no items provided")
+    }
+    if level == "" {
+        level = ds.LevelStandard
+    }
+    if !ds.ValidServiceLevel(level) {
+        return 0, fmt.Errorf("неизвестный уровень сервиса: %s", level)
+    }
+    if err := customsData.normalize(); err != nil {
+        return 0, err
+    }
 
     returnID := 0
     err := r.db.Transaction(func(tx *gorm.DB) error {
-        // Используем параметры первого как общие
-        first := items[0]
-
-        order := ds.Order{
-            SessionID: "guest",
-            IsDraft:   true,
-            FromCity:  first.FromCity,
-            ToCity:    first.ToCity,
-            Weight:    0,
-            Length:    0,
-            Width:     0,
-            Height:    0,
-            TotalCost: 0,
-            TotalDays: 0,
-            Status:    ds.StatusDraft,
-            CreatorID: creatorID, // используем переданный creatorID
-        }
-        if err := tx.Create(&order).Error; err != nil {
-            return err
-        }
-
-        // агрегаты
-        maxDays := 0
-        totalCost := 0.0
-        totalWeight := 0.0
-        totalLength := 0.0
-        totalWidth := 0.0
-        totalHeight := 0.0
+        order, err := r.createCargoOrderTx(tx, items, customsData, level, creatorID)
+        returnID = order.ID
+        return err
+    })
+    if err != nil {
+        return 0, err
+    }
+    return returnID, nil
+}
 
-        for _, it := range items {
-            svc, err := r.GetService(it.ServiceID)
-            if err != nil {
-                return fmt.Errorf("service %d not found", it.ServiceID)
-            }
-            res := calc.CalculateDelivery(svc, it.FromCity, it.ToCity, it.Length, it.Width, it.Height, it.Weight)
-            if !res.IsValid {
-                return fmt.Errorf(res.ErrorMessage)
-            }
+// createCargoOrderTx - создание черновика заявки с услугами и расчётом итогов в транзакции.
+// Уровень сервиса должен быть доступен для транспорта каждой строки
+func (r *Repository) createCargoOrderTx(tx *gorm.DB, items []CargoOrderItem, customsData CustomsData, level string, creatorID int) (ds.Order, error) {
+    // Маршрут заявки - от начала первой строки до конца последней, каждая строка хранит свой
+    first, last := items[0], items[len(items)-1]
 
-            // создаём строку заказа
-            os := ds.OrderService{OrderID: order.ID, ServiceID: it.ServiceID, Quantity: 1}
-            if err := tx.Create(&os).Error; err != nil {
-                return err
-            }
+    order := ds.Order{
+        SessionID: "guest",
+        IsDraft:   true,
+        FromCity:  first.FromCity,
+        ToCity:    last.ToCity,
+        Weight:    0,
+        Length:    0,
+        Width:     0,
+        Height:    0,
+        TotalCost: 0,
+        TotalDays: 0,
+        Status:    ds.StatusDraft,
+        CreatorID: creatorID, // используем переданный creatorID
+        OriginCountry:      customsData.OriginCountry,
+        DestinationCountry: customsData.DestinationCountry,
+        Incoterms:          customsData.Incoterms,
+        ServiceLevel:       level,
+    }
+    if err := tx.Create(&order).Error; err != nil {
+        return ds.Order{}, err
+    }
 
-            if res.DeliveryDays > maxDays { maxDays = res.DeliveryDays }
-            totalCost += res.TotalCost
-            totalWeight += it.Weight
-            totalLength += it.Length
-            totalWidth += it.Width
-            totalHeight += it.Height
+    for i := range customsData.Items {
+        customsData.Items[i].OrderID = order.ID
+    }
+    if len(customsData.Items) > 0 {
+        if err := tx.Create(&customsData.Items).Error; err != nil {
+            return ds.Order{}, err
         }
+    }
 
-        // итоговые поля заказа
-        order.TotalDays = maxDays
-        order.TotalCost = totalCost
-        order.Weight = totalWeight
-        order.Length = totalLength
-        order.Width = totalWidth
-        order.Height = totalHeight
+    // агрегаты груза
+    totalWeight := 0.0
+    totalLength := 0.0
+    totalWidth := 0.0
+    totalHeight := 0.0
 
-        if err := tx.Save(&order).Error; err != nil {
-            return err
+    calc := calculator.NewDeliveryCalculator()
+    for i, it := range items {
+        svc, err := r.GetService(it.ServiceID)
+        if err != nil {
+            return ds.Order{}, fmt.Errorf("service %d not found", it.ServiceID)
+        }
+        if err := checkServiceLevel(&order, svc); err != nil {
+            return ds.Order{}, err
         }
 
-        returnID = order.ID
-        return nil
-    })
+        // создаём строку заказа со своим маршрутом, грузом и расчётом
+        line := ds.OrderService{
+            OrderID:   order.ID,
+            ServiceID: it.ServiceID,
+            Quantity:  1,
+            Order:     i,
+            FromCity:  it.FromCity,
+            ToCity:    it.ToCity,
+            Weight:    it.Weight,
+            Length:    it.Length,
+            Width:     it.Width,
+            Height:    it.Height,
+            Service:   svc,
+        }
+        if _, err := priceLine(calc, &order, &line); err != nil {
+            return ds.Order{}, err
+        }
+        if err := tx.Omit("Service").Create(&line).Error; err != nil {
+            return ds.Order{}, err
+        }
+        order.Services = append(order.Services, line)
 
-    if err != nil {
-        return 0, err
+        totalWeight += it.Weight
+        totalLength += it.Length
+        totalWidth += it.Width
+        totalHeight += it.Height
     }
-    return returnID, nil
+
+    // итоговые поля заказа
+    order.CustomsItems = customsData.Items
+    sumOrderLines(&order)
+    order.Weight = totalWeight
+    order.Length = totalLength
+    order.Width = totalWidth
+    order.Height = totalHeight
+
+    if err := tx.Omit(clause.Associations).Save(&order).Error; err != nil {
+        return ds.Order{}, err
+    }
+    return order, nil
 }
 
 // ==================== ПОЛЬЗОВАТЕЛИ ====================
@@ -304,7 +395,7 @@ func (r *Repository) GetOrders(status string, dateFrom, dateTo *time.Time) ([]ds
 // GetOrder - получение заявки по ID с услугами
 func (r *Repository) GetOrder(id int) (ds.Order, error) {
     var order ds.Order
-    err := r.db.Preload("Services.Service").Preload("Creator").Preload("Moderator").
+    err := r.db.Preload("Services.Service", withDeleted).Preload("CustomsItems").Preload("Stops", orderedStops).Preload("Creator").Preload("Moderator").
         Where("id = ? AND deleted_at IS NULL", id).First(&order).Error
     if err != nil {
         return ds.Order{}, fmt.Errorf("заявка не найдена")
@@ -315,8 +406,8 @@ func (r *Repository) GetOrder(id int) (ds.Order, error) {
 // GetDraftOrder - получение черновика заявки пользователя
 func (r *Repository) GetDraftOrder(creatorID int) (ds.Order, error) {
     var order ds.Order
-    err := r.db.Preload("Services.Service").
-        Where("creator_id = ? AND status = ? AND deleted_at IS NULL", creatorID, ds.StatusDraft).
+    err := r.db.Preload("Services.Service", withDeleted).
+        Where("creator_id = ? AND status = ? AND deleted_at IS NULL AND schedule_id IS NULL AND return_of_id IS NULL", creatorID, ds.StatusDraft).
         First(&order).Error
     if err != nil {
         return ds.Order{}, fmt.Errorf("черновик не найден")
@@ -335,104 +426,465 @@ func (r *Repository) CreateDraftOrder(creatorID int) (ds.Order, error) {
     return order, err
 }
 
-// UpdateOrder - обновление заявки
-func (r *Repository) UpdateOrder(order *ds.Order) error {
-    return r.db.Save(order).Error
+// UpdateOrder - обновление заявки с записью изменённых полей в журнал
+func (r *Repository) UpdateOrder(order *ds.Order, actor workflow.Actor, version int) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        var before ds.Order
+        if err := tx.Where("id = ? AND deleted_at IS NULL", order.ID).First(&before).Error; err != nil {
+            return ErrOrderNotFound
+        }
+        // статус мог измениться после проверки в обработчике
+        if before.Status != ds.StatusDraft {
+            return ErrNotDraft
+        }
+        if cargoChanged(&before, order) {
+            if err := checkReturnFrozen(&before, actor); err != nil {
+                return err
+            }
+        }
+        order.Version = before.Version
+        if err := saveOrder(tx, order, version); err != nil {
+            return err
+        }
+        if err := recordOrderEvents(tx, orderFieldChanges(&before, order, actor)...); err != nil {
+            return err
+        }
+        return repriceDraftTx(tx, order.ID)
+    })
 }
 
-// FormOrder - формирование заявки создателем (проверка обязательных полей)
-func (r *Repository) FormOrder(orderID int, fromCity, toCity string, weight, length, width, height float64) error {
+// cargoChanged - изменились ли маршрут, груз или уровень сервиса заявки
+func cargoChanged(before, after *ds.Order) bool {
+    return before.FromCity != after.FromCity || before.ToCity != after.ToCity ||
+        before.Weight != after.Weight || before.Length != after.Length || before.Width != after.Width ||
+        before.Height != after.Height || before.ServiceLevel != after.ServiceLevel
+}
+
+// ErrOrderNotFound - заявка не найдена
+var ErrOrderNotFound = errors.New("заявка не найдена")
+
+// ErrNotDraft - операция доступна только для черновика заявки
+var ErrNotDraft = errors.New("заявка не является черновиком")
+
+// ErrVersionConflict - заявка изменена другим пользователем после чтения
+var ErrVersionConflict = errors.New("заявка была изменена, обновите данные и повторите")
+
+// saveOrder - сохранение заявки с проверкой версии (version = 0 - без проверки).
+// Версия увеличивается при каждом сохранении
+func saveOrder(tx *gorm.DB, order *ds.Order, version int) error {
+    current := order.Version
+    if version > 0 && version != current {
+        return ErrVersionConflict
+    }
+
+    order.Version = current + 1
+    res := tx.Model(order).Where("version = ?", current).
+        Select("*").Omit(clause.Associations, "created_at").Updates(order)
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return ErrVersionConflict
+    }
+    return nil
+}
+
+// bumpOrderVersion - увеличение версии заявки при изменении её строк
+func bumpOrderVersion(tx *gorm.DB, orderID int, version int) error {
+    query := tx.Model(&ds.Order{}).Where("id = ?", orderID)
+    if version > 0 {
+        query = query.Where("version = ?", version)
+    }
+    res := query.Updates(map[string]interface{}{
+        "version":    gorm.Expr("version + 1"),
+        "updated_at": time.Now(),
+    })
+    if res.Error != nil {
+        return res.Error
+    }
+    if res.RowsAffected == 0 {
+        return ErrVersionConflict
+    }
+    return nil
+}
+
+// GetOrderVersion - текущая версия заявки (для ETag)
+func (r *Repository) GetOrderVersion(orderID int) (int, error) {
     var order ds.Order
-    err := r.db.Preload("Services").Where("id = ?", orderID).First(&order).Error
+    err := r.db.Select("id", "version").Where("id = ? AND deleted_at IS NULL", orderID).First(&order).Error
     if err != nil {
-        return fmt.Errorf("заявка не найдена")
+        return 0, ErrOrderNotFound
     }
-    
-    // Проверяем, что заявка в статусе draft
-    if order.Status != ds.StatusDraft {
-        return fmt.Errorf("можно формировать только черновики")
+    return order.Version, nil
+}
+
+// TransitionOrder - смена статуса заявки через машину состояний.
+// prepare вызывается до проверки перехода и может изменить поля заявки,
+// переход и изменённые поля записываются в журнал в той же транзакции
+func (r *Repository) TransitionOrder(orderID int, to string, actor workflow.Actor, version int, reason string, prepare func(order *ds.Order) error) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        _, err := r.transitionOrderTx(tx, orderID, to, actor, version, reason, prepare)
+        return err
+    })
+}
+
+// transitionOrderTx - смена статуса внутри уже открытой транзакции
+func (r *Repository) transitionOrderTx(tx *gorm.DB, orderID int, to string, actor workflow.Actor, version int, reason string, prepare func(order *ds.Order) error) (ds.Order, error) {
+    var order ds.Order
+    err := tx.Preload("Services.Service", withDeleted).Preload("CustomsItems").Preload("Stops", orderedStops).Preload("Creator").
+        Where("id = ? AND deleted_at IS NULL", orderID).First(&order).Error
+    if err != nil {
+        return ds.Order{}, ErrOrderNotFound
     }
-    
-    // Проверяем обязательные поля
-    if fromCity == "" || toCity == "" || weight <= 0 || length <= 0 || width <= 0 || height <= 0 {
-        return fmt.Errorf("не заполнены обязательные поля: города и параметры груза")
+
+    before := order
+    if prepare != nil {
+        if err := prepare(&order); err != nil {
+            return ds.Order{}, err
+        }
     }
-    
-    if len(order.Services) == 0 {
-        return fmt.Errorf("в заявке нет услуг")
+
+    if err := workflow.Apply(&order, to, actor); err != nil {
+        return ds.Order{}, err
     }
-    
-    // Обновляем заявку
-    now := time.Now()
-    order.FromCity = fromCity
-    order.ToCity = toCity
-    order.Weight = weight
-    order.Length = length
-    order.Width = width
-    order.Height = height
-    order.Status = ds.StatusFormed
-    order.FormedAt = &now
-    order.IsDraft = false
-    
-    return r.db.Save(&order).Error
+
+    // Сформированная заявка может сразу получить ответственного менеджера
+    if order.Status == ds.StatusFormed && before.Status != ds.StatusFormed {
+        if err := r.autoAssignTx(tx, &order); err != nil {
+            return ds.Order{}, err
+        }
+    }
+
+    // Срок SLA отсчитывается от формирования, итог фиксируется при решении
+    if order.Status != before.Status {
+        if err := r.applySLATx(tx, &order); err != nil {
+            return ds.Order{}, err
+        }
+    }
+
+    // Принятой заявке выдаётся номер для отслеживания
+    if order.Status == ds.StatusCompleted && order.TrackingNumber == nil {
+        number, err := newTrackingNumber(tx)
+        if err != nil {
+            return ds.Order{}, err
+        }
+        order.TrackingNumber = &number
+    }
+
+    if err := saveOrder(tx, &order, version); err != nil {
+        return ds.Order{}, err
+    }
+
+    events := orderFieldChanges(&before, &order, actor)
+    events = append(events, ds.OrderEvent{
+        OrderID:  order.ID,
+        ActorID:  actorID(actor),
+        Type:     ds.EventStatusChange,
+        Field:    "status",
+        OldValue: before.Status,
+        NewValue: order.Status,
+        Reason:   reason,
+    })
+    if assigneeValue(before.AssigneeID) != assigneeValue(order.AssigneeID) {
+        events = append(events, ds.OrderEvent{
+            OrderID:  order.ID,
+            Type:     ds.EventAssignment,
+            Field:    "assignee_id",
+            OldValue: assigneeValue(before.AssigneeID),
+            NewValue: assigneeValue(order.AssigneeID),
+            Reason:   "auto",
+        })
+    }
+    return order, recordOrderEvents(tx, events...)
 }
 
-// CompleteOrder - завершение/отклонение заявки модератором
-func (r *Repository) CompleteOrder(orderID int, status string, moderatorID int) error {
+// FormOrder - формирование заявки создателем (проверка обязательных полей)
+func (r *Repository) FormOrder(orderID int, actor workflow.Actor, version int, fromCity, toCity string, weight, length, width, height float64) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        order, err := r.transitionOrderTx(tx, orderID, ds.StatusFormed, actor, version, "", func(order *ds.Order) error {
+            // Параметры применяются только к черновику, остальное отсечёт машина состояний
+            if order.Status != ds.StatusDraft {
+                return nil
+            }
+            order.FromCity = fromCity
+            order.ToCity = toCity
+            // Маршрут с остановками задаётся самими остановками
+            if len(order.Stops) > 0 {
+                order.FromCity, order.ToCity = order.Stops[0].City, order.Stops[len(order.Stops)-1].City
+            }
+            order.Weight = weight
+            order.Length = length
+            order.Width = width
+            order.Height = height
+            // Клиент видит стоимость по окончательным параметрам груза; заявка со строкой,
+            // которую нельзя рассчитать (груз или уровень сервиса не подходят), не формируется
+            for _, line := range priceOrder(order).Lines {
+                if line.Error != "" {
+                    reason := fmt.Sprintf("строка %d (%s) не рассчитана: %s", line.LineID, line.Service, line.Error)
+                    return &workflow.TransitionError{From: order.Status, To: ds.StatusFormed, Reason: reason}
+                }
+            }
+
+            // Экспресс-заявки обрабатываются модераторами в первую очередь
+            if order.ServiceLevel == ds.LevelExpress {
+                order.Priority = true
+            }
+
+            // Срок доставки проверяется по окончательному расчёту
+            f := r.orderFeasibility(order, false)
+            order.DeadlineStatus = f.Status()
+            if !f.Feasible && r.rejectInfeasible {
+                return &workflow.TransitionError{From: order.Status, To: ds.StatusFormed, Reason: f.Reason}
+            }
+            return nil
+        })
+        if err != nil {
+            return err
+        }
+        return saveLines(tx, order.Services)
+    })
+}
+
+// CompleteOrder - завершение/отклонение заявки модератором. Строки без решения одобряются
+// при завершении и отклоняются при отклонении заявки
+func (r *Repository) CompleteOrder(orderID int, status string, actor workflow.Actor, version int, reason string) error {
     if status != ds.StatusCompleted && status != ds.StatusRejected {
         return fmt.Errorf("неверный статус для завершения")
     }
-    
-    var order ds.Order
-    err := r.db.Preload("Services.Service").Where("id = ?", orderID).First(&order).Error
-    if err != nil {
-        return fmt.Errorf("заявка не найдена")
+
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        return r.completeOrderTx(tx, orderID, status, actor, version, reason)
+    })
+}
+
+// completeOrderTx - решение по заявке: недостающие решения по строкам, расчёт стоимости по принятым строкам
+func (r *Repository) completeOrderTx(tx *gorm.DB, orderID int, status string, actor workflow.Actor, version int, reason string) error {
+    decided := false
+    order, err := r.transitionOrderTx(tx, orderID, status, actor, version, reason, func(order *ds.Order) error {
+        if order.Status != ds.StatusFormed {
+            return nil
+        }
+
+        lineDecision, lineReason := ds.LineApproved, ""
+        if status == ds.StatusRejected {
+            lineDecision, lineReason = ds.LineRejected, reason
+        }
+        now := time.Now()
+        accepted := 0
+        for i := range order.Services {
+            line := &order.Services[i]
+            if line.Decision == ds.LinePending || line.Decision == "" {
+                line.Decision, line.DecisionReason = lineDecision, lineReason
+                line.DecidedByID, line.DecidedAt = actorID(actor), &now
+            }
+            if line.Accepted() {
+                accepted++
+            }
+        }
+        if status == ds.StatusCompleted && accepted == 0 {
+            return &workflow.TransitionError{From: order.Status, To: status, Reason: "все строки заявки отклонены"}
+        }
+
+        // Рассчитываем стоимость и сроки при завершении
+        if status == ds.StatusCompleted {
+            priceOrder(order)
+        }
+        decided = true
+        return nil
+    })
+    if err != nil || !decided {
+        return err
     }
-    
-    if order.Status != ds.StatusFormed {
-        return fmt.Errorf("можно завершать только сформированные заявки")
+    return saveLines(tx, order.Services)
+}
+
+// priceOrder - расчёт стоимости и сроков строк заявки по текущим тарифам услуг и итогов с таможней.
+// Строки, не подходящие под параметры груза, в итог не входят
+func priceOrder(order *ds.Order) PriceBreakdown {
+    calc := calculator.NewDeliveryCalculator()
+    lineErrors := map[int]string{}
+    lineLegs := map[int][]calculator.LegResult{}
+    for i := range order.Services {
+        legs, err := priceLine(calc, order, &order.Services[i])
+        if err != nil {
+            lineErrors[i] = err.Error()
+        }
+        lineLegs[i] = legs
     }
-    
-    // Рассчитываем стоимость и сроки при завершении
-    if status == ds.StatusCompleted {
-        calc := calculator.NewDeliveryCalculator()
-        totalCost := 0.0
-        maxDays := 0
-        
-        for _, orderService := range order.Services {
-            res := calc.CalculateDelivery(orderService.Service, order.FromCity, order.ToCity, 
-                order.Length, order.Width, order.Height, order.Weight)
-            if res.IsValid {
-                totalCost += res.TotalCost
-                if res.DeliveryDays > maxDays {
-                    maxDays = res.DeliveryDays
-                }
+    sumOrderLines(order)
+    b := breakdown(order, lineErrors)
+    for i := range b.Lines {
+        b.Lines[i].Legs = lineLegs[i]
+    }
+    return b
+}
+
+// priceLine - расчёт стоимости и срока строки по её маршруту и грузу (незаданные берутся из заявки)
+// на уровне сервиса заявки. Для заявки с остановками строка везёт свою долю груза маршрута (routeShare),
+// участки возвращаются для расшифровки. Стоимость умножается на количество, для возврата - со скидкой тарифа
+func priceLine(calc *calculator.DeliveryCalculator, order *ds.Order, line *ds.OrderService) ([]calculator.LegResult, error) {
+    if len(order.Stops) > 0 {
+        // груз доли делится поровну между единицами транспорта строки
+        units := float64(max(line.Quantity, 1))
+        legs := stopLegs(order.Stops)
+        share := routeShare(order, line)
+        for i := range legs {
+            legs[i].Weight *= share / units
+            legs[i].Volume *= share / units
+        }
+        res := calc.CalculateRoute(line.Service, order.ServiceLevel, legs)
+        if !res.IsValid {
+            line.Cost, line.Days = 0, 0
+            return res.Legs, errors.New(res.ErrorMessage)
+        }
+        line.Cost, line.Days = returnTariffCost(order, line, res.TotalCost*units), res.DeliveryDays
+        return res.Legs, nil
+    }
+
+    cargo := line.WithOrderDefaults(*order)
+    res := calc.CalculateDeliveryLevel(line.Service, order.ServiceLevel, cargo.FromCity, cargo.ToCity, cargo.Length, cargo.Width, cargo.Height, cargo.Weight)
+    if !res.IsValid {
+        line.Cost, line.Days = 0, 0
+        return nil, errors.New(res.ErrorMessage)
+    }
+    line.Cost, line.Days = returnTariffCost(order, line, res.TotalCost*float64(max(line.Quantity, 1))), res.DeliveryDays
+    return nil, nil
+}
+
+// sumOrderLines - итоги заявки из принятых строк: сумма стоимостей, срок перевозки (transitDays) и таможня
+func sumOrderLines(order *ds.Order) {
+    totalCost := 0.0
+    for _, line := range order.Services {
+        if line.Accepted() {
+            totalCost += line.Cost
+        }
+    }
+
+    // таможенное оформление считается один раз на всю заявку
+    clearance := customs.CalculateClearance(order.OriginCountry, order.DestinationCountry, customs.DeclaredValue(order.CustomsItems))
+    order.CustomsDays = clearance.Days
+    order.CustomsCost = clearance.Cost
+    order.TotalCost = totalCost + clearance.Cost
+    order.TotalDays = transitDays(order) + clearance.Days
+}
+
+// transitDays - срок перевозки по принятым строкам. В заявке с остановками каждая строка везёт свою долю груза
+// по всему маршруту, строки идут параллельно - срок самой долгой. Без остановок строки со своими маршрутами
+// складываются в цепочки: строка, которая начинается в городе прибытия более ранней (по порядку в заявке)
+// строки, отправляется после неё, и её срок прибавляется; независимые направления идут параллельно.
+// Срок заявки - самая долгая цепочка
+func transitDays(order *ds.Order) int {
+    var lines []ds.OrderService
+    for _, line := range order.Services {
+        if line.Accepted() {
+            lines = append(lines, line.WithOrderDefaults(*order))
+        }
+    }
+    if len(order.Stops) > 0 {
+        days := 0
+        for _, line := range lines {
+            days = max(days, line.Days)
+        }
+        return days
+    }
+
+    sort.SliceStable(lines, func(i, j int) bool {
+        if lines[i].Order != lines[j].Order {
+            return lines[i].Order < lines[j].Order
+        }
+        return lines[i].ID < lines[j].ID
+    })
+    // arrival[i] - день прибытия строки i с учётом предшествующих участков
+    arrival := make([]int, len(lines))
+    days := 0
+    for i, line := range lines {
+        start := 0
+        for j := 0; j < i; j++ {
+            if lines[j].ToCity == line.FromCity {
+                start = max(start, arrival[j])
             }
         }
-        
-        order.TotalCost = totalCost
-        order.TotalDays = maxDays
+        arrival[i] = start + line.Days
+        days = max(days, arrival[i])
     }
-    
-    now := time.Now()
-    order.Status = status
-    order.ModeratorID = &moderatorID
-    order.CompletedAt = &now
-    
-    return r.db.Save(&order).Error
+    return days
 }
 
-// DeleteOrder - удаление заявки (мягкое удаление)
+// saveLines - сохранение расчёта и решений по строкам заявки
+func saveLines(tx *gorm.DB, lines []ds.OrderService) error {
+    for _, line := range lines {
+        err := tx.Model(&ds.OrderService{}).Where("id = ?", line.ID).
+            Updates(map[string]interface{}{
+                "cost":            line.Cost,
+                "days":            line.Days,
+                "decision":        line.Decision,
+                "decision_reason": line.DecisionReason,
+                "decided_by_id":   line.DecidedByID,
+                "decided_at":      line.DecidedAt,
+            }).Error
+        if err != nil {
+            return err
+        }
+    }
+    return nil
+}
 
-func (r *Repository) DeleteOrder(orderID int) error {
-    // Каскадное удаление автоматически удалит связанные записи в order_services
-    return r.db.Where("id = ?", orderID).Delete(&ds.Order{}).Error
+// UpdateOrderCustoms - обновление данных международной перевозки черновика
+func (r *Repository) UpdateOrderCustoms(orderID int, data CustomsData, actor workflow.Actor, version int) error {
+    if err := data.normalize(); err != nil {
+        return err
+    }
+
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        var order ds.Order
+        if err := tx.Where("id = ? AND deleted_at IS NULL", orderID).First(&order).Error; err != nil {
+            return ErrOrderNotFound
+        }
+        if order.Status != ds.StatusDraft {
+            return fmt.Errorf("%w: таможенные данные можно менять только в черновике", ErrNotDraft)
+        }
+
+        before := order
+        order.OriginCountry = data.OriginCountry
+        order.DestinationCountry = data.DestinationCountry
+        order.Incoterms = data.Incoterms
+        if err := saveOrder(tx, &order, version); err != nil {
+            return err
+        }
+        if err := recordOrderEvents(tx, orderFieldChanges(&before, &order, actor)...); err != nil {
+            return err
+        }
+
+        // позиции заменяются целиком
+        if err := tx.Where("order_id = ?", orderID).Delete(&ds.CustomsItem{}).Error; err != nil {
+            return err
+        }
+        for i := range data.Items {
+            data.Items[i].OrderID = orderID
+        }
+        if len(data.Items) > 0 {
+            if err := tx.Create(&data.Items).Error; err != nil {
+                return err
+            }
+        }
+        return repriceDraftTx(tx, orderID)
+    })
+}
+
+// DeleteOrder - удаление заявки в корзину (переход в статус deleted); окончательно удаляется по истечении срока хранения
+func (r *Repository) DeleteOrder(orderID int, actor workflow.Actor, version int) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        if _, err := r.transitionOrderTx(tx, orderID, ds.StatusDeleted, actor, version, "", nil); err != nil {
+            return err
+        }
+        return tx.Delete(&ds.Order{}, orderID).Error
+    })
 }
 
 // GetCartIcon - получение иконки корзины (количество услуг в черновике)
 func (r *Repository) GetCartIcon(creatorID int) (int, int, error) {
     var order ds.Order
-    err := r.db.Preload("Services").Where("creator_id = ? AND status = ? AND deleted_at IS NULL", 
+    err := r.db.Preload("Services").Where("creator_id = ? AND status = ? AND deleted_at IS NULL AND schedule_id IS NULL AND return_of_id IS NULL", 
         creatorID, ds.StatusDraft).First(&order).Error
     if err != nil {
         // Создаём черновик если нет
@@ -448,70 +900,188 @@ func (r *Repository) GetCartIcon(creatorID int) (int, int, error) {
 
 // ==================== М-М ЗАЯВКА-УСЛУГА ====================
 
-// AddServiceToOrder - добавление услуги в заявку-черновик
-func (r *Repository) AddServiceToOrder(orderID, serviceID int) error {
-    // Проверяем что заявка - черновик
+// AddServiceToOrder - добавление строки с услугой в заявку-черновик. У каждой строки свои маршрут и груз,
+// поэтому повторное добавление той же услуги создаёт новую строку
+func (r *Repository) AddServiceToOrder(orderID, serviceID int, actor workflow.Actor, version int) (ds.OrderService, error) {
+    var orderService ds.OrderService
+    err := r.db.Transaction(func(tx *gorm.DB) error {
+        order, err := draftOrderTx(tx, orderID)
+        if err != nil {
+            return err
+        }
+
+        // Проверяем услугу
+        svc, err := r.GetService(serviceID)
+        if err != nil {
+            return fmt.Errorf("услуга не найдена")
+        }
+        if err := checkServiceLevel(&order, svc); err != nil {
+            return err
+        }
+        if err := checkReturnFrozen(&order, actor); err != nil {
+            return err
+        }
+
+        if err := bumpOrderVersion(tx, orderID, version); err != nil {
+            return err
+        }
+
+        orderService = ds.OrderService{
+            OrderID:   orderID,
+            ServiceID: serviceID,
+            Quantity:  1,
+        }
+        if err := tx.Create(&orderService).Error; err != nil {
+            return err
+        }
+        err = recordOrderEvents(tx, ds.OrderEvent{
+            OrderID:  orderID,
+            ActorID:  actorID(actor),
+            Type:     ds.EventServiceAdded,
+            Field:    "services",
+            NewValue: strconv.Itoa(serviceID),
+        })
+        if err != nil {
+            return err
+        }
+        return repriceDraftTx(tx, orderID)
+    })
+    return orderService, err
+}
+
+// draftOrderTx - заявка, строки которой можно менять: только черновик
+func draftOrderTx(tx *gorm.DB, orderID int) (ds.Order, error) {
     var order ds.Order
-    err := r.db.Where("id = ? AND status = ?", orderID, ds.StatusDraft).First(&order).Error
-    if err != nil {
-        return fmt.Errorf("заявка не найдена или не является черновиком")
-    }
-    
-    // Проверяем услугу
-    _, err = r.GetService(serviceID)
-    if err != nil {
-        return fmt.Errorf("услуга не найдена")
+    if err := tx.Where("id = ? AND deleted_at IS NULL", orderID).First(&order).Error; err != nil {
+        return ds.Order{}, ErrOrderNotFound
     }
-    
-    // Проверяем не добавлена ли уже
-    var existing ds.OrderService
-    err = r.db.Where("order_id = ? AND service_id = ?", orderID, serviceID).First(&existing).Error
-    if err == nil {
-        // Увеличиваем количество
-        existing.Quantity++
-        return r.db.Save(&existing).Error
+    if order.Status != ds.StatusDraft {
+        return ds.Order{}, ErrNotDraft
     }
-    
-    // Добавляем новую
-    orderService := ds.OrderService{
-        OrderID:   orderID,
-        ServiceID: serviceID,
-        Quantity:  1,
+    return order, nil
+}
+
+// checkServiceLevel - уровень сервиса заявки должен быть доступен для транспорта услуги
+func checkServiceLevel(order *ds.Order, svc ds.Service) error {
+    if _, ok := calculator.NewDeliveryCalculator().ServiceLevel(svc.ID, order.ServiceLevel); !ok {
+        return fmt.Errorf("уровень сервиса %s недоступен для услуги %s", order.ServiceLevel, svc.Name)
     }
-    return r.db.Create(&orderService).Error
+    return nil
 }
 
-// RemoveServiceFromOrder - удаление услуги из заявки
-func (r *Repository) RemoveServiceFromOrder(orderID, serviceID int) error {
-    var orderService ds.OrderService
-    err := r.db.Where("order_id = ? AND service_id = ?", orderID, serviceID).First(&orderService).Error
-    if err != nil {
-        return fmt.Errorf("услуга не найдена в заявке")
+// orderLineTx - строка заявки по её ID
+func orderLineTx(tx *gorm.DB, orderID, lineID int) (ds.OrderService, error) {
+    var line ds.OrderService
+    if err := tx.Where("id = ? AND order_id = ?", lineID, orderID).First(&line).Error; err != nil {
+        return ds.OrderService{}, ErrLineNotFound
     }
-    
-    return r.db.Delete(&orderService).Error
+    return line, nil
 }
 
-// UpdateOrderService - обновление количества/порядка в м-м
-func (r *Repository) UpdateOrderService(orderID, serviceID int, quantity, orderNum int, comment string) error {
-    var orderService ds.OrderService
-    err := r.db.Where("order_id = ? AND service_id = ?", orderID, serviceID).First(&orderService).Error
-    if err != nil {
-        return fmt.Errorf("услуга не найдена в заявке")
+// GetOrderLineByService - единственная строка заявки с услугой serviceID (для устаревших путей по ID услуги).
+// Если услуга встречается в нескольких строках, строку по услуге выбрать нельзя
+func (r *Repository) GetOrderLineByService(orderID, serviceID int) (ds.OrderService, error) {
+    var lines []ds.OrderService
+    if err := r.db.Where("order_id = ? AND service_id = ?", orderID, serviceID).Limit(2).Find(&lines).Error; err != nil {
+        return ds.OrderService{}, err
+    }
+    switch len(lines) {
+    case 0:
+        return ds.OrderService{}, ErrLineNotFound
+    case 1:
+        return lines[0], nil
+    default:
+        return ds.OrderService{}, ErrAmbiguousLine
     }
-    
-    orderService.Quantity = quantity
-    orderService.Order = orderNum
-    orderService.Comment = comment
-    
-    return r.db.Save(&orderService).Error
+}
+
+// RemoveServiceFromOrder - удаление строки из заявки
+func (r *Repository) RemoveServiceFromOrder(orderID, lineID int, actor workflow.Actor, version int) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        if _, err := draftOrderTx(tx, orderID); err != nil {
+            return err
+        }
+        orderService, err := orderLineTx(tx, orderID, lineID)
+        if err != nil {
+            return err
+        }
+
+        if err := bumpOrderVersion(tx, orderID, version); err != nil {
+            return err
+        }
+        if err := tx.Delete(&orderService).Error; err != nil {
+            return err
+        }
+        err = recordOrderEvents(tx, ds.OrderEvent{
+            OrderID:  orderID,
+            ActorID:  actorID(actor),
+            Type:     ds.EventServiceRemoved,
+            Field:    "services",
+            OldValue: strconv.Itoa(orderService.ServiceID),
+        })
+        if err != nil {
+            return err
+        }
+        return repriceDraftTx(tx, orderID)
+    })
+}
+
+// UpdateOrderService - обновление количества/порядка строки заявки
+func (r *Repository) UpdateOrderService(orderID, lineID int, quantity, orderNum int, comment string, actor workflow.Actor, version int) error {
+    return r.db.Transaction(func(tx *gorm.DB) error {
+        order, err := draftOrderTx(tx, orderID)
+        if err != nil {
+            return err
+        }
+        orderService, err := orderLineTx(tx, orderID, lineID)
+        if err != nil {
+            return err
+        }
+        if quantity != orderService.Quantity {
+            if err := checkReturnFrozen(&order, actor); err != nil {
+                return err
+            }
+        }
+
+        if err := bumpOrderVersion(tx, orderID, version); err != nil {
+            return err
+        }
+
+        before := orderService
+        orderService.Quantity = quantity
+        orderService.Order = orderNum
+        orderService.Comment = comment
+
+        if err := tx.Save(&orderService).Error; err != nil {
+            return err
+        }
+
+        prefix := "services." + strconv.Itoa(lineID) + "."
+        var events []ds.OrderEvent
+        if before.Quantity != orderService.Quantity {
+            events = append(events, ds.OrderEvent{OrderID: orderID, ActorID: actorID(actor), Type: ds.EventServiceUpdated,
+                Field: prefix + "quantity", OldValue: strconv.Itoa(before.Quantity), NewValue: strconv.Itoa(orderService.Quantity)})
+        }
+        if before.Order != orderService.Order {
+            events = append(events, ds.OrderEvent{OrderID: orderID, ActorID: actorID(actor), Type: ds.EventServiceUpdated,
+                Field: prefix + "order", OldValue: strconv.Itoa(before.Order), NewValue: strconv.Itoa(orderService.Order)})
+        }
+        if before.Comment != orderService.Comment {
+            events = append(events, ds.OrderEvent{OrderID: orderID, ActorID: actorID(actor), Type: ds.EventServiceUpdated,
+                Field: prefix + "comment", OldValue: before.Comment, NewValue: orderService.Comment})
+        }
+        if err := recordOrderEvents(tx, events...); err != nil {
+            return err
+        }
+        return repriceDraftTx(tx, orderID)
+    })
 }
 
 
 // AddToCart - добавление услуги в корзину
 func (r *Repository) ensureDraftOrder(sessionID string) (int, error) {
     var order ds.Order
-    if err := r.db.Where("session_id = ? AND is_draft = ? AND deleted_at IS NULL", sessionID, true).First(&order).Error; err != nil {
+    if err := r.db.Where("session_id = ? AND is_draft = ? AND deleted_at IS NULL AND schedule_id IS NULL AND return_of_id IS NULL", sessionID, true).First(&order).Error; err != nil {
         // создаём с системным создателем
         order = ds.Order{
             SessionID: sessionID, 
@@ -544,7 +1114,8 @@ func (r *Repository) AddToCart(serviceID int) error {
         ON CONFLICT (order_id, service_id)
         DO UPDATE SET quantity = order_services.quantity + 1
     `, orderID, serviceID)
-    return err
+    if err != nil { return err }
+    return repriceDraftTx(r.db, orderID)
 }
 
 // RemoveFromCart - удаление услуги из корзины
@@ -564,7 +1135,8 @@ func (r *Repository) RemoveFromCart(serviceID int) error {
     } else {
         _, err = sqlDB.Exec(`DELETE FROM order_services WHERE order_id=$1 AND service_id=$2`, orderID, serviceID)
     }
-    return err
+    if err != nil { return err }
+    return repriceDraftTx(r.db, orderID)
 }
 
 // GetCart - получение корзины
@@ -608,46 +1180,5 @@ func (r *Repository) ClearCart() {
     orderID, err := r.ensureDraftOrder("guest")
     if err != nil { return }
     r.db.Where("order_id = ?", orderID).Delete(&ds.CartService{})
-}
-
-// UpdateOrderStatusWithCursor - обновление статуса заказа через курсор без ORM
-func (r *Repository) UpdateOrderStatusWithCursor(orderID int, newStatus string) error {
-	// Получаем нативное подключение к БД из GORM
-	sqlDB, err := r.db.DB()
-	if err != nil {
-		return fmt.Errorf("failed to get sql.DB: %w", err)
-	}
-
-	// Подготавливаем запрос с курсором
-	query := `
-		UPDATE orders 
-		SET status = $1 
-		WHERE id = $2
-		RETURNING id, status, from_city, to_city
-	`
-
-	// Выполняем запрос через курсор
-	rows, err := sqlDB.Query(query, newStatus, orderID)
-	if err != nil {
-		return fmt.Errorf("failed to execute query: %w", err)
-	}
-	defer rows.Close()
-
-	// Обрабатываем результат через курсор
-	if rows.Next() {
-		var id int
-		var status, fromCity, toCity string
-		
-		err := rows.Scan(&id, &status, &fromCity, &toCity)
-		if err != nil {
-			return fmt.Errorf("failed to scan row: %w", err)
-		}
-		
-		// Логируем обновление
-		fmt.Printf("Order %d status updated to: %s (Route: %s -> %s)\n", id, status, fromCity, toCity)
-	} else {
-		return fmt.Errorf("order with id %d not found", orderID)
-	}
-
-	return nil
+    _ = repriceDraftTx(r.db, orderID)
 }
@@ -0,0 +1,315 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/workflow"
+)
+
+// ErrReturnNotAllowed - возврат по заявке в этом статусе невозможен
+var ErrReturnNotAllowed = errors.New("возврат возможен только по завершённой или доставленной заявке")
+
+// ErrTariffForbidden - специальный тариф возврата назначает только модератор
+var ErrTariffForbidden = fmt.Errorf("специальный тариф возврата назначает менеджер или администратор: %w", workflow.ErrForbidden)
+
+// ErrReturnFrozen - груз, маршрут и строки возврата со специальным тарифом меняет только модератор
+var ErrReturnFrozen = fmt.Errorf("возврат со специальным тарифом может изменить только менеджер или администратор: %w", workflow.ErrForbidden)
+
+// ReturnTariff - специальный тариф возврата: скидка от обычного тарифа перевозки
+type ReturnTariff struct {
+	Name        string  `json:"name"`
+	Discount    float64 `json:"discount"` // %, 100 - возврат бесплатно для клиента
+	Description string  `json:"description"`
+}
+
+// SetReturnTariffs - специальные тарифы возврата (без тарифов возврат считается по обычным ценам)
+func (r *Repository) SetReturnTariffs(tariffs []ReturnTariff) {
+	r.returnTariffs = tariffs
+}
+
+// GetReturnTariffs - доступные тарифы возврата
+func (r *Repository) GetReturnTariffs() []ReturnTariff {
+	return r.returnTariffs
+}
+
+// returnTariff - тариф возврата по названию
+func (r *Repository) returnTariff(name string) (ReturnTariff, error) {
+	for _, t := range r.returnTariffs {
+		if t.Name == name {
+			return t, nil
+		}
+	}
+	return ReturnTariff{}, fmt.Errorf("неизвестный тариф возврата: %s", name)
+}
+
+// returnTariffCost - стоимость перевозки строки со скидкой тарифа возврата. Скидка действует только
+// на возвращаемый груз исходной заявки, добавленные в черновик строки считаются по обычным ценам
+func returnTariffCost(order *ds.Order, line *ds.OrderService, cost float64) float64 {
+	if order.ReturnDiscount <= 0 || line.ReturnOfLineID == nil {
+		return cost
+	}
+	return math.Round(cost*(100-order.ReturnDiscount)) / 100
+}
+
+// isModerator - менеджер или администратор
+func isModerator(actor workflow.Actor) bool {
+	return actor.Role == ds.RoleManager || actor.Role == ds.RoleAdmin
+}
+
+// checkReturnFrozen - груз, маршрут и строки возврата со специальным тарифом согласованы модератором
+// и клиентом не меняются
+func checkReturnFrozen(order *ds.Order, actor workflow.Actor) error {
+	if order.ReturnTariff != "" && !isModerator(actor) {
+		return ErrReturnFrozen
+	}
+	return nil
+}
+
+// ReturnLine - возвращаемая часть строки исходной заявки
+type ReturnLine struct {
+	LineID   int `json:"line_id"`
+	Quantity int `json:"quantity"`
+}
+
+// ReturnRequest - параметры возврата; без строк возвращается весь невозвращённый груз
+type ReturnRequest struct {
+	Reason string       `json:"reason"`
+	Tariff string       `json:"tariff"`
+	Lines  []ReturnLine `json:"lines"`
+}
+
+// ReturnSummary - возврат в карточке исходной заявки
+type ReturnSummary struct {
+	ID           int       `json:"id"`
+	Status       string    `json:"status"`
+	ReturnReason string    `json:"return_reason"`
+	ReturnTariff string    `json:"return_tariff,omitempty"`
+	TotalCost    float64   `json:"total_cost"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// returnedQuantities - количество уже возвращённого груза по строкам исходной заявки.
+// Удалённые, отклонённые и отменённые возвраты не учитываются
+func returnedQuantities(tx *gorm.DB, sourceID int) (map[int]int, error) {
+	var rows []struct {
+		ReturnOfLineID int
+		Quantity       int
+	}
+	err := tx.Table("order_services").
+		Select("order_services.return_of_line_id, SUM(order_services.quantity) AS quantity").
+		Joins("JOIN orders ON orders.id = order_services.order_id").
+		Where("orders.return_of_id = ? AND orders.deleted_at IS NULL AND orders.status NOT IN ?", sourceID,
+			[]string{ds.StatusDeleted, ds.StatusRejected, ds.StatusCancelled}).
+		Where("order_services.return_of_line_id IS NOT NULL").
+		Group("order_services.return_of_line_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	returned := make(map[int]int, len(rows))
+	for _, row := range rows {
+		returned[row.ReturnOfLineID] = row.Quantity
+	}
+	return returned, nil
+}
+
+// CreateReturnOrder - черновик возврата по завершённой или доставленной заявке: маршрут в обратную сторону,
+// груз исходных строк (можно вернуть часть строк и количества), причина и специальный тариф возврата.
+// Тариф со скидкой может указать только менеджер или администратор. Черновик принадлежит клиенту исходной заявки
+func (r *Repository) CreateReturnOrder(orderID int, req ReturnRequest, actor workflow.Actor) (ds.Order, error) {
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return ds.Order{}, fmt.Errorf("укажите причину возврата")
+	}
+	var tariff ReturnTariff
+	if req.Tariff != "" {
+		if !isModerator(actor) {
+			return ds.Order{}, ErrTariffForbidden
+		}
+		var err error
+		if tariff, err = r.returnTariff(req.Tariff); err != nil {
+			return ds.Order{}, err
+		}
+	}
+
+	var draftID int
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		// Исходная заявка блокируется до создания возврата: параллельные частичные возвраты
+		// видят уже возвращённые количества друг друга и не превышают груз заявки
+		var source ds.Order
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Services", func(db *gorm.DB) *gorm.DB { return db.Order("\"order\", id") }).
+			Preload("Services.Service", withDeleted).Preload("CustomsItems").Preload("Stops", orderedStops).
+			Where("id = ? AND deleted_at IS NULL", orderID).First(&source).Error
+		if err != nil {
+			return ErrOrderNotFound
+		}
+		if source.Status != ds.StatusCompleted && source.Status != ds.StatusDelivered {
+			return ErrReturnNotAllowed
+		}
+
+		returned, err := returnedQuantities(tx, source.ID)
+		if err != nil {
+			return err
+		}
+
+		// Количества к возврату по строкам исходной заявки
+		quantities := map[int]int{}
+		if len(req.Lines) == 0 {
+			for _, line := range source.Services {
+				if left := line.Quantity - returned[line.ID]; line.Accepted() && left > 0 {
+					quantities[line.ID] = left
+				}
+			}
+			if len(quantities) == 0 {
+				return fmt.Errorf("весь груз заявки уже возвращён")
+			}
+		}
+		for _, rl := range req.Lines {
+			var line *ds.OrderService
+			for i := range source.Services {
+				if source.Services[i].ID == rl.LineID && source.Services[i].Accepted() {
+					line = &source.Services[i]
+				}
+			}
+			if line == nil {
+				return ErrLineNotFound
+			}
+			left := line.Quantity - returned[line.ID] - quantities[line.ID]
+			if rl.Quantity <= 0 || rl.Quantity > left {
+				return fmt.Errorf("строка %d: к возврату доступно не больше %d", line.ID, max(left, 0))
+			}
+			quantities[line.ID] += rl.Quantity
+		}
+
+		draft := ds.Order{
+			SessionID:          "guest",
+			IsDraft:            true,
+			Status:             ds.StatusDraft,
+			CreatorID:          source.CreatorID,
+			FromCity:           source.ToCity,
+			ToCity:             source.FromCity,
+			Weight:             source.Weight,
+			Length:             source.Length,
+			Width:              source.Width,
+			Height:             source.Height,
+			OriginCountry:      source.DestinationCountry,
+			DestinationCountry: source.OriginCountry,
+			ServiceLevel:       ds.LevelStandard,
+			ReturnOfID:         &source.ID,
+			ReturnReason:       req.Reason,
+			ReturnTariff:       tariff.Name,
+			ReturnDiscount:     tariff.Discount,
+		}
+		if err := tx.Omit(clause.Associations).Create(&draft).Error; err != nil {
+			return err
+		}
+		draftID = draft.ID
+
+		// Возвращается ли весь груз заявки одним возвратом
+		full := true
+		for _, line := range source.Services {
+			if line.Accepted() && (returned[line.ID] > 0 || quantities[line.ID] != line.Quantity) {
+				full = false
+			}
+		}
+
+		for _, line := range source.Services {
+			qty := quantities[line.ID]
+			if qty == 0 {
+				continue
+			}
+			lineID := line.ID
+			returnLine := ds.OrderService{
+				OrderID:        draft.ID,
+				ServiceID:      line.ServiceID,
+				Quantity:       qty,
+				Comment:        line.Comment,
+				Order:          line.Order,
+				FromCity:       line.ToCity,
+				ToCity:         line.FromCity,
+				Weight:         line.Weight,
+				Length:         line.Length,
+				Width:          line.Width,
+				Height:         line.Height,
+				ReturnOfLineID: &lineID,
+			}
+			if err := tx.Omit("Service").Create(&returnLine).Error; err != nil {
+				return err
+			}
+		}
+
+		// Таможенные позиции переносятся для международного возврата и при необходимости правятся клиентом
+		for _, item := range source.CustomsItems {
+			item.ID = 0
+			item.OrderID = draft.ID
+			if err := tx.Create(&item).Error; err != nil {
+				return err
+			}
+		}
+
+		// Маршрут с остановками проходится в обратном порядке только при возврате всего груза,
+		// частичный возврат везётся напрямую из последней остановки в первую
+		if full {
+			for i := len(source.Stops) - 1; i >= 0; i-- {
+				stop := source.Stops[i]
+				stop.ID = 0
+				stop.OrderID = draft.ID
+				stop.Seq = len(source.Stops) - i
+				stop.WindowFrom, stop.WindowTo = nil, nil
+				if stop.Kind == ds.StopPickup {
+					stop.Kind = ds.StopDropoff
+				} else {
+					stop.Kind = ds.StopPickup
+				}
+				if err := tx.Create(&stop).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		if err := repriceDraftTx(tx, draft.ID); err != nil {
+			return err
+		}
+
+		return recordOrderEvents(tx,
+			ds.OrderEvent{
+				OrderID:  draft.ID,
+				ActorID:  actorID(actor),
+				Type:     ds.EventReturnCreated,
+				Field:    "return_of_id",
+				NewValue: strconv.Itoa(source.ID),
+				Reason:   req.Reason,
+			},
+			ds.OrderEvent{
+				OrderID:  source.ID,
+				ActorID:  actorID(actor),
+				Type:     ds.EventReturnCreated,
+				Field:    "returns",
+				NewValue: strconv.Itoa(draft.ID),
+				Reason:   req.Reason,
+			},
+		)
+	})
+	if err != nil {
+		return ds.Order{}, err
+	}
+	return r.GetOrder(draftID)
+}
+
+// GetOrderReturns - возвраты, созданные по заявке
+func (r *Repository) GetOrderReturns(orderID int) ([]ReturnSummary, error) {
+	var returns []ReturnSummary
+	err := r.db.Model(&ds.Order{}).
+		Select("id, status, return_reason, return_tariff, total_cost, created_at").
+		Where("return_of_id = ? AND deleted_at IS NULL", orderID).
+		Order("created_at, id").Scan(&returns).Error
+	return returns, err
+}
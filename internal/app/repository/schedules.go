@@ -0,0 +1,335 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/schedule"
+	"rip-go-app/internal/app/workflow"
+)
+
+// maxLeadDays - максимальное упреждение создания заявок по расписанию
+const maxLeadDays = 60
+
+// ErrScheduleNotFound - расписание не найдено
+var ErrScheduleNotFound = errors.New("расписание не найдено")
+
+// scheduleRule - правило повторения расписания в его часовом поясе
+func scheduleRule(s *ds.Schedule) (*schedule.Rule, error) {
+	loc := time.Local
+	if s.Timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(s.Timezone); err != nil {
+			return nil, fmt.Errorf("неизвестный часовой пояс: %s", s.Timezone)
+		}
+	}
+
+	rule := &schedule.Rule{
+		Kind:      s.RuleKind,
+		Weekdays:  s.Weekdays,
+		MonthDay:  s.MonthDay,
+		TimeOfDay: s.TimeOfDay,
+		Cron:      s.Cron,
+		Location:  loc,
+	}
+	if err := rule.Compile(); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+// validateSchedule - проверка шаблона и правила расписания
+func validateSchedule(s *ds.Schedule) error {
+	s.Name = strings.TrimSpace(s.Name)
+	if s.Name == "" {
+		return fmt.Errorf("не указано название расписания")
+	}
+	if s.FromCity == "" || s.ToCity == "" {
+		return fmt.Errorf("не указан маршрут")
+	}
+	if len(s.Items) == 0 {
+		return fmt.Errorf("в шаблоне нет услуг")
+	}
+	for _, it := range s.Items {
+		if it.ServiceID <= 0 || it.Weight <= 0 || it.Length <= 0 || it.Width <= 0 || it.Height <= 0 {
+			return fmt.Errorf("для каждой услуги нужны вес и габариты груза")
+		}
+	}
+	if s.LeadDays < 0 || s.LeadDays > maxLeadDays {
+		return fmt.Errorf("упреждение должно быть от 0 до %d дней", maxLeadDays)
+	}
+	if s.StartsAt != nil && s.EndsAt != nil && s.EndsAt.Before(*s.StartsAt) {
+		return fmt.Errorf("дата окончания раньше даты начала")
+	}
+
+	customsData := CustomsData{
+		OriginCountry:      s.OriginCountry,
+		DestinationCountry: s.DestinationCountry,
+		Incoterms:          s.Incoterms,
+		Items:              s.CustomsItems,
+	}
+	if err := customsData.normalize(); err != nil {
+		return err
+	}
+	s.OriginCountry, s.DestinationCountry, s.Incoterms = customsData.OriginCountry, customsData.DestinationCountry, customsData.Incoterms
+
+	_, err := scheduleRule(s)
+	return err
+}
+
+// skipDate - исключение праздников, если расписание их пропускает
+func (r *Repository) skipDate(s *ds.Schedule) func(time.Time) bool {
+	if !s.SkipHolidays {
+		return nil
+	}
+	return r.businessCalendar().IsHoliday
+}
+
+// nextOccurrence - ближайшая дата отправки после after с учётом начала и окончания расписания
+func nextOccurrence(s *ds.Schedule, rule *schedule.Rule, after time.Time) *time.Time {
+	if s.StartsAt != nil && after.Before(*s.StartsAt) {
+		after = s.StartsAt.Add(-time.Minute)
+	}
+	next := rule.Next(after)
+	if next.IsZero() || (s.EndsAt != nil && next.After(*s.EndsAt)) {
+		return nil
+	}
+	return &next
+}
+
+// PreviewSchedule - ближайшие n дат отправки (праздники исключаются, если они пропускаются)
+func (r *Repository) PreviewSchedule(s *ds.Schedule, n int) ([]time.Time, error) {
+	rule, err := scheduleRule(s)
+	if err != nil {
+		return nil, err
+	}
+
+	after := time.Now()
+	if s.StartsAt != nil && after.Before(*s.StartsAt) {
+		after = s.StartsAt.Add(-time.Minute)
+	}
+
+	dates := rule.Preview(after, n, r.skipDate(s))
+	for i, d := range dates {
+		if s.EndsAt != nil && d.After(*s.EndsAt) {
+			return dates[:i], nil
+		}
+	}
+	return dates, nil
+}
+
+// CreateSchedule - создание расписания регулярной отправки
+func (r *Repository) CreateSchedule(s *ds.Schedule) error {
+	if err := validateSchedule(s); err != nil {
+		return err
+	}
+	rule, _ := scheduleRule(s)
+
+	s.ID = 0
+	s.LastOrderID = nil
+	s.NextRunAt = nextOccurrence(s, rule, time.Now())
+	return r.db.Create(s).Error
+}
+
+// GetSchedules - расписания пользователя (creatorID = nil - все)
+func (r *Repository) GetSchedules(creatorID *int) ([]ds.Schedule, error) {
+	query := r.db.Order("id")
+	if creatorID != nil {
+		query = query.Where("creator_id = ?", *creatorID)
+	}
+	var schedules []ds.Schedule
+	err := query.Find(&schedules).Error
+	return schedules, err
+}
+
+// GetSchedule - расписание по ID
+func (r *Repository) GetSchedule(id int) (ds.Schedule, error) {
+	var s ds.Schedule
+	if err := r.db.Where("id = ?", id).First(&s).Error; err != nil {
+		return ds.Schedule{}, ErrScheduleNotFound
+	}
+	return s, nil
+}
+
+// UpdateSchedule - изменение шаблона и правила; даты пересчитываются от текущего момента
+func (r *Repository) UpdateSchedule(s *ds.Schedule) error {
+	existing, err := r.GetSchedule(s.ID)
+	if err != nil {
+		return err
+	}
+	if err := validateSchedule(s); err != nil {
+		return err
+	}
+	rule, _ := scheduleRule(s)
+
+	s.CreatorID = existing.CreatorID
+	s.LastOrderID = existing.LastOrderID
+	s.Paused = existing.Paused
+	s.CreatedAt = existing.CreatedAt
+	s.NextRunAt = nextOccurrence(s, rule, time.Now())
+	return r.db.Save(s).Error
+}
+
+// DeleteSchedule - удаление расписания (созданные заявки сохраняются)
+func (r *Repository) DeleteSchedule(id int) error {
+	res := r.db.Delete(&ds.Schedule{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrScheduleNotFound
+	}
+	return nil
+}
+
+// SetSchedulePaused - пауза и возобновление; после паузы пропущенные даты не создаются
+func (r *Repository) SetSchedulePaused(id int, paused bool) (ds.Schedule, error) {
+	s, err := r.GetSchedule(id)
+	if err != nil {
+		return ds.Schedule{}, err
+	}
+
+	updates := map[string]interface{}{"paused": paused}
+	if !paused {
+		updates["last_error"] = ""
+		rule, err := scheduleRule(&s)
+		if err != nil {
+			return ds.Schedule{}, err
+		}
+		updates["next_run_at"] = nextOccurrence(&s, rule, time.Now())
+	}
+	if err := r.db.Model(&s).Updates(updates).Error; err != nil {
+		return ds.Schedule{}, err
+	}
+	return r.GetSchedule(id)
+}
+
+// RunSchedules - создание заявок по расписаниям, дата отправки которых наступает в пределах упреждения.
+// Расписание, по которому не удалось создать заявку, приостанавливается с причиной в LastError,
+// остальные расписания обрабатываются; ошибки возвращаются вместе
+func (r *Repository) RunSchedules(now time.Time) (int, error) {
+	var ids []int
+	err := r.db.Model(&ds.Schedule{}).
+		Where("paused = ? AND next_run_at IS NOT NULL", false).
+		Where("next_run_at - make_interval(days => lead_days) <= ?", now).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return 0, err
+	}
+
+	created := 0
+	var errs []error
+	for _, id := range ids {
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			n, err := r.runScheduleTx(tx, id, now)
+			if err == nil {
+				created += n
+			}
+			return err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("расписание %d приостановлено: %w", id, err))
+			pause := map[string]interface{}{"paused": true, "last_error": err.Error()}
+			if err := r.db.Model(&ds.Schedule{}).Where("id = ?", id).Updates(pause).Error; err != nil {
+				errs = append(errs, fmt.Errorf("расписание %d: %w", id, err))
+			}
+		}
+	}
+	return created, errors.Join(errs...)
+}
+
+// runScheduleTx - создание заявок одного расписания и перенос даты следующего запуска
+func (r *Repository) runScheduleTx(tx *gorm.DB, scheduleID int, now time.Time) (int, error) {
+	var s ds.Schedule
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", scheduleID).First(&s).Error; err != nil {
+		return 0, nil
+	}
+	if s.Paused || s.NextRunAt == nil {
+		return 0, nil
+	}
+
+	rule, err := scheduleRule(&s)
+	if err != nil {
+		return 0, err
+	}
+	skip := r.skipDate(&s)
+	lead := time.Duration(s.LeadDays) * 24 * time.Hour
+
+	created := 0
+	for s.NextRunAt != nil && !s.NextRunAt.Add(-lead).After(now) {
+		date := *s.NextRunAt
+		// Прошедшие (например, во время простоя) и праздничные даты пропускаются
+		if date.After(now) && (skip == nil || !skip(date)) {
+			order, err := r.createScheduledOrderTx(tx, &s, date)
+			if err != nil {
+				return created, err
+			}
+			s.LastOrderID = &order.ID
+			created++
+		}
+		s.NextRunAt = nextOccurrence(&s, rule, date)
+	}
+
+	return created, tx.Model(&s).Select("next_run_at", "last_order_id").Updates(&s).Error
+}
+
+// createScheduledOrderTx - заявка по шаблону расписания на дату отправки
+func (r *Repository) createScheduledOrderTx(tx *gorm.DB, s *ds.Schedule, date time.Time) (ds.Order, error) {
+	items := make([]CargoOrderItem, 0, len(s.Items))
+	for _, it := range s.Items {
+		items = append(items, CargoOrderItem{
+			ServiceID: it.ServiceID,
+			FromCity:  s.FromCity,
+			ToCity:    s.ToCity,
+			Length:    it.Length,
+			Width:     it.Width,
+			Height:    it.Height,
+			Weight:    it.Weight,
+		})
+	}
+
+	customsItems := make([]ds.CustomsItem, len(s.CustomsItems))
+	copy(customsItems, s.CustomsItems)
+	customsData := CustomsData{
+		OriginCountry:      s.OriginCountry,
+		DestinationCountry: s.DestinationCountry,
+		Incoterms:          s.Incoterms,
+		Items:              customsItems,
+	}
+	if err := customsData.normalize(); err != nil {
+		return ds.Order{}, err
+	}
+
+	order, err := r.createCargoOrderTx(tx, items, customsData, ds.LevelStandard, s.CreatorID)
+	if err != nil {
+		return ds.Order{}, err
+	}
+
+	order.ScheduleID = &s.ID
+	order.ScheduledFor = &date
+	if err := tx.Model(&order).Select("schedule_id", "scheduled_for").Updates(&order).Error; err != nil {
+		return ds.Order{}, err
+	}
+
+	if !s.AutoForm {
+		return order, nil
+	}
+
+	var creator ds.User
+	if err := tx.Where("id = ?", s.CreatorID).First(&creator).Error; err != nil {
+		return order, nil
+	}
+	actor := workflow.Actor{UserID: creator.ID, Role: creator.Role}
+	formed, err := r.transitionOrderTx(tx, order.ID, ds.StatusFormed, actor, 0, "schedule", nil)
+	var transitionErr *workflow.TransitionError
+	if errors.As(err, &transitionErr) || errors.Is(err, workflow.ErrForbidden) {
+		// Не удалось сформировать - заявка остаётся черновиком для ручной доработки
+		return order, nil
+	}
+	return formed, err
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"rip-go-app/internal/app/ds"
+)
+
+// searchLimit - максимум результатов по каждому типу сущности
+const searchLimit = 20
+
+// Поисковые документы: одинаковые выражения используются в индексах и запросах,
+// иначе PostgreSQL не сможет применить индекс
+const (
+	orderSearchDoc   = "(coalesce(from_city, '') || ' ' || coalesce(to_city, '') || ' ' || coalesce(tracking_number, ''))"
+	userSearchDoc    = "(coalesce(name, '') || ' ' || coalesce(login, ''))"
+	commentSearchDoc = "coalesce(comment, '')"
+	serviceSearchDoc = "(coalesce(name, '') || ' ' || coalesce(description, ''))"
+)
+
+// searchConfigs - словари полнотекстового поиска
+var searchConfigs = []string{"russian", "english"}
+
+// SearchIndexDDL - расширение pg_trgm и GIN-индексы для полнотекстового и нечёткого поиска
+func SearchIndexDDL() []string {
+	stmts := []string{"CREATE EXTENSION IF NOT EXISTS pg_trgm"}
+	docs := []struct{ table, name, doc string }{
+		{"orders", "orders", orderSearchDoc},
+		{"users", "users", userSearchDoc},
+		{"order_services", "order_comments", commentSearchDoc},
+		{"services", "services", serviceSearchDoc},
+	}
+	for _, d := range docs {
+		for _, cfg := range searchConfigs {
+			stmts = append(stmts, fmt.Sprintf(
+				"CREATE INDEX IF NOT EXISTS idx_%s_fts_%s ON %s USING GIN (to_tsvector('%s', %s))",
+				d.name, cfg, d.table, cfg, d.doc))
+		}
+		stmts = append(stmts, fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS idx_%s_trgm ON %s USING GIN (%s gin_trgm_ops)",
+			d.name, d.table, d.doc))
+	}
+	return stmts
+}
+
+// matchExpr - условие совпадения документа: полнотекстовое (ru/en) или нечёткое по триграммам
+func matchExpr(doc string) string {
+	parts := make([]string, 0, len(searchConfigs)+1)
+	for _, cfg := range searchConfigs {
+		parts = append(parts, fmt.Sprintf("to_tsvector('%s', %s) @@ websearch_to_tsquery('%s', @q)", cfg, doc, cfg))
+	}
+	parts = append(parts, fmt.Sprintf("@q <%% %s", doc))
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
+
+// rankExpr - релевантность документа
+func rankExpr(doc string) string {
+	parts := make([]string, 0, len(searchConfigs)+1)
+	for _, cfg := range searchConfigs {
+		parts = append(parts, fmt.Sprintf("ts_rank(to_tsvector('%s', %s), websearch_to_tsquery('%s', @q))", cfg, doc, cfg))
+	}
+	parts = append(parts, fmt.Sprintf("word_similarity(@q, %s)", doc))
+	return "GREATEST(" + strings.Join(parts, ", ") + ")"
+}
+
+// Маркеры подсветки в ts_headline: документ не экранирован, поэтому <mark> подставляется
+// только после экранирования фрагмента в highlightSnippet
+const (
+	headlineStart = "\x02"
+	headlineStop  = "\x03"
+)
+
+// headlineExpr - фрагмент документа с подсветкой найденных слов
+func headlineExpr(doc string) string {
+	return fmt.Sprintf("ts_headline('russian', %s, websearch_to_tsquery('russian', @q), "+
+		"'StartSel=%s, StopSel=%s, MaxWords=25, MinWords=8, MaxFragments=2')", doc, headlineStart, headlineStop)
+}
+
+// highlightSnippet - HTML-фрагмент из ts_headline: текст экранируется, маркеры заменяются на <mark>.
+// Маркеры, попавшие в сам документ, не ломают разметку: лишние отбрасываются
+func highlightSnippet(snippet string) string {
+	var b strings.Builder
+	open := false
+	for _, r := range html.EscapeString(snippet) {
+		switch string(r) {
+		case headlineStart:
+			if !open {
+				b.WriteString("<mark>")
+				open = true
+			}
+		case headlineStop:
+			if open {
+				b.WriteString("</mark>")
+				open = false
+			}
+		default:
+			b.WriteRune(r)
+		}
+	}
+	if open {
+		b.WriteString("</mark>")
+	}
+	return b.String()
+}
+
+// SearchHit - найденная сущность
+type SearchHit struct {
+	Type    string  `json:"type"`
+	ID      int     `json:"id"`
+	OrderID int     `json:"order_id,omitempty"`
+	Title   string  `json:"title"`
+	Snippet string  `json:"snippet"`
+	Rank    float64 `json:"rank"`
+}
+
+// SearchResults - результаты поиска, сгруппированные по типу сущности
+type SearchResults struct {
+	Orders   []SearchHit `json:"orders"`
+	Comments []SearchHit `json:"comments"`
+	Services []SearchHit `json:"services"`
+}
+
+// Search - полнотекстовый поиск по заявкам, комментариям к услугам заявок и услугам.
+// creatorID ограничивает заявки и комментарии заявками пользователя (nil - все заявки)
+func (r *Repository) Search(q string, creatorID *int) (SearchResults, error) {
+	results := SearchResults{Orders: []SearchHit{}, Comments: []SearchHit{}, Services: []SearchHit{}}
+	q = strings.TrimSpace(q)
+	if q == "" {
+		return results, nil
+	}
+
+	args := map[string]interface{}{"q": q, "limit": searchLimit, "draft": ds.StatusDraft}
+	scope := ""
+	if creatorID != nil {
+		scope = " AND o.creator_id = @creator"
+		args["creator"] = *creatorID
+	}
+
+	// Заявки: маршрут, номер отслеживания, имя и логин клиента
+	orderDoc := qualify(orderSearchDoc, "o") + " || ' ' || " + qualify(userSearchDoc, "u")
+	ordersSQL := fmt.Sprintf(`
+        SELECT 'order' AS type, o.id, o.id AS order_id,
+            concat_ws(' → ', o.from_city, o.to_city) AS title,
+            %s AS snippet,
+            GREATEST(%s, %s) AS rank
+        FROM orders o JOIN users u ON u.id = o.creator_id
+        WHERE o.deleted_at IS NULL AND o.status != @draft%s
+            AND (%s OR o.creator_id IN (SELECT id FROM users WHERE %s))
+        ORDER BY rank DESC, o.id DESC
+        LIMIT @limit`,
+		headlineExpr(orderDoc), rankExpr(qualify(orderSearchDoc, "o")), rankExpr(qualify(userSearchDoc, "u")),
+		scope, matchExpr(qualify(orderSearchDoc, "o")), matchExpr(userSearchDoc))
+
+	// Комментарии к услугам в заявках
+	commentDoc := qualify(commentSearchDoc, "os")
+	commentsSQL := fmt.Sprintf(`
+        SELECT 'comment' AS type, os.id, os.order_id,
+            s.name AS title,
+            %s AS snippet,
+            %s AS rank
+        FROM order_services os
+            JOIN orders o ON o.id = os.order_id
+            JOIN services s ON s.id = os.service_id
+        WHERE o.deleted_at IS NULL AND o.status != @draft%s AND %s
+        ORDER BY rank DESC, os.id DESC
+        LIMIT @limit`,
+		headlineExpr(commentDoc), rankExpr(commentDoc), scope, matchExpr(commentDoc))
+
+	// Услуги каталога
+	servicesSQL := fmt.Sprintf(`
+        SELECT 'service' AS type, id, name AS title,
+            %s AS snippet,
+            %s AS rank
+        FROM services
+        WHERE deleted_at IS NULL AND %s
+        ORDER BY rank DESC, id
+        LIMIT @limit`,
+		headlineExpr(serviceSearchDoc), rankExpr(serviceSearchDoc), matchExpr(serviceSearchDoc))
+
+	queries := []struct {
+		sql  string
+		dest *[]SearchHit
+	}{
+		{ordersSQL, &results.Orders},
+		{commentsSQL, &results.Comments},
+		{servicesSQL, &results.Services},
+	}
+	for _, query := range queries {
+		if err := r.db.Raw(query.sql, args).Scan(query.dest).Error; err != nil {
+			return SearchResults{}, err
+		}
+		for i := range *query.dest {
+			(*query.dest)[i].Snippet = highlightSnippet((*query.dest)[i].Snippet)
+		}
+	}
+	return results, nil
+}
+
+// qualify - добавление псевдонима таблицы к колонкам поискового документа
+func qualify(doc, alias string) string {
+	for _, column := range []string{"from_city", "to_city", "tracking_number", "name", "login", "comment"} {
+		doc = strings.ReplaceAll(doc, "coalesce("+column+",", "coalesce("+alias+"."+column+",")
+	}
+	return doc
+}
@@ -0,0 +1,509 @@
+package repository
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"rip-go-app/internal/app/calendar"
+	"rip-go-app/internal/app/ds"
+)
+
+// SetCalendar - рабочий календарь для расчёта сроков SLA
+func (r *Repository) SetCalendar(cal *calendar.Calendar) {
+	r.calendar = cal
+}
+
+// defaultCalendar - календарь по умолчанию: пн-пт 9-18
+var defaultCalendar = calendar.NewCalendar(9, 18, "")
+
+// businessCalendar - действующий рабочий календарь
+func (r *Repository) businessCalendar() *calendar.Calendar {
+	if r.calendar == nil {
+		return defaultCalendar
+	}
+	return r.calendar
+}
+
+// ==================== ПОЛИТИКИ SLA ====================
+
+// GetSLAPolicies - все политики SLA
+func (r *Repository) GetSLAPolicies() ([]ds.SLAPolicy, error) {
+	var policies []ds.SLAPolicy
+	err := r.db.Order("id").Find(&policies).Error
+	return policies, err
+}
+
+// validateSLAPolicy - проверка и нормализация политики
+func validateSLAPolicy(policy *ds.SLAPolicy) error {
+	if strings.TrimSpace(policy.Name) == "" {
+		return fmt.Errorf("не указано название политики")
+	}
+	if policy.ResponseHours <= 0 {
+		return fmt.Errorf("срок ответа должен быть больше 0")
+	}
+	if policy.WarnPercent <= 0 || policy.WarnPercent >= 100 {
+		policy.WarnPercent = 80
+	}
+
+	var actions []string
+	for _, a := range strings.Split(policy.Escalation, ",") {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		known := false
+		for _, k := range ds.EscalationActions {
+			if a == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return fmt.Errorf("неизвестное действие эскалации: %s", a)
+		}
+		actions = append(actions, a)
+	}
+	policy.Escalation = strings.Join(actions, ",")
+	return nil
+}
+
+// CreateSLAPolicy - создание политики SLA
+func (r *Repository) CreateSLAPolicy(policy *ds.SLAPolicy) error {
+	if err := validateSLAPolicy(policy); err != nil {
+		return err
+	}
+	policy.ID = 0
+	return r.db.Create(policy).Error
+}
+
+// UpdateSLAPolicy - изменение политики SLA (на уже сформированные заявки не влияет)
+func (r *Repository) UpdateSLAPolicy(policy *ds.SLAPolicy) error {
+	if err := validateSLAPolicy(policy); err != nil {
+		return err
+	}
+	res := r.db.Model(policy).Select("*").Omit("created_at").Updates(policy)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("политика не найдена")
+	}
+	return nil
+}
+
+// DeleteSLAPolicy - удаление политики SLA
+func (r *Repository) DeleteSLAPolicy(id int) error {
+	res := r.db.Delete(&ds.SLAPolicy{}, id)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("политика не найдена")
+	}
+	return nil
+}
+
+// matchSLAPolicy - наиболее специфичная политика для заявки:
+// клиент важнее услуги, услуга важнее политики по умолчанию; при равенстве - кратчайший срок
+func matchSLAPolicy(policies []ds.SLAPolicy, order *ds.Order) *ds.SLAPolicy {
+	var best *ds.SLAPolicy
+	bestScore := -1
+	for i := range policies {
+		p := &policies[i]
+		score := 0
+		if p.CustomerID != nil {
+			if *p.CustomerID != order.CreatorID {
+				continue
+			}
+			score += 2
+		}
+		if p.ServiceID != nil {
+			found := false
+			for _, s := range order.Services {
+				if s.ServiceID == *p.ServiceID {
+					found = true
+					break
+				}
+			}
+			if !found {
+				continue
+			}
+			score++
+		}
+		if score > bestScore || (score == bestScore && p.ResponseHours < best.ResponseHours) {
+			best, bestScore = p, score
+		}
+	}
+	return best
+}
+
+// applySLATx - расчёт срока SLA при формировании и итога SLA при решении по заявке
+func (r *Repository) applySLATx(tx *gorm.DB, order *ds.Order) error {
+	switch order.Status {
+	case ds.StatusFormed:
+		var policies []ds.SLAPolicy
+		if err := tx.Where("active = ?", true).Find(&policies).Error; err != nil {
+			return err
+		}
+		policy := matchSLAPolicy(policies, order)
+		if policy == nil || order.FormedAt == nil {
+			return nil
+		}
+
+		cal := r.businessCalendar()
+		warnAt := cal.AddBusinessHours(*order.FormedAt, policy.ResponseHours*float64(policy.WarnPercent)/100)
+		dueAt := cal.AddBusinessHours(*order.FormedAt, policy.ResponseHours)
+		order.SLAPolicyID = &policy.ID
+		order.SLAWarnAt = &warnAt
+		order.SLADueAt = &dueAt
+		order.SLAStatus = ds.SLAPending
+	case ds.StatusCompleted, ds.StatusRejected:
+		if status := slaOutcome(order); status != "" {
+			order.SLAStatus = status
+		}
+	}
+	return nil
+}
+
+// slaOutcome - итог SLA решённой заявки: met, если решение принято не позже срока ("" - у заявки нет срока)
+func slaOutcome(order *ds.Order) string {
+	if order.SLADueAt == nil || order.CompletedAt == nil {
+		return ""
+	}
+	if order.CompletedAt.After(*order.SLADueAt) {
+		return ds.SLAMissed
+	}
+	return ds.SLAMet
+}
+
+// nextSLAStatus - состояние SLA сформированной заявки на момент now ("" - без изменений):
+// после срока - breached, после момента предупреждения - warning. Нарушение не повторяется,
+// а предупреждение после нарушения не выдаётся
+func nextSLAStatus(order *ds.Order, now time.Time) string {
+	if order.Status != ds.StatusFormed || order.SLADueAt == nil {
+		return ""
+	}
+	switch {
+	case order.SLAStatus != ds.SLABreached && !now.Before(*order.SLADueAt):
+		return ds.SLABreached
+	case order.SLAStatus == ds.SLAPending && order.SLAWarnAt != nil && !now.Before(*order.SLAWarnAt):
+		return ds.SLAWarning
+	}
+	return ""
+}
+
+// ==================== ПРОВЕРКА И ЭСКАЛАЦИЯ ====================
+
+// SLACheckResult - итог проверки сроков
+type SLACheckResult struct {
+	Warned   int `json:"warned"`
+	Breached int `json:"breached"`
+}
+
+// CheckSLA - предупреждения о подходящих сроках и эскалация нарушенных
+func (r *Repository) CheckSLA(now time.Time) (SLACheckResult, error) {
+	var result SLACheckResult
+
+	var ids []int
+	err := r.db.Model(&ds.Order{}).
+		Where("deleted_at IS NULL AND status = ? AND sla_status IN ?", ds.StatusFormed, []string{ds.SLAPending, ds.SLAWarning}).
+		Where("(sla_warn_at <= ? OR sla_due_at <= ?)", now, now).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return result, err
+	}
+
+	for _, id := range ids {
+		var changed string
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			changed, err = r.checkOrderSLATx(tx, id, now)
+			return err
+		})
+		if err != nil {
+			return result, err
+		}
+		switch changed {
+		case ds.SLAWarning:
+			result.Warned++
+		case ds.SLABreached:
+			result.Breached++
+		}
+	}
+	return result, nil
+}
+
+// checkOrderSLATx - проверка одной заявки, возвращает новое состояние SLA ("" - без изменений)
+func (r *Repository) checkOrderSLATx(tx *gorm.DB, orderID int, now time.Time) (string, error) {
+	var order ds.Order
+	res := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ? AND deleted_at IS NULL", orderID).Limit(1).Find(&order)
+	if res.Error != nil {
+		return "", res.Error
+	}
+	// заявка могла быть удалена или решена после выборки
+	status := nextSLAStatus(&order, now)
+	if res.RowsAffected == 0 || status == "" {
+		return "", nil
+	}
+
+	before := order
+	order.SLAStatus = status
+	var notifications []ds.Notification
+	var actions []string
+
+	switch status {
+	case ds.SLABreached:
+		// удалённая политика не отменяет нарушение, эскалации просто нет
+		var policy ds.SLAPolicy
+		if order.SLAPolicyID != nil {
+			if err := tx.Where("id = ?", *order.SLAPolicyID).Limit(1).Find(&policy).Error; err != nil {
+				return "", err
+			}
+		}
+		message := fmt.Sprintf("Нарушен срок обработки заявки №%d (до %s)", order.ID, order.SLADueAt.Format("02.01.2006 15:04"))
+
+		for _, action := range strings.Split(policy.Escalation, ",") {
+			switch action {
+			case ds.EscalatePriority:
+				order.Priority = true
+			case ds.EscalateReassign:
+				managerID, err := pickManagerTx(tx, AssignWorkload, order.AssigneeID)
+				if err != nil {
+					return "", err
+				}
+				if managerID == 0 {
+					continue
+				}
+				claimedAt := now
+				order.AssigneeID = &managerID
+				order.ClaimedAt = &claimedAt
+				order.ClaimExpiresAt = nil
+			case ds.EscalateNotifyAdmin:
+				var admins []int
+				if err := tx.Model(&ds.User{}).Where("role = ?", ds.RoleAdmin).Pluck("id", &admins).Error; err != nil {
+					return "", err
+				}
+				for _, adminID := range admins {
+					notifications = append(notifications, newNotification(adminID, order.ID, ds.NotifySLABreach, message))
+				}
+			default:
+				continue
+			}
+			actions = append(actions, action)
+		}
+		assignee, err := assigneeNotificationsTx(tx, &order, ds.NotifySLABreach, message)
+		if err != nil {
+			return "", err
+		}
+		notifications = append(notifications, assignee...)
+	case ds.SLAWarning:
+		message := fmt.Sprintf("Срок обработки заявки №%d истекает %s", order.ID, order.SLADueAt.Format("02.01.2006 15:04"))
+		assignee, err := assigneeNotificationsTx(tx, &order, ds.NotifySLAWarning, message)
+		if err != nil {
+			return "", err
+		}
+		notifications = append(notifications, assignee...)
+	}
+
+	if err := saveOrder(tx, &order, 0); err != nil {
+		return "", err
+	}
+
+	events := []ds.OrderEvent{{
+		OrderID:  order.ID,
+		Type:     ds.EventSLA,
+		Field:    "sla_status",
+		OldValue: before.SLAStatus,
+		NewValue: order.SLAStatus,
+		Reason:   strings.Join(actions, ","),
+	}}
+	if assigneeValue(before.AssigneeID) != assigneeValue(order.AssigneeID) {
+		events = append(events, ds.OrderEvent{
+			OrderID:  order.ID,
+			Type:     ds.EventAssignment,
+			Field:    "assignee_id",
+			OldValue: assigneeValue(before.AssigneeID),
+			NewValue: assigneeValue(order.AssigneeID),
+			Reason:   "sla_escalation",
+		})
+	}
+	if err := recordOrderEvents(tx, events...); err != nil {
+		return "", err
+	}
+	if len(notifications) > 0 {
+		if err := tx.Create(&notifications).Error; err != nil {
+			return "", err
+		}
+	}
+	return order.SLAStatus, nil
+}
+
+// assigneeNotificationsTx - уведомление ответственного, а без него - всех менеджеров
+func assigneeNotificationsTx(tx *gorm.DB, order *ds.Order, kind, message string) ([]ds.Notification, error) {
+	if order.AssigneeID != nil {
+		return []ds.Notification{newNotification(*order.AssigneeID, order.ID, kind, message)}, nil
+	}
+
+	var managers []int
+	if err := tx.Model(&ds.User{}).Where("role = ?", ds.RoleManager).Pluck("id", &managers).Error; err != nil {
+		return nil, err
+	}
+	notifications := make([]ds.Notification, 0, len(managers))
+	for _, id := range managers {
+		notifications = append(notifications, newNotification(id, order.ID, kind, message))
+	}
+	return notifications, nil
+}
+
+func newNotification(userID, orderID int, kind, message string) ds.Notification {
+	return ds.Notification{UserID: userID, OrderID: &orderID, Type: kind, Message: message}
+}
+
+// ==================== УВЕДОМЛЕНИЯ ====================
+
+// GetNotifications - уведомления пользователя, новые сверху
+func (r *Repository) GetNotifications(userID int, unreadOnly bool) ([]ds.Notification, error) {
+	query := r.db.Where("user_id = ?", userID)
+	if unreadOnly {
+		query = query.Where("read_at IS NULL")
+	}
+	var notifications []ds.Notification
+	err := query.Order("created_at DESC, id DESC").Limit(100).Find(&notifications).Error
+	return notifications, err
+}
+
+// MarkNotificationRead - отметка уведомления прочитанным
+func (r *Repository) MarkNotificationRead(id, userID int) error {
+	res := r.db.Model(&ds.Notification{}).Where("id = ? AND user_id = ?", id, userID).
+		Update("read_at", gorm.Expr("COALESCE(read_at, ?)", time.Now()))
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return fmt.Errorf("уведомление не найдено")
+	}
+	return nil
+}
+
+// ==================== ОТЧЁТ ====================
+
+// Группировки отчёта по SLA
+const (
+	SLAByModerator = "moderator"
+	SLAByPeriod    = "period"
+)
+
+// SLAReportRow - строка отчёта о соблюдении SLA
+type SLAReportRow struct {
+	ModeratorID      *int       `json:"moderator_id,omitempty"`
+	Moderator        string     `json:"moderator,omitempty"`
+	Period           *time.Time `json:"period,omitempty"`
+	Total            int        `json:"total"`
+	Met              int        `json:"met"`
+	Missed           int        `json:"missed"`
+	Compliance       float64    `json:"compliance"`         // доля обработанных в срок, %
+	AvgResponseHours float64    `json:"avg_response_hours"` // среднее время ответа в рабочих часах
+}
+
+// slaReportSource - обработанная заявка с SLA для отчёта
+type slaReportSource struct {
+	ModeratorID *int
+	Moderator   string
+	FormedAt    time.Time
+	CompletedAt time.Time
+	SLAStatus   string
+}
+
+// SLAReport - соблюдение SLA за период [from, to) по модераторам или по интервалам (day, week, month)
+func (r *Repository) SLAReport(from, to time.Time, groupBy, interval string) ([]SLAReportRow, error) {
+	if groupBy != SLAByModerator && groupBy != SLAByPeriod {
+		return nil, fmt.Errorf("неизвестная группировка отчёта: %s", groupBy)
+	}
+	if groupBy == SLAByPeriod && interval != "day" && interval != "week" && interval != "month" {
+		return nil, fmt.Errorf("неизвестный интервал отчёта: %s", interval)
+	}
+
+	var sources []slaReportSource
+	err := r.db.Table("orders o").
+		Select("o.moderator_id, COALESCE(u.name, '') AS moderator, o.formed_at, o.completed_at, o.sla_status").
+		Joins("LEFT JOIN users u ON u.id = o.moderator_id").
+		Where("o.deleted_at IS NULL AND o.sla_status IN ?", []string{ds.SLAMet, ds.SLAMissed}).
+		Where("o.completed_at >= ? AND o.completed_at < ?", from, to).
+		Scan(&sources).Error
+	if err != nil {
+		return nil, err
+	}
+
+	cal := r.businessCalendar()
+	rows := map[string]*SLAReportRow{}
+	var keys []string
+	for _, src := range sources {
+		var key string
+		row := SLAReportRow{}
+		if groupBy == SLAByModerator {
+			row.ModeratorID, row.Moderator = src.ModeratorID, src.Moderator
+			key = assigneeValue(src.ModeratorID)
+		} else {
+			period := truncatePeriod(src.CompletedAt.In(cal.Location), interval)
+			row.Period = &period
+			key = period.Format(time.RFC3339)
+		}
+
+		acc, ok := rows[key]
+		if !ok {
+			acc = &row
+			rows[key] = acc
+			keys = append(keys, key)
+		}
+		acc.Total++
+		if src.SLAStatus == ds.SLAMet {
+			acc.Met++
+		} else {
+			acc.Missed++
+		}
+		acc.AvgResponseHours += cal.BusinessHoursBetween(src.FormedAt, src.CompletedAt)
+	}
+
+	report := make([]SLAReportRow, 0, len(keys))
+	for _, key := range keys {
+		row := rows[key]
+		row.Compliance = float64(row.Met) * 100 / float64(row.Total)
+		row.AvgResponseHours /= float64(row.Total)
+		report = append(report, *row)
+	}
+	sortSLAReport(report)
+	return report, nil
+}
+
+// sortSLAReport - строки по ID модератора (без модератора - первыми) или по началу интервала
+func sortSLAReport(report []SLAReportRow) {
+	sort.SliceStable(report, func(i, j int) bool {
+		a, b := report[i], report[j]
+		if a.Period != nil && b.Period != nil {
+			return a.Period.Before(*b.Period)
+		}
+		if a.ModeratorID == nil || b.ModeratorID == nil {
+			return a.ModeratorID == nil && b.ModeratorID != nil
+		}
+		return *a.ModeratorID < *b.ModeratorID
+	})
+}
+
+// truncatePeriod - начало дня, недели (понедельник) или месяца
+func truncatePeriod(t time.Time, interval string) time.Time {
+	y, m, d := t.Date()
+	switch interval {
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, t.Location())
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, t.Location())
+	default:
+		return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+	}
+}
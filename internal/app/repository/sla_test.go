@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"rip-go-app/internal/app/ds"
+)
+
+func TestNextSLAStatus(t *testing.T) {
+	warnAt := time.Date(2026, 3, 2, 15, 0, 0, 0, time.UTC)
+	dueAt := time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC)
+	before, warning, due, after := warnAt.Add(-time.Minute), warnAt, dueAt, dueAt.Add(time.Hour)
+	order := func(status, sla string) *ds.Order {
+		return &ds.Order{Status: status, SLAStatus: sla, SLAWarnAt: &warnAt, SLADueAt: &dueAt}
+	}
+
+	tests := []struct {
+		name  string
+		order *ds.Order
+		now   time.Time
+		want  string
+	}{
+		{"pending before warning", order(ds.StatusFormed, ds.SLAPending), before, ""},
+		{"pending at warning", order(ds.StatusFormed, ds.SLAPending), warning, ds.SLAWarning},
+		{"pending at due", order(ds.StatusFormed, ds.SLAPending), due, ds.SLABreached},
+		{"warning before due", order(ds.StatusFormed, ds.SLAWarning), warning, ""},
+		{"warning after due", order(ds.StatusFormed, ds.SLAWarning), after, ds.SLABreached},
+		{"breached stays breached", order(ds.StatusFormed, ds.SLABreached), after, ""},
+		{"no warning time", &ds.Order{Status: ds.StatusFormed, SLAStatus: ds.SLAPending, SLADueAt: &dueAt}, warning, ""},
+		{"no policy", &ds.Order{Status: ds.StatusFormed}, after, ""},
+		{"completed order", order(ds.StatusCompleted, ds.SLAPending), after, ""},
+		{"deleted or missing order", &ds.Order{}, after, ""},
+	}
+
+	for _, tt := range tests {
+		if got := nextSLAStatus(tt.order, tt.now); got != tt.want {
+			t.Errorf("%s: nextSLAStatus = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSLAOutcome(t *testing.T) {
+	dueAt := time.Date(2026, 3, 2, 17, 0, 0, 0, time.UTC)
+	early, late := dueAt.Add(-time.Hour), dueAt.Add(time.Second)
+
+	tests := []struct {
+		name        string
+		due         *time.Time
+		completedAt *time.Time
+		want        string
+	}{
+		{"before due", &dueAt, &early, ds.SLAMet},
+		{"at due", &dueAt, &dueAt, ds.SLAMet},
+		{"after due", &dueAt, &late, ds.SLAMissed},
+		{"no due", nil, &early, ""},
+		{"not completed", &dueAt, nil, ""},
+	}
+
+	for _, tt := range tests {
+		order := &ds.Order{SLADueAt: tt.due, CompletedAt: tt.completedAt}
+		if got := slaOutcome(order); got != tt.want {
+			t.Errorf("%s: slaOutcome = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSortSLAReport(t *testing.T) {
+	id := func(v int) *int { return &v }
+	report := []SLAReportRow{{ModeratorID: id(10)}, {ModeratorID: id(2)}, {}, {ModeratorID: id(1)}}
+	sortSLAReport(report)
+	var got []int
+	for _, row := range report {
+		if row.ModeratorID == nil {
+			got = append(got, 0)
+		} else {
+			got = append(got, *row.ModeratorID)
+		}
+	}
+	if want := []int{0, 1, 2, 10}; !reflect.DeepEqual(got, want) {
+		t.Errorf("moderators = %v, want %v", got, want)
+	}
+
+	// смещение часового пояса меняется на переходе на летнее время, сортировка - по моменту
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("load location: %v", err)
+	}
+	march := time.Date(2026, 3, 1, 0, 0, 0, 0, berlin)
+	april := time.Date(2026, 4, 1, 0, 0, 0, 0, berlin)
+	february := time.Date(2026, 2, 1, 0, 0, 0, 0, berlin)
+	periods := []SLAReportRow{{Period: &april}, {Period: &february}, {Period: &march}}
+	sortSLAReport(periods)
+	for i, want := range []time.Time{february, march, april} {
+		if !periods[i].Period.Equal(want) {
+			t.Errorf("period %d = %s, want %s", i, periods[i].Period, want)
+		}
+	}
+}
@@ -0,0 +1,200 @@
+package repository
+
+import (
+	"fmt"
+	"math"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"rip-go-app/internal/app/calculator"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/workflow"
+)
+
+// loadEpsilon - допуск при сравнении остатка груза на борту
+const loadEpsilon = 1e-6
+
+// orderedStops - остановки в порядке маршрута
+func orderedStops(db *gorm.DB) *gorm.DB {
+	return db.Order("seq, id")
+}
+
+// validateStops - проверка маршрута: не меньше двух остановок, груз не выгружается раньше погрузки
+// и полностью выгружается к последней остановке, окна прибытия идут по порядку
+func validateStops(stops []ds.OrderStop) error {
+	if len(stops) < 2 {
+		return fmt.Errorf("маршрут должен содержать не менее двух остановок")
+	}
+
+	var weight, volume float64
+	for i := range stops {
+		s := &stops[i]
+		s.ID = 0
+		s.Seq = i + 1
+		s.City = strings.TrimSpace(s.City)
+		s.Kind = strings.ToLower(strings.TrimSpace(s.Kind))
+		if s.City == "" {
+			return fmt.Errorf("остановка %d: не указан город", s.Seq)
+		}
+		if s.Weight < 0 || s.Volume < 0 {
+			return fmt.Errorf("остановка %d: вес и объём не могут быть отрицательными", s.Seq)
+		}
+		if s.WindowFrom != nil && s.WindowTo != nil && s.WindowTo.Before(*s.WindowFrom) {
+			return fmt.Errorf("остановка %d: окно прибытия заканчивается раньше начала", s.Seq)
+		}
+		if i > 0 {
+			prev := stops[i-1]
+			if s.WindowTo != nil && prev.WindowFrom != nil && s.WindowTo.Before(*prev.WindowFrom) {
+				return fmt.Errorf("остановка %d: окно прибытия раньше окна предыдущей остановки", s.Seq)
+			}
+		}
+
+		switch s.Kind {
+		case ds.StopPickup:
+			weight += s.Weight
+			volume += s.Volume
+		case ds.StopDropoff:
+			weight -= s.Weight
+			volume -= s.Volume
+			if weight < -loadEpsilon || volume < -loadEpsilon {
+				return fmt.Errorf("остановка %d: выгружается больше груза, чем есть на борту", s.Seq)
+			}
+		default:
+			return fmt.Errorf("остановка %d: неизвестный вид остановки: %s", s.Seq, s.Kind)
+		}
+	}
+
+	if stops[0].Kind != ds.StopPickup {
+		return fmt.Errorf("маршрут должен начинаться с погрузки")
+	}
+	if weight > loadEpsilon || volume > loadEpsilon {
+		return fmt.Errorf("к последней остановке должен быть выгружен весь груз")
+	}
+	return nil
+}
+
+// stopLegs - участки маршрута между соседними остановками с грузом на борту после каждой остановки
+func stopLegs(stops []ds.OrderStop) []calculator.RouteLeg {
+	legs := make([]calculator.RouteLeg, 0, len(stops))
+	var weight, volume float64
+	for i := 0; i+1 < len(stops); i++ {
+		s := stops[i]
+		if s.Kind == ds.StopDropoff {
+			weight, volume = weight-s.Weight, volume-s.Volume
+		} else {
+			weight, volume = weight+s.Weight, volume+s.Volume
+		}
+		legs = append(legs, calculator.RouteLeg{
+			FromCity: s.City,
+			ToCity:   stops[i+1].City,
+			Weight:   math.Max(weight, 0),
+			Volume:   math.Max(volume, 0),
+		})
+	}
+	return legs
+}
+
+// routeShare - доля груза маршрута с остановками, которую везёт строка: груз делится между принятыми
+// строками пропорционально грузоподъёмности их транспорта, так что погрузка оплачивается один раз на заявку,
+// а каждая единица транспорта проходит маршрут только со своей частью груза.
+// Отклонённая или ещё не добавленная строка считается вместе с принятыми
+func routeShare(order *ds.Order, line *ds.OrderService) float64 {
+	own := lineCapacity(*line)
+	total := own
+	for _, other := range order.Services {
+		if other.Accepted() && (line.ID == 0 || other.ID != line.ID) {
+			total += lineCapacity(other)
+		}
+	}
+	if total <= 0 {
+		return 1
+	}
+	return own / total
+}
+
+// lineCapacity - грузоподъёмность транспорта строки с учётом количества
+func lineCapacity(line ds.OrderService) float64 {
+	return math.Max(line.Service.MaxWeight, 0) * float64(max(line.Quantity, 1))
+}
+
+// stopsRoute - маршрут остановок строкой для журнала
+func stopsRoute(stops []ds.OrderStop) string {
+	cities := make([]string, 0, len(stops))
+	for _, s := range stops {
+		cities = append(cities, s.City)
+	}
+	return strings.Join(cities, " → ")
+}
+
+// GetOrderStops - остановки маршрута заявки
+func (r *Repository) GetOrderStops(orderID int) ([]ds.OrderStop, error) {
+	if _, err := r.GetOrder(orderID); err != nil {
+		return nil, ErrOrderNotFound
+	}
+	var stops []ds.OrderStop
+	err := orderedStops(r.db).Where("order_id = ?", orderID).Find(&stops).Error
+	return stops, err
+}
+
+// SetOrderStops - замена маршрута черновика списком остановок; пустой список возвращает
+// заявку к перевозке из FromCity в ToCity. Строки пересчитываются по новому маршруту
+func (r *Repository) SetOrderStops(orderID int, stops []ds.OrderStop, actor workflow.Actor, version int) error {
+	if len(stops) > 0 {
+		if err := validateStops(stops); err != nil {
+			return err
+		}
+	}
+
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		var order ds.Order
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Preload("Stops", orderedStops).
+			Where("id = ? AND deleted_at IS NULL", orderID).First(&order).Error
+		if err != nil {
+			return ErrOrderNotFound
+		}
+		if order.Status != ds.StatusDraft {
+			return fmt.Errorf("%w: маршрут можно менять только в черновике", ErrNotDraft)
+		}
+		if err := checkReturnFrozen(&order, actor); err != nil {
+			return err
+		}
+
+		before := order
+		if len(stops) > 0 {
+			order.FromCity, order.ToCity = stops[0].City, stops[len(stops)-1].City
+		}
+		if err := saveOrder(tx, &order, version); err != nil {
+			return err
+		}
+
+		events := orderFieldChanges(&before, &order, actor)
+		if oldRoute, newRoute := stopsRoute(before.Stops), stopsRoute(stops); oldRoute != newRoute {
+			events = append(events, ds.OrderEvent{
+				OrderID:  orderID,
+				ActorID:  actorID(actor),
+				Type:     ds.EventFieldChange,
+				Field:    "stops",
+				OldValue: oldRoute,
+				NewValue: newRoute,
+			})
+		}
+		if err := recordOrderEvents(tx, events...); err != nil {
+			return err
+		}
+
+		// остановки заменяются целиком
+		if err := tx.Where("order_id = ?", orderID).Delete(&ds.OrderStop{}).Error; err != nil {
+			return err
+		}
+		for i := range stops {
+			stops[i].OrderID = orderID
+		}
+		if len(stops) > 0 {
+			if err := tx.Create(&stops).Error; err != nil {
+				return err
+			}
+		}
+		return repriceDraftTx(tx, orderID)
+	})
+}
@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/workflow"
+)
+
+// trackingAlphabet - символы номера без легко путаемых (0/O, 1/I/L)
+const trackingAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+// newTrackingNumber - генерация уникального номера вида GD-XXXX-XXXX
+func newTrackingNumber(tx *gorm.DB) (string, error) {
+	for attempt := 0; attempt < 5; attempt++ {
+		buf := make([]byte, 8)
+		if _, err := rand.Read(buf); err != nil {
+			return "", err
+		}
+		for i := range buf {
+			buf[i] = trackingAlphabet[int(buf[i])%len(trackingAlphabet)]
+		}
+		number := fmt.Sprintf("GD-%s-%s", buf[:4], buf[4:])
+
+		var count int64
+		if err := tx.Model(&ds.Order{}).Where("tracking_number = ?", number).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return number, nil
+		}
+	}
+	return "", fmt.Errorf("не удалось сгенерировать номер отслеживания")
+}
+
+// ErrTrackingNotActive - промежуточные этапы отмечаются только для груза в пути
+var ErrTrackingNotActive = errors.New("этап можно отметить только для груза в пути")
+
+// milestoneTransitions - этапы, которые переводят заявку в новый статус
+var milestoneTransitions = map[string]string{
+	ds.MilestonePickedUp:  ds.StatusShipped,
+	ds.MilestoneDelivered: ds.StatusDelivered,
+}
+
+// AddTrackingEvent - отметка этапа доставки (при необходимости меняет статус заявки)
+func (r *Repository) AddTrackingEvent(orderID int, event ds.TrackingEvent, actor workflow.Actor) (ds.TrackingEvent, error) {
+	valid := false
+	for _, m := range ds.TrackingMilestones {
+		if m == event.Milestone {
+			valid = true
+			break
+		}
+	}
+	if !valid {
+		return ds.TrackingEvent{}, fmt.Errorf("неизвестный этап доставки: %s", event.Milestone)
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var order ds.Order
+		if err := tx.Where("id = ? AND deleted_at IS NULL", orderID).First(&order).Error; err != nil {
+			return ErrOrderNotFound
+		}
+
+		if to, ok := milestoneTransitions[event.Milestone]; ok {
+			if _, err := r.transitionOrderTx(tx, orderID, to, actor, 0, event.Note, nil); err != nil {
+				return err
+			}
+		} else if order.Status != ds.StatusShipped && !(order.Status == ds.StatusCompleted && event.Milestone == ds.MilestoneException) {
+			return ErrTrackingNotActive
+		}
+
+		event.ID = 0
+		event.OrderID = orderID
+		event.CreatedByID = actor.UserID
+		return tx.Create(&event).Error
+	})
+	if err != nil {
+		return ds.TrackingEvent{}, err
+	}
+	return event, nil
+}
+
+// GetTrackingEvents - этапы доставки заявки в хронологическом порядке
+func (r *Repository) GetTrackingEvents(orderID int) ([]ds.TrackingEvent, error) {
+	var events []ds.TrackingEvent
+	err := r.db.Where("order_id = ?", orderID).Order("occurred_at ASC, id ASC").Find(&events).Error
+	return events, err
+}
+
+// GetOrderByTrackingNumber - заявка по номеру отслеживания (без персональных данных)
+func (r *Repository) GetOrderByTrackingNumber(number string) (ds.Order, error) {
+	var order ds.Order
+	err := r.db.Where("tracking_number = ? AND deleted_at IS NULL", number).First(&order).Error
+	if err != nil {
+		return ds.Order{}, ErrOrderNotFound
+	}
+	return order, nil
+}
@@ -0,0 +1,223 @@
+package repository
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/workflow"
+)
+
+// defaultTrashRetention - срок хранения удалённых заявок и услуг по умолчанию
+const defaultTrashRetention = 30 * 24 * time.Hour
+
+// ErrNotInTrash - запись не найдена среди удалённых
+var ErrNotInTrash = errors.New("запись не найдена в корзине")
+
+// TrashedOrder - удалённая заявка и дата её окончательного удаления
+type TrashedOrder struct {
+	Order     ds.Order  `json:"order"`
+	DeletedAt time.Time `json:"deleted_at"`
+	PurgeAt   time.Time `json:"purge_at"`
+}
+
+// TrashedService - удалённая услуга и дата её окончательного удаления
+type TrashedService struct {
+	Service   ds.Service `json:"service"`
+	DeletedAt time.Time  `json:"deleted_at"`
+	PurgeAt   time.Time  `json:"purge_at"`
+}
+
+// PurgeResult - итог очистки корзины
+type PurgeResult struct {
+	Orders      int
+	Services    int
+	StorageKeys []string // файлы удалённых заявок, которые нужно стереть из хранилища
+}
+
+// withDeleted - загрузка связей вместе с удалёнными записями (услуги в строках заявок)
+func withDeleted(db *gorm.DB) *gorm.DB {
+	return db.Unscoped()
+}
+
+// SetTrashRetention - срок хранения удалённых записей до окончательного удаления
+func (r *Repository) SetTrashRetention(retention time.Duration) {
+	r.trashRetention = retention
+}
+
+// trashRetentionPeriod - действующий срок хранения корзины
+func (r *Repository) trashRetentionPeriod() time.Duration {
+	if r.trashRetention <= 0 {
+		return defaultTrashRetention
+	}
+	return r.trashRetention
+}
+
+// GetTrashedOrders - удалённые заявки, последние удалённые первыми
+func (r *Repository) GetTrashedOrders() ([]TrashedOrder, error) {
+	var orders []ds.Order
+	err := r.db.Unscoped().Preload("Creator").
+		Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&orders).Error
+	if err != nil {
+		return nil, err
+	}
+
+	retention := r.trashRetentionPeriod()
+	items := make([]TrashedOrder, 0, len(orders))
+	for _, o := range orders {
+		items = append(items, TrashedOrder{Order: o, DeletedAt: o.DeletedAt.Time, PurgeAt: o.DeletedAt.Time.Add(retention)})
+	}
+	return items, nil
+}
+
+// GetTrashedServices - удалённые услуги, последние удалённые первыми
+func (r *Repository) GetTrashedServices() ([]TrashedService, error) {
+	var services []ds.Service
+	err := r.db.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&services).Error
+	if err != nil {
+		return nil, err
+	}
+
+	retention := r.trashRetentionPeriod()
+	items := make([]TrashedService, 0, len(services))
+	for _, s := range services {
+		items = append(items, TrashedService{Service: s, DeletedAt: s.DeletedAt.Time, PurgeAt: s.DeletedAt.Time.Add(retention)})
+	}
+	return items, nil
+}
+
+// RestoreOrder - восстановление заявки из корзины в статус, из которого она была удалена
+func (r *Repository) RestoreOrder(orderID int, actor workflow.Actor) (ds.Order, error) {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		var order ds.Order
+		if err := tx.Unscoped().Where("id = ? AND deleted_at IS NOT NULL", orderID).First(&order).Error; err != nil {
+			return ErrNotInTrash
+		}
+
+		// Статус до удаления берётся из истории; без неё заявка возвращается черновиком
+		status := ds.StatusDraft
+		var event ds.OrderEvent
+		err := tx.Where("order_id = ? AND type = ? AND new_value = ?", orderID, ds.EventStatusChange, ds.StatusDeleted).
+			Order("id DESC").First(&event).Error
+		if err == nil && event.OldValue != "" {
+			status = event.OldValue
+		}
+
+		res := tx.Unscoped().Model(&ds.Order{}).Where("id = ? AND version = ?", orderID, order.Version).
+			Updates(map[string]interface{}{
+				"deleted_at": nil,
+				"status":     status,
+				"is_draft":   status == ds.StatusDraft,
+				"version":    order.Version + 1,
+				"updated_at": time.Now(),
+			})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrVersionConflict
+		}
+
+		return recordOrderEvents(tx, ds.OrderEvent{
+			OrderID:  orderID,
+			ActorID:  actorID(actor),
+			Type:     ds.EventRestored,
+			Field:    "status",
+			OldValue: order.Status,
+			NewValue: status,
+		})
+	})
+	if err != nil {
+		return ds.Order{}, err
+	}
+	return r.GetOrder(orderID)
+}
+
+// RestoreService - восстановление услуги из корзины
+func (r *Repository) RestoreService(id int) (ds.Service, error) {
+	res := r.db.Unscoped().Model(&ds.Service{}).Where("id = ? AND deleted_at IS NOT NULL", id).
+		Update("deleted_at", nil)
+	if res.Error != nil {
+		return ds.Service{}, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ds.Service{}, ErrNotInTrash
+	}
+	return r.GetService(id)
+}
+
+// PurgeTrash - окончательное удаление записей, пролежавших в корзине дольше срока хранения.
+// Заявки с платежами сохраняются для бухгалтерии, услуги - пока на них ссылаются строки заявок
+func (r *Repository) PurgeTrash(now time.Time) (PurgeResult, error) {
+	var result PurgeResult
+	cutoff := now.Add(-r.trashRetentionPeriod())
+
+	var orderIDs []int
+	err := r.db.Unscoped().Model(&ds.Order{}).
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM payments p WHERE p.order_id = orders.id)").
+		Pluck("id", &orderIDs).Error
+	if err != nil {
+		return result, err
+	}
+	for _, id := range orderIDs {
+		var keys []string
+		err := r.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			keys, err = purgeOrderTx(tx, id)
+			return err
+		})
+		if err != nil {
+			return result, err
+		}
+		result.Orders++
+		result.StorageKeys = append(result.StorageKeys, keys...)
+	}
+
+	res := r.db.Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+		Where("NOT EXISTS (SELECT 1 FROM order_services os WHERE os.service_id = services.id)").
+		Delete(&ds.Service{})
+	if res.Error != nil {
+		return result, res.Error
+	}
+	result.Services = int(res.RowsAffected)
+	return result, nil
+}
+
+// purgeOrderTx - удаление заявки со всеми связанными записями; возвращает ключи её файлов
+func purgeOrderTx(tx *gorm.DB, orderID int) ([]string, error) {
+	var keys []string
+	if err := tx.Model(&ds.Attachment{}).Where("order_id = ?", orderID).Pluck("storage_key", &keys).Error; err != nil {
+		return nil, err
+	}
+	var documentKeys []string
+	if err := tx.Model(&ds.OrderDocument{}).Where("order_id = ?", orderID).Pluck("storage_key", &documentKeys).Error; err != nil {
+		return nil, err
+	}
+	keys = append(keys, documentKeys...)
+
+	// Уведомления и расписания сохраняются без ссылки на заявку
+	if err := tx.Model(&ds.Notification{}).Where("order_id = ?", orderID).Update("order_id", nil).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&ds.Schedule{}).Where("last_order_id = ?", orderID).Update("last_order_id", nil).Error; err != nil {
+		return nil, err
+	}
+
+	err := tx.Where("message_id IN (?)", tx.Model(&ds.OrderMessage{}).Select("id").Where("order_id = ?", orderID)).
+		Delete(&ds.MessageRead{}).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, model := range []interface{}{
+		&ds.OrderMessage{}, &ds.Attachment{}, &ds.OrderDocument{}, &ds.Cancellation{},
+		&ds.TrackingEvent{}, &ds.CustomsItem{}, &ds.OrderStop{}, &ds.OrderService{}, &ds.OrderEvent{},
+	} {
+		if err := tx.Where("order_id = ?", orderID).Delete(model).Error; err != nil {
+			return nil, err
+		}
+	}
+	return keys, tx.Unscoped().Delete(&ds.Order{}, orderID).Error
+}
@@ -0,0 +1,173 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron - разобранное cron-выражение из пяти полей: минута, час, день месяца, месяц, день недели
+type Cron struct {
+	minutes  [60]bool
+	hours    [24]bool
+	days     [32]bool
+	months   [13]bool
+	weekdays [7]bool
+
+	anyDay     bool // день месяца задан как *
+	anyWeekday bool // день недели задан как *
+}
+
+// cronField - допустимый диапазон поля
+type cronField struct {
+	name     string
+	min, max int
+}
+
+var cronFields = []cronField{
+	{"минута", 0, 59},
+	{"час", 0, 23},
+	{"день месяца", 1, 31},
+	{"месяц", 1, 12},
+	{"день недели", 0, 7}, // 0 и 7 - воскресенье
+}
+
+// ParseCron - разбор выражения вида "0 9 * * 1,3" (поддерживаются *, списки, диапазоны и шаг)
+func ParseCron(expr string) (*Cron, error) {
+	parts := strings.Fields(expr)
+	if len(parts) != len(cronFields) {
+		return nil, fmt.Errorf("cron-выражение должно состоять из 5 полей")
+	}
+
+	c := &Cron{anyDay: parts[2] == "*", anyWeekday: parts[4] == "*"}
+	for i, part := range parts {
+		values, err := parseCronField(part, cronFields[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, v := range values {
+			switch i {
+			case 0:
+				c.minutes[v] = true
+			case 1:
+				c.hours[v] = true
+			case 2:
+				c.days[v] = true
+			case 3:
+				c.months[v] = true
+			case 4:
+				c.weekdays[v%7] = true
+			}
+		}
+	}
+	return c, nil
+}
+
+func parseCronField(part string, field cronField) ([]int, error) {
+	var values []int
+	for _, item := range strings.Split(part, ",") {
+		step := 1
+		if idx := strings.Index(item, "/"); idx >= 0 {
+			s, err := strconv.Atoi(item[idx+1:])
+			if err != nil || s <= 0 {
+				return nil, fmt.Errorf("некорректный шаг в поле «%s»: %s", field.name, item)
+			}
+			step = s
+			item = item[:idx]
+		}
+
+		lo, hi := field.min, field.max
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			var err1, err2 error
+			lo, err1 = strconv.Atoi(bounds[0])
+			hi, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil || lo > hi {
+				return nil, fmt.Errorf("некорректный диапазон в поле «%s»: %s", field.name, item)
+			}
+		default:
+			v, err := strconv.Atoi(item)
+			if err != nil {
+				return nil, fmt.Errorf("некорректное значение в поле «%s»: %s", field.name, item)
+			}
+			lo, hi = v, v
+			if step > 1 {
+				hi = field.max
+			}
+		}
+		if lo < field.min || hi > field.max {
+			return nil, fmt.Errorf("значение поля «%s» вне диапазона %d-%d", field.name, field.min, field.max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			values = append(values, v)
+		}
+	}
+	return values, nil
+}
+
+// matchDay - подходит ли день. Как в cron: если заданы и день месяца, и день недели,
+// достаточно совпадения любого из них
+func (c *Cron) matchDay(t time.Time) bool {
+	day, weekday := c.days[t.Day()], c.weekdays[int(t.Weekday())]
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// Next - ближайший момент строго после after (нулевое время, если его нет в ближайшие 5 лет).
+// Выражение сопоставляется с показаниями часов в часовом поясе after: время, пропущенное
+// при переводе часов вперёд, сдвигается на величину перевода, повторяющееся при переводе
+// назад срабатывает один раз
+func (c *Cron) Next(after time.Time) time.Time {
+	w := time.Date(after.Year(), after.Month(), after.Day(), after.Hour(), after.Minute(), 0, 0, time.UTC).Add(time.Minute)
+	limit := w.AddDate(5, 0, 0)
+
+	for w.Before(limit) {
+		if !c.months[int(w.Month())] {
+			w = time.Date(w.Year(), w.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.matchDay(w) {
+			w = time.Date(w.Year(), w.Month(), w.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !c.hours[w.Hour()] {
+			w = w.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if !c.minutes[w.Minute()] {
+			w = w.Add(time.Minute)
+			continue
+		}
+		if t := localTime(w, after.Location()); t.After(after) {
+			return t
+		}
+		w = w.Add(time.Minute)
+	}
+	return time.Time{}
+}
+
+// localTime - момент, когда часы в loc показывают то же, что w. Несуществующее время
+// сдвигается вперёд на величину перевода часов, из повторяющегося берётся первое
+func localTime(w time.Time, loc *time.Location) time.Time {
+	t := time.Date(w.Year(), w.Month(), w.Day(), w.Hour(), w.Minute(), 0, 0, loc)
+	_, offset := t.Zone()
+	if _, before := t.Add(-24 * time.Hour).Zone(); before > offset {
+		first := t.Add(-time.Duration(before-offset) * time.Second)
+		if first.Day() == t.Day() && first.Hour() == t.Hour() && first.Minute() == t.Minute() {
+			return first
+		}
+	}
+	return t
+}
@@ -0,0 +1,148 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Виды правил повторения
+const (
+	KindWeekly  = "weekly"  // по дням недели
+	KindMonthly = "monthly" // в день месяца (в коротких месяцах - последний день)
+	KindCron    = "cron"    // cron-выражение
+)
+
+// weekdayNames - сокращения дней недели
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// Rule - правило повторения отправок
+type Rule struct {
+	Kind      string
+	Weekdays  string // для weekly: "mon,thu"
+	MonthDay  int    // для monthly: 1-31
+	TimeOfDay string // для weekly/monthly: "09:00"
+	Cron      string // для cron: "0 9 * * 1"
+	Location  *time.Location
+
+	cron *Cron
+}
+
+// Compile - проверка правила и подготовка к расчёту дат
+func (r *Rule) Compile() error {
+	if r.Location == nil {
+		r.Location = time.Local
+	}
+
+	var expr string
+	switch r.Kind {
+	case KindWeekly:
+		days, err := parseWeekdays(r.Weekdays)
+		if err != nil {
+			return err
+		}
+		hour, minute, err := parseTimeOfDay(r.TimeOfDay)
+		if err != nil {
+			return err
+		}
+		expr = fmt.Sprintf("%d %d * * %s", minute, hour, days)
+	case KindMonthly:
+		if r.MonthDay < 1 || r.MonthDay > 31 {
+			return fmt.Errorf("день месяца должен быть от 1 до 31")
+		}
+		if _, _, err := parseTimeOfDay(r.TimeOfDay); err != nil {
+			return err
+		}
+		return nil
+	case KindCron:
+		expr = r.Cron
+	default:
+		return fmt.Errorf("неизвестный вид повторения: %s", r.Kind)
+	}
+
+	c, err := ParseCron(expr)
+	if err != nil {
+		return err
+	}
+	r.cron = c
+	return nil
+}
+
+// Next - ближайшая дата отправки строго после after
+func (r *Rule) Next(after time.Time) time.Time {
+	after = after.In(r.Location)
+	if r.Kind != KindMonthly {
+		if r.cron == nil {
+			return time.Time{}
+		}
+		return r.cron.Next(after)
+	}
+
+	hour, minute, _ := parseTimeOfDay(r.TimeOfDay)
+	for i := 0; i < 24; i++ {
+		first := time.Date(after.Year(), after.Month()+time.Month(i), 1, hour, minute, 0, 0, time.UTC)
+		day := r.MonthDay
+		if last := first.AddDate(0, 1, -1).Day(); day > last {
+			day = last
+		}
+		t := localTime(first.AddDate(0, 0, day-1), r.Location)
+		if t.After(after) {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// Preview - следующие n дат после after; skip исключает даты (например, праздники)
+func (r *Rule) Preview(after time.Time, n int, skip func(time.Time) bool) []time.Time {
+	var dates []time.Time
+	t := after
+	// Ограничение на случай, если почти все даты исключены
+	for guard := 0; len(dates) < n && guard < n*50; guard++ {
+		t = r.Next(t)
+		if t.IsZero() {
+			break
+		}
+		if skip != nil && skip(t) {
+			continue
+		}
+		dates = append(dates, t)
+	}
+	return dates
+}
+
+// parseWeekdays - "mon,thu" в список для cron ("1,4")
+func parseWeekdays(s string) (string, error) {
+	var days []string
+	for _, name := range strings.Split(strings.ToLower(s), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		d, ok := weekdayNames[name]
+		if !ok {
+			return "", fmt.Errorf("неизвестный день недели: %s", name)
+		}
+		days = append(days, strconv.Itoa(int(d)))
+	}
+	if len(days) == 0 {
+		return "", fmt.Errorf("не указаны дни недели")
+	}
+	return strings.Join(days, ","), nil
+}
+
+// parseTimeOfDay - "09:30" в часы и минуты
+func parseTimeOfDay(s string) (int, int, error) {
+	if s == "" {
+		s = "09:00"
+	}
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, 0, fmt.Errorf("время должно быть в формате ЧЧ:ММ")
+	}
+	return t.Hour(), t.Minute(), nil
+}
@@ -0,0 +1,106 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Fatalf("load location %s: %v", name, err)
+	}
+	return loc
+}
+
+func TestRuleNext(t *testing.T) {
+	berlin := mustLocation(t, "Europe/Berlin")
+	newYork := mustLocation(t, "America/New_York")
+	at := func(loc *time.Location, y int, m time.Month, d, h, min int) time.Time {
+		return time.Date(y, m, d, h, min, 0, 0, loc)
+	}
+
+	tests := []struct {
+		name  string
+		rule  Rule
+		after time.Time
+		want  time.Time
+	}{
+		// Пересечение дня месяца и дня недели: достаточно любого из них
+		{"cron day of week before day of month", Rule{Kind: KindCron, Cron: "0 9 15 * 1"},
+			at(time.UTC, 2026, 3, 3, 12, 0), at(time.UTC, 2026, 3, 9, 9, 0)},
+		{"cron day of month before day of week", Rule{Kind: KindCron, Cron: "0 9 15 * 1"},
+			at(time.UTC, 2026, 3, 10, 12, 0), at(time.UTC, 2026, 3, 15, 9, 0)},
+		{"cron day of week after day of month", Rule{Kind: KindCron, Cron: "0 9 15 * 1"},
+			at(time.UTC, 2026, 3, 15, 9, 0), at(time.UTC, 2026, 3, 16, 9, 0)},
+		{"cron weekdays only", Rule{Kind: KindCron, Cron: "0 9 * * 1-5"},
+			at(time.UTC, 2026, 3, 6, 10, 0), at(time.UTC, 2026, 3, 9, 9, 0)},
+		{"cron sunday as 7", Rule{Kind: KindCron, Cron: "0 9 * * 7"},
+			at(time.UTC, 2026, 3, 2, 0, 0), at(time.UTC, 2026, 3, 8, 9, 0)},
+		{"cron step", Rule{Kind: KindCron, Cron: "*/15 * * * *"},
+			at(time.UTC, 2026, 3, 2, 12, 7), at(time.UTC, 2026, 3, 2, 12, 15)},
+		{"cron strictly after", Rule{Kind: KindCron, Cron: "0 9 * * *"},
+			at(time.UTC, 2026, 3, 2, 9, 0), at(time.UTC, 2026, 3, 3, 9, 0)},
+
+		// Переход через конец месяца и года
+		{"cron 31st skips short month", Rule{Kind: KindCron, Cron: "0 9 31 * *"},
+			at(time.UTC, 2026, 4, 1, 0, 0), at(time.UTC, 2026, 5, 31, 9, 0)},
+		{"cron new year", Rule{Kind: KindCron, Cron: "0 0 1 1 *"},
+			at(time.UTC, 2026, 6, 1, 0, 0), at(time.UTC, 2027, 1, 1, 0, 0)},
+		{"cron leap day", Rule{Kind: KindCron, Cron: "0 0 29 2 *"},
+			at(time.UTC, 2026, 3, 1, 0, 0), at(time.UTC, 2028, 2, 29, 0, 0)},
+		{"cron impossible date", Rule{Kind: KindCron, Cron: "0 0 31 2 *"},
+			at(time.UTC, 2026, 3, 1, 0, 0), time.Time{}},
+		{"monthly 31st in february", Rule{Kind: KindMonthly, MonthDay: 31, TimeOfDay: "09:00"},
+			at(time.UTC, 2026, 1, 31, 10, 0), at(time.UTC, 2026, 2, 28, 9, 0)},
+		{"monthly 31st after short month", Rule{Kind: KindMonthly, MonthDay: 31, TimeOfDay: "09:00"},
+			at(time.UTC, 2026, 2, 28, 9, 0), at(time.UTC, 2026, 3, 31, 9, 0)},
+		{"monthly 30th in leap february", Rule{Kind: KindMonthly, MonthDay: 30, TimeOfDay: "09:00"},
+			at(time.UTC, 2028, 2, 1, 0, 0), at(time.UTC, 2028, 2, 29, 9, 0)},
+		{"monthly december to january", Rule{Kind: KindMonthly, MonthDay: 15, TimeOfDay: "09:00"},
+			at(time.UTC, 2026, 12, 20, 0, 0), at(time.UTC, 2027, 1, 15, 9, 0)},
+		{"weekly next listed day", Rule{Kind: KindWeekly, Weekdays: "mon,thu", TimeOfDay: "09:00"},
+			at(time.UTC, 2026, 3, 2, 9, 0), at(time.UTC, 2026, 3, 5, 9, 0)},
+
+		// Переход на летнее и зимнее время в часовом поясе правила
+		{"spring forward shifts skipped time", Rule{Kind: KindCron, Cron: "30 2 * * *", Location: berlin},
+			at(berlin, 2026, 3, 28, 12, 0), at(berlin, 2026, 3, 29, 3, 30)},
+		{"spring forward next day", Rule{Kind: KindCron, Cron: "30 2 * * *", Location: berlin},
+			at(berlin, 2026, 3, 29, 3, 30), at(berlin, 2026, 3, 30, 2, 30)},
+		{"fall back fires first occurrence", Rule{Kind: KindCron, Cron: "30 2 * * *", Location: berlin},
+			at(berlin, 2026, 10, 24, 12, 0), time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC)},
+		{"fall back does not repeat", Rule{Kind: KindCron, Cron: "30 2 * * *", Location: berlin},
+			time.Date(2026, 10, 25, 0, 30, 0, 0, time.UTC), at(berlin, 2026, 10, 26, 2, 30)},
+		{"fall back hourly skips repeated hour", Rule{Kind: KindCron, Cron: "0 * * * *", Location: berlin},
+			time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC), time.Date(2026, 10, 25, 2, 0, 0, 0, time.UTC)},
+		{"monthly in skipped hour", Rule{Kind: KindMonthly, MonthDay: 29, TimeOfDay: "02:30", Location: berlin},
+			at(berlin, 2026, 3, 1, 0, 0), at(berlin, 2026, 3, 29, 3, 30)},
+		{"weekly keeps local time across dst", Rule{Kind: KindWeekly, Weekdays: "mon", TimeOfDay: "09:00", Location: newYork},
+			at(newYork, 2026, 3, 6, 0, 0), time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC)},
+		{"after in other zone", Rule{Kind: KindWeekly, Weekdays: "mon", TimeOfDay: "09:00", Location: newYork},
+			time.Date(2026, 3, 9, 12, 0, 0, 0, time.UTC), time.Date(2026, 3, 9, 13, 0, 0, 0, time.UTC)},
+	}
+
+	for _, tt := range tests {
+		rule := tt.rule
+		if rule.Location == nil {
+			rule.Location = time.UTC
+		}
+		if err := rule.Compile(); err != nil {
+			t.Fatalf("%s: compile: %v", tt.name, err)
+		}
+		if got := rule.Next(tt.after); !got.Equal(tt.want) {
+			t.Errorf("%s: Next(%s) = %s, want %s", tt.name, tt.after, got, tt.want)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expr := range []string{"", "0 9 * *", "60 9 * * *", "0 24 * * *", "0 9 0 * *", "0 9 * 13 *", "0 9 * * 8", "0 9 5-1 * *", "*/0 9 * * *", "x 9 * * *"} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q): expected error", expr)
+		}
+	}
+}
@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/payments"
+	"rip-go-app/internal/app/repository"
+	"rip-go-app/internal/app/workflow"
+)
+
+// ErrPaymentProvider - ошибка на стороне платёжного провайдера
+var ErrPaymentProvider = errors.New("ошибка платёжного провайдера")
+
+// errPaymentUnchanged - уведомление не меняет платёж, сохранять нечего
+var errPaymentUnchanged = errors.New("платёж не изменился")
+
+// payableStatuses - статусы заявки, в которых её можно оплатить. До завершения модерации
+// стоимость ещё может измениться, поэтому сформированная заявка не оплачивается
+var payableStatuses = map[string]bool{
+	ds.StatusCompleted: true,
+	ds.StatusShipped:   true,
+	ds.StatusDelivered: true,
+}
+
+// paymentRank - порядок статусов платежа; уведомления не могут вернуть платёж назад
+var paymentRank = map[string]int{
+	ds.PaymentPending:    0,
+	ds.PaymentAuthorized: 1,
+	ds.PaymentPaid:       2,
+	ds.PaymentRefunded:   3,
+}
+
+// PaymentStore - заявки, платежи и отмены для PaymentService (реализуется repository.Repository)
+type PaymentStore interface {
+	GetOrder(id int) (ds.Order, error)
+	GetPayments(orderID int) ([]ds.Payment, error)
+	GetActivePayment(orderID int) (ds.Payment, error)
+	GetPaymentByExternalID(provider, externalID string) (ds.Payment, error)
+	SavePayment(p *ds.Payment, actor workflow.Actor, reason string) error
+	// UpdateActivePayment и UpdatePaymentByExternalID держат платёж заблокированным от чтения до сохранения
+	UpdateActivePayment(orderID int, actor workflow.Actor, reason string, update func(p *ds.Payment) error) (ds.Payment, error)
+	UpdatePaymentByExternalID(provider, externalID string, actor workflow.Actor, reason string, update func(p *ds.Payment) error) (ds.Payment, error)
+	GetCancellation(orderID int) (ds.Cancellation, error)
+	MarkCancellationRefunded(orderID int) error
+}
+
+// PaymentService - оплата заявок через платёжного провайдера
+type PaymentService struct {
+	repo     PaymentStore
+	provider payments.Provider
+	currency string
+}
+
+// NewPaymentService - сервис оплаты с провайдером provider
+func NewPaymentService(repo PaymentStore, provider payments.Provider, currency string) *PaymentService {
+	if currency == "" {
+		currency = "RUB"
+	}
+	return &PaymentService{repo: repo, provider: provider, currency: currency}
+}
+
+// Provider - код используемого провайдера
+func (s *PaymentService) Provider() string {
+	return s.provider.Name()
+}
+
+// Pay - создание платежа на полную стоимость заявки. Если платёж уже ожидает подтверждения, возвращается он
+func (s *PaymentService) Pay(ctx context.Context, orderID int, actor workflow.Actor) (ds.Payment, error) {
+	order, err := s.repo.GetOrder(orderID)
+	if err != nil {
+		return ds.Payment{}, repository.ErrOrderNotFound
+	}
+
+	active, err := s.repo.GetActivePayment(orderID)
+	switch {
+	case err == nil && active.Status == ds.PaymentPending:
+		return active, nil
+	case err == nil:
+		return ds.Payment{}, fmt.Errorf("%w: заявка уже оплачена", repository.ErrPaymentState)
+	case !errors.Is(err, repository.ErrPaymentNotFound):
+		return ds.Payment{}, err
+	}
+
+	if !payableStatuses[order.Status] {
+		return ds.Payment{}, fmt.Errorf("%w: заявку в статусе %s нельзя оплатить", repository.ErrPaymentState, order.Status)
+	}
+	if order.TotalCost <= 0 {
+		return ds.Payment{}, fmt.Errorf("%w: стоимость заявки не рассчитана", repository.ErrPaymentState)
+	}
+
+	// Ключ меняется с каждой попыткой, чтобы после отказа можно было оплатить снова
+	attempts, err := s.repo.GetPayments(orderID)
+	if err != nil {
+		return ds.Payment{}, err
+	}
+	res, err := s.provider.Create(ctx, payments.CreateRequest{
+		OrderID:        orderID,
+		Amount:         order.TotalCost,
+		Currency:       s.currency,
+		Description:    fmt.Sprintf("Оплата заявки № %d", orderID),
+		IdempotencyKey: fmt.Sprintf("order-%d-%d", orderID, len(attempts)+1),
+	})
+	if err != nil {
+		return ds.Payment{}, fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+	}
+
+	payment := ds.Payment{
+		OrderID:         orderID,
+		Provider:        s.provider.Name(),
+		ExternalID:      res.ExternalID,
+		Amount:          res.Amount,
+		Currency:        s.currency,
+		Status:          res.Status,
+		ConfirmationURL: res.ConfirmationURL,
+	}
+	return payment, s.repo.SavePayment(&payment, actor, "payment created")
+}
+
+// Capture - списание заблокированной суммы. Платёж заблокирован от проверки до сохранения,
+// поэтому повторное списание видит уже списанный платёж
+func (s *PaymentService) Capture(ctx context.Context, orderID int, actor workflow.Actor) (ds.Payment, error) {
+	payment, err := s.repo.UpdateActivePayment(orderID, actor, "payment captured", func(payment *ds.Payment) error {
+		if payment.Status != ds.PaymentAuthorized {
+			return fmt.Errorf("%w: списать можно только авторизованный платёж", repository.ErrPaymentState)
+		}
+
+		res, err := s.provider.Capture(ctx, payment.ExternalID, payment.Amount)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+		}
+
+		payment.Status = ds.PaymentPaid
+		if res.Amount > 0 {
+			payment.Amount = res.Amount
+		}
+		return nil
+	})
+	if err != nil {
+		return ds.Payment{}, err
+	}
+	return payment, nil
+}
+
+// Refund - возврат суммы amount (0 - весь остаток). Платёж считается возвращённым, когда возвращена вся сумма.
+// Остаток проверяется под блокировкой платежа, поэтому параллельные возвраты не превышают списанное
+func (s *PaymentService) Refund(ctx context.Context, orderID int, amount float64, reason string, actor workflow.Actor) (ds.Payment, error) {
+	if reason == "" {
+		reason = "payment refunded"
+	}
+	return s.refund(ctx, orderID, reason, actor, func(ds.Payment) (float64, error) { return amount, nil })
+}
+
+// refund - возврат суммы, которую due определяет по заблокированному платежу (0 - весь остаток).
+// Ошибка due отменяет возврат
+func (s *PaymentService) refund(ctx context.Context, orderID int, reason string, actor workflow.Actor, due func(p ds.Payment) (float64, error)) (ds.Payment, error) {
+	payment, err := s.repo.UpdateActivePayment(orderID, actor, reason, func(payment *ds.Payment) error {
+		amount, err := due(*payment)
+		if err != nil {
+			return err
+		}
+		if payment.Status != ds.PaymentPaid {
+			return fmt.Errorf("%w: вернуть можно только списанный платёж", repository.ErrPaymentState)
+		}
+
+		remaining := payment.Amount - payment.RefundedAmount
+		if amount <= 0 {
+			amount = remaining
+		}
+		if amount > remaining+0.005 {
+			return fmt.Errorf("%w: к возврату доступно не более %.2f", repository.ErrPaymentState, remaining)
+		}
+
+		res, err := s.provider.Refund(ctx, payment.ExternalID, amount)
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrPaymentProvider, err)
+		}
+
+		payment.RefundedAmount += res.Amount
+		if payment.RefundedAmount >= payment.Amount-0.005 {
+			payment.Status = ds.PaymentRefunded
+		}
+		return nil
+	})
+	if err != nil {
+		return ds.Payment{}, err
+	}
+	return payment, s.markCancellationCovered(orderID, payment)
+}
+
+// markCancellationCovered - ожидающий возврат по отмене выполнен, если по платежу уже возвращено
+// не меньше положенного (например, бухгалтерия вернула деньги вручную)
+func (s *PaymentService) markCancellationCovered(orderID int, payment ds.Payment) error {
+	cancellation, err := s.repo.GetCancellation(orderID)
+	if errors.Is(err, repository.ErrCancellationNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cancellation.RefundStatus != ds.RefundPending || refundedSinceCancellation(cancellation, payment) < cancellation.RefundAmount-0.005 {
+		return nil
+	}
+	return s.repo.MarkCancellationRefunded(orderID)
+}
+
+// refundedSinceCancellation - сумма, возвращённая по платежу после отмены заявки
+// (при отмене PaidAmount - оплаченное за вычетом прежних возвратов)
+func refundedSinceCancellation(cancellation ds.Cancellation, payment ds.Payment) float64 {
+	return payment.RefundedAmount - (payment.Amount - cancellation.PaidAmount)
+}
+
+// SettleCancellation - возврат суммы по отмене заявки; заблокированная сумма сначала списывается,
+// чтобы штраф остался у перевозчика. Уже возвращённое вручную повторно не возвращается.
+// Если вернуть не удалось, возврат остаётся в ожидании и повторяется фоновой задачей
+func (s *PaymentService) SettleCancellation(ctx context.Context, orderID int, actor workflow.Actor) (ds.Cancellation, error) {
+	cancellation, err := s.repo.GetCancellation(orderID)
+	if err != nil {
+		return ds.Cancellation{}, err
+	}
+	if cancellation.RefundStatus != ds.RefundPending {
+		return cancellation, nil
+	}
+
+	payment, err := s.repo.GetActivePayment(orderID)
+	if err != nil {
+		return cancellation, err
+	}
+	if payment.Status == ds.PaymentAuthorized {
+		if payment, err = s.Capture(ctx, orderID, actor); err != nil {
+			return cancellation, err
+		}
+	}
+	// Сумма к возврату считается по заблокированному платежу: параллельный возврат не повторяется
+	_, err = s.refund(ctx, orderID, "cancellation", actor, func(p ds.Payment) (float64, error) {
+		payment = p
+		due := cancellation.RefundAmount - refundedSinceCancellation(cancellation, p)
+		if due <= 0.005 {
+			return 0, errPaymentUnchanged
+		}
+		return due, nil
+	})
+	if errors.Is(err, errPaymentUnchanged) {
+		err = s.markCancellationCovered(orderID, payment)
+	}
+	if err != nil {
+		return cancellation, err
+	}
+	return s.repo.GetCancellation(orderID)
+}
+
+// HandleWebhook - уведомление провайдера об изменении платежа. Повторные и запоздавшие уведомления игнорируются
+func (s *PaymentService) HandleWebhook(body []byte, header http.Header) (ds.Payment, error) {
+	res, err := s.provider.ParseWebhook(body, header)
+	if err != nil {
+		return ds.Payment{}, err
+	}
+
+	payment, err := s.repo.UpdatePaymentByExternalID(s.provider.Name(), res.ExternalID, workflow.Actor{}, "webhook", func(payment *ds.Payment) error {
+		if !paymentTransitionAllowed(payment.Status, res.Status) {
+			return errPaymentUnchanged
+		}
+
+		switch res.Status {
+		case ds.PaymentPaid:
+			if res.Amount > 0 {
+				payment.Amount = res.Amount
+			}
+		case ds.PaymentRefunded:
+			payment.RefundedAmount = payment.Amount
+		}
+		payment.Status = res.Status
+		return nil
+	})
+	if errors.Is(err, errPaymentUnchanged) {
+		return s.repo.GetPaymentByExternalID(s.provider.Name(), res.ExternalID)
+	}
+	if err != nil {
+		return ds.Payment{}, err
+	}
+	if payment.Status == ds.PaymentRefunded {
+		return payment, s.markCancellationCovered(payment.OrderID, payment)
+	}
+	return payment, nil
+}
+
+// paymentTransitionAllowed - допустимая смена статуса платежа по уведомлению
+func paymentTransitionAllowed(from, to string) bool {
+	if from == to {
+		return false
+	}
+	if to == ds.PaymentCancelled || to == ds.PaymentFailed {
+		return from == ds.PaymentPending || from == ds.PaymentAuthorized
+	}
+	fromRank, ok1 := paymentRank[from]
+	toRank, ok2 := paymentRank[to]
+	return ok1 && ok2 && toRank > fromRank
+}
@@ -0,0 +1,466 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"rip-go-app/internal/app/ds"
+	"rip-go-app/internal/app/payments"
+	"rip-go-app/internal/app/repository"
+	"rip-go-app/internal/app/workflow"
+)
+
+// memStore - PaymentStore в памяти. rowLock заменяет блокировку строки платежа в базе:
+// Update* держат его от чтения платежа до сохранения
+type memStore struct {
+	mu            sync.Mutex
+	rowLock       sync.Mutex
+	orders        map[int]ds.Order
+	payments      []ds.Payment
+	cancellations map[int]ds.Cancellation
+}
+
+func newMemStore(orders ...ds.Order) *memStore {
+	s := &memStore{orders: map[int]ds.Order{}, cancellations: map[int]ds.Cancellation{}}
+	for _, order := range orders {
+		s.orders[order.ID] = order
+	}
+	return s
+}
+
+func (s *memStore) GetOrder(id int) (ds.Order, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	order, ok := s.orders[id]
+	if !ok {
+		return ds.Order{}, repository.ErrOrderNotFound
+	}
+	return order, nil
+}
+
+func (s *memStore) GetPayments(orderID int) ([]ds.Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []ds.Payment
+	for i := len(s.payments) - 1; i >= 0; i-- {
+		if s.payments[i].OrderID == orderID {
+			res = append(res, s.payments[i])
+		}
+	}
+	return res, nil
+}
+
+func (s *memStore) GetActivePayment(orderID int) (ds.Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := len(s.payments) - 1; i >= 0; i-- {
+		p := s.payments[i]
+		if p.OrderID == orderID && p.Status != ds.PaymentCancelled && p.Status != ds.PaymentFailed {
+			return p, nil
+		}
+	}
+	return ds.Payment{}, repository.ErrPaymentNotFound
+}
+
+func (s *memStore) GetPaymentByExternalID(provider, externalID string) (ds.Payment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, p := range s.payments {
+		if p.Provider == provider && p.ExternalID == externalID {
+			return p, nil
+		}
+	}
+	return ds.Payment{}, repository.ErrPaymentNotFound
+}
+
+func (s *memStore) SavePayment(p *ds.Payment, actor workflow.Actor, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if p.ID == 0 {
+		p.ID = len(s.payments) + 1
+		s.payments = append(s.payments, *p)
+	} else {
+		s.payments[p.ID-1] = *p
+	}
+	order := s.orders[p.OrderID]
+	order.PaymentStatus = ds.OrderPaymentStatus(*p)
+	s.orders[p.OrderID] = order
+	return nil
+}
+
+func (s *memStore) UpdateActivePayment(orderID int, actor workflow.Actor, reason string, update func(p *ds.Payment) error) (ds.Payment, error) {
+	return s.update(func() (ds.Payment, error) { return s.GetActivePayment(orderID) }, actor, reason, update)
+}
+
+func (s *memStore) UpdatePaymentByExternalID(provider, externalID string, actor workflow.Actor, reason string, update func(p *ds.Payment) error) (ds.Payment, error) {
+	return s.update(func() (ds.Payment, error) { return s.GetPaymentByExternalID(provider, externalID) }, actor, reason, update)
+}
+
+func (s *memStore) update(find func() (ds.Payment, error), actor workflow.Actor, reason string, update func(p *ds.Payment) error) (ds.Payment, error) {
+	s.rowLock.Lock()
+	defer s.rowLock.Unlock()
+	p, err := find()
+	if err != nil {
+		return ds.Payment{}, err
+	}
+	if err := update(&p); err != nil {
+		return ds.Payment{}, err
+	}
+	return p, s.SavePayment(&p, actor, reason)
+}
+
+func (s *memStore) GetCancellation(orderID int) (ds.Cancellation, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.cancellations[orderID]
+	if !ok {
+		return ds.Cancellation{}, repository.ErrCancellationNotFound
+	}
+	return c, nil
+}
+
+func (s *memStore) MarkCancellationRefunded(orderID int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c := s.cancellations[orderID]
+	now := time.Now()
+	c.RefundStatus = ds.RefundComplete
+	c.RefundedAt = &now
+	s.cancellations[orderID] = c
+	return nil
+}
+
+// countingProvider - payments.Mock, считающий вызовы провайдера; возврат выполняется с задержкой,
+// чтобы параллельные возвраты пересекались
+type countingProvider struct {
+	*payments.Mock
+	mu         sync.Mutex
+	captures   int
+	refunds    int
+	failRefund bool
+}
+
+func (p *countingProvider) Capture(ctx context.Context, externalID string, amount float64) (payments.Result, error) {
+	p.mu.Lock()
+	p.captures++
+	p.mu.Unlock()
+	return p.Mock.Capture(ctx, externalID, amount)
+}
+
+func (p *countingProvider) Refund(ctx context.Context, externalID string, amount float64) (payments.Result, error) {
+	p.mu.Lock()
+	p.refunds++
+	fail := p.failRefund
+	p.mu.Unlock()
+	if fail {
+		return payments.Result{}, errors.New("provider unavailable")
+	}
+	time.Sleep(5 * time.Millisecond)
+	return p.Mock.Refund(ctx, externalID, amount)
+}
+
+var testActor = workflow.Actor{UserID: 1, Role: ds.RoleManager}
+
+func newTestService(orders ...ds.Order) (*PaymentService, *memStore, *countingProvider) {
+	store := newMemStore(orders...)
+	provider := &countingProvider{Mock: payments.NewMock("secret")}
+	return NewPaymentService(store, provider, ""), store, provider
+}
+
+// paidOrder - завершённая заявка с оплаченным (списанным) платежом на cost
+func paidOrder(t *testing.T, cost float64) (*PaymentService, *memStore, *countingProvider) {
+	t.Helper()
+	svc, store, provider := newTestService(ds.Order{ID: 1, Status: ds.StatusCompleted, TotalCost: cost})
+	ctx := context.Background()
+	if _, err := svc.Pay(ctx, 1, testActor); err != nil {
+		t.Fatalf("pay: %v", err)
+	}
+	if _, err := svc.Capture(ctx, 1, testActor); err != nil {
+		t.Fatalf("capture: %v", err)
+	}
+	return svc, store, provider
+}
+
+func equalAmount(a, b float64) bool {
+	return math.Abs(a-b) < 0.005
+}
+
+func TestPay(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   ds.Order
+		wantErr error
+	}{
+		{"completed", ds.Order{ID: 1, Status: ds.StatusCompleted, TotalCost: 1000}, nil},
+		{"shipped", ds.Order{ID: 1, Status: ds.StatusShipped, TotalCost: 1000}, nil},
+		{"delivered", ds.Order{ID: 1, Status: ds.StatusDelivered, TotalCost: 1000}, nil},
+		{"formed", ds.Order{ID: 1, Status: ds.StatusFormed, TotalCost: 1000}, repository.ErrPaymentState},
+		{"draft", ds.Order{ID: 1, Status: ds.StatusDraft, TotalCost: 1000}, repository.ErrPaymentState},
+		{"no cost", ds.Order{ID: 1, Status: ds.StatusCompleted}, repository.ErrPaymentState},
+		{"missing order", ds.Order{ID: 2, Status: ds.StatusCompleted, TotalCost: 1000}, repository.ErrOrderNotFound},
+	}
+
+	for _, tt := range tests {
+		svc, store, _ := newTestService(tt.order)
+		payment, err := svc.Pay(context.Background(), 1, testActor)
+		if !errors.Is(err, tt.wantErr) {
+			t.Errorf("%s: err = %v, want %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if tt.wantErr != nil {
+			continue
+		}
+		if payment.Status != ds.PaymentAuthorized || payment.Amount != tt.order.TotalCost {
+			t.Errorf("%s: payment = %s %.2f, want authorized %.2f", tt.name, payment.Status, payment.Amount, tt.order.TotalCost)
+		}
+		if got := store.orders[1].PaymentStatus; got != ds.PaymentAuthorized {
+			t.Errorf("%s: order payment status = %s, want authorized", tt.name, got)
+		}
+		if _, err := svc.Pay(context.Background(), 1, testActor); !errors.Is(err, repository.ErrPaymentState) {
+			t.Errorf("%s: second pay err = %v, want ErrPaymentState", tt.name, err)
+		}
+	}
+}
+
+func TestCaptureAndRefund(t *testing.T) {
+	svc, store, provider := paidOrder(t, 1000)
+	ctx := context.Background()
+
+	if _, err := svc.Capture(ctx, 1, testActor); !errors.Is(err, repository.ErrPaymentState) {
+		t.Errorf("second capture err = %v, want ErrPaymentState", err)
+	}
+	if provider.captures != 1 {
+		t.Errorf("captures = %d, want 1", provider.captures)
+	}
+
+	steps := []struct {
+		name         string
+		amount       float64
+		wantErr      error
+		wantRefunded float64
+		wantStatus   string
+	}{
+		{"partial", 300, nil, 300, ds.PaymentPaid},
+		{"over remaining", 800, repository.ErrPaymentState, 300, ds.PaymentPaid},
+		{"rest", 0, nil, 1000, ds.PaymentRefunded},
+		{"already refunded", 100, repository.ErrPaymentState, 1000, ds.PaymentRefunded},
+	}
+	for _, step := range steps {
+		_, err := svc.Refund(ctx, 1, step.amount, "", testActor)
+		if !errors.Is(err, step.wantErr) {
+			t.Errorf("%s: err = %v, want %v", step.name, err, step.wantErr)
+		}
+		payment, _ := store.GetActivePayment(1)
+		if !equalAmount(payment.RefundedAmount, step.wantRefunded) || payment.Status != step.wantStatus {
+			t.Errorf("%s: payment = %s refunded %.2f, want %s %.2f",
+				step.name, payment.Status, payment.RefundedAmount, step.wantStatus, step.wantRefunded)
+		}
+	}
+	if provider.refunds != 2 {
+		t.Errorf("refunds = %d, want 2", provider.refunds)
+	}
+	if got := store.orders[1].PaymentStatus; got != ds.PaymentRefunded {
+		t.Errorf("order payment status = %s, want refunded", got)
+	}
+}
+
+func TestRefundConcurrent(t *testing.T) {
+	svc, store, provider := paidOrder(t, 1000)
+
+	const workers = 8
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = svc.Refund(context.Background(), 1, 600, "", testActor)
+		}(i)
+	}
+	wg.Wait()
+
+	succeeded := 0
+	for _, err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case !errors.Is(err, repository.ErrPaymentState):
+			t.Errorf("unexpected err: %v", err)
+		}
+	}
+	if succeeded != 1 || provider.refunds != 1 {
+		t.Errorf("succeeded = %d, provider refunds = %d, want 1 and 1", succeeded, provider.refunds)
+	}
+	payment, _ := store.GetActivePayment(1)
+	if !equalAmount(payment.RefundedAmount, 600) {
+		t.Errorf("refunded = %.2f, want 600", payment.RefundedAmount)
+	}
+}
+
+func TestSettleCancellation(t *testing.T) {
+	ctx := context.Background()
+	cancellation := ds.Cancellation{OrderID: 1, PaidAmount: 1000, Fee: 200, RefundAmount: 800, RefundStatus: ds.RefundPending}
+
+	t.Run("authorized payment is captured and refunded", func(t *testing.T) {
+		svc, store, provider := newTestService(ds.Order{ID: 1, Status: ds.StatusCompleted, TotalCost: 1000})
+		if _, err := svc.Pay(ctx, 1, testActor); err != nil {
+			t.Fatalf("pay: %v", err)
+		}
+		store.cancellations[1] = cancellation
+
+		got, err := svc.SettleCancellation(ctx, 1, testActor)
+		if err != nil {
+			t.Fatalf("settle: %v", err)
+		}
+		if got.RefundStatus != ds.RefundComplete {
+			t.Errorf("refund status = %s, want refunded", got.RefundStatus)
+		}
+		payment, _ := store.GetActivePayment(1)
+		if payment.Status != ds.PaymentPaid || !equalAmount(payment.RefundedAmount, 800) {
+			t.Errorf("payment = %s refunded %.2f, want paid 800", payment.Status, payment.RefundedAmount)
+		}
+		if provider.captures != 1 || provider.refunds != 1 {
+			t.Errorf("captures = %d, refunds = %d, want 1 and 1", provider.captures, provider.refunds)
+		}
+
+		if _, err := svc.SettleCancellation(ctx, 1, testActor); err != nil {
+			t.Fatalf("second settle: %v", err)
+		}
+		if provider.refunds != 1 {
+			t.Errorf("second settle refunded again: refunds = %d", provider.refunds)
+		}
+	})
+
+	t.Run("manual refund covers cancellation", func(t *testing.T) {
+		svc, store, provider := paidOrder(t, 1000)
+		store.cancellations[1] = cancellation
+		// Бухгалтерия вернула часть, остаток возвращается при урегулировании
+		if _, err := svc.Refund(ctx, 1, 500, "manual", testActor); err != nil {
+			t.Fatalf("refund: %v", err)
+		}
+		if got, _ := store.GetCancellation(1); got.RefundStatus != ds.RefundPending {
+			t.Fatalf("partial refund completed cancellation")
+		}
+		if _, err := svc.SettleCancellation(ctx, 1, testActor); err != nil {
+			t.Fatalf("settle: %v", err)
+		}
+		payment, _ := store.GetActivePayment(1)
+		if !equalAmount(payment.RefundedAmount, 800) || provider.refunds != 2 {
+			t.Errorf("refunded = %.2f in %d refunds, want 800 in 2", payment.RefundedAmount, provider.refunds)
+		}
+
+		// Полный ручной возврат закрывает отмену без обращения к провайдеру
+		svc, store, provider = paidOrder(t, 1000)
+		store.cancellations[1] = cancellation
+		if _, err := svc.Refund(ctx, 1, 800, "manual", testActor); err != nil {
+			t.Fatalf("refund: %v", err)
+		}
+		if got, _ := svc.SettleCancellation(ctx, 1, testActor); got.RefundStatus != ds.RefundComplete {
+			t.Errorf("refund status = %s, want refunded", got.RefundStatus)
+		}
+		if provider.refunds != 1 {
+			t.Errorf("refunds = %d, want 1", provider.refunds)
+		}
+	})
+
+	t.Run("provider failure keeps refund pending", func(t *testing.T) {
+		svc, store, provider := paidOrder(t, 1000)
+		store.cancellations[1] = cancellation
+		provider.failRefund = true
+		if _, err := svc.SettleCancellation(ctx, 1, testActor); !errors.Is(err, ErrPaymentProvider) {
+			t.Errorf("err = %v, want ErrPaymentProvider", err)
+		}
+		if got, _ := store.GetCancellation(1); got.RefundStatus != ds.RefundPending {
+			t.Errorf("refund status = %s, want pending", got.RefundStatus)
+		}
+
+		provider.failRefund = false
+		if got, err := svc.SettleCancellation(ctx, 1, testActor); err != nil || got.RefundStatus != ds.RefundComplete {
+			t.Errorf("retry = %s, %v, want refunded", got.RefundStatus, err)
+		}
+	})
+
+	t.Run("not cancelled", func(t *testing.T) {
+		svc, _, _ := paidOrder(t, 1000)
+		if _, err := svc.SettleCancellation(ctx, 1, testActor); !errors.Is(err, repository.ErrCancellationNotFound) {
+			t.Errorf("err = %v, want ErrCancellationNotFound", err)
+		}
+	})
+}
+
+func TestHandleWebhook(t *testing.T) {
+	svc, store, provider := newTestService(ds.Order{ID: 1, Status: ds.StatusCompleted, TotalCost: 1000})
+	payment, err := svc.Pay(context.Background(), 1, testActor)
+	if err != nil {
+		t.Fatalf("pay: %v", err)
+	}
+	notify := func(status string, signed bool) (ds.Payment, error) {
+		body := []byte(fmt.Sprintf(`{"id": %q, "status": %q, "amount": 1000}`, payment.ExternalID, status))
+		header := http.Header{}
+		if signed {
+			header.Set(payments.MockSignatureHeader, provider.Sign(body))
+		}
+		return svc.HandleWebhook(body, header)
+	}
+
+	steps := []struct {
+		name       string
+		status     string
+		signed     bool
+		wantErr    error
+		wantStatus string
+	}{
+		{"unsigned", ds.PaymentPaid, false, payments.ErrInvalidSignature, ds.PaymentAuthorized},
+		{"paid", ds.PaymentPaid, true, nil, ds.PaymentPaid},
+		{"repeated", ds.PaymentPaid, true, nil, ds.PaymentPaid},
+		{"late authorized", ds.PaymentAuthorized, true, nil, ds.PaymentPaid},
+		{"cancelled after paid", ds.PaymentCancelled, true, nil, ds.PaymentPaid},
+		{"refunded", ds.PaymentRefunded, true, nil, ds.PaymentRefunded},
+	}
+	for _, step := range steps {
+		got, err := notify(step.status, step.signed)
+		if !errors.Is(err, step.wantErr) {
+			t.Errorf("%s: err = %v, want %v", step.name, err, step.wantErr)
+			continue
+		}
+		if step.wantErr == nil && got.Status != step.wantStatus {
+			t.Errorf("%s: returned status = %s, want %s", step.name, got.Status, step.wantStatus)
+		}
+		if stored, _ := store.GetPaymentByExternalID("mock", payment.ExternalID); stored.Status != step.wantStatus {
+			t.Errorf("%s: stored status = %s, want %s", step.name, stored.Status, step.wantStatus)
+		}
+	}
+	if stored, _ := store.GetActivePayment(1); !equalAmount(stored.RefundedAmount, 1000) {
+		t.Errorf("refunded = %.2f, want 1000", stored.RefundedAmount)
+	}
+}
+
+func TestPaymentTransitionAllowed(t *testing.T) {
+	tests := []struct {
+		from, to string
+		want     bool
+	}{
+		{ds.PaymentPending, ds.PaymentAuthorized, true},
+		{ds.PaymentPending, ds.PaymentPaid, true},
+		{ds.PaymentAuthorized, ds.PaymentPaid, true},
+		{ds.PaymentPaid, ds.PaymentRefunded, true},
+		{ds.PaymentAuthorized, ds.PaymentCancelled, true},
+		{ds.PaymentPending, ds.PaymentFailed, true},
+		{ds.PaymentPaid, ds.PaymentPaid, false},
+		{ds.PaymentPaid, ds.PaymentAuthorized, false},
+		{ds.PaymentRefunded, ds.PaymentPaid, false},
+		{ds.PaymentPaid, ds.PaymentCancelled, false},
+		{ds.PaymentCancelled, ds.PaymentPaid, false},
+	}
+	for _, tt := range tests {
+		if got := paymentTransitionAllowed(tt.from, tt.to); got != tt.want {
+			t.Errorf("paymentTransitionAllowed(%s, %s) = %v, want %v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package storage
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LocalStore - хранилище в каталоге на диске; ссылки ведут на /api/files и подписываются HMAC
+type LocalStore struct {
+	Root    string // каталог с файлами
+	BaseURL string // префикс ссылок на скачивание
+	secret  []byte
+}
+
+// NewLocalStore - хранилище в каталоге root; ссылки подписываются ключом secret
+func NewLocalStore(root, baseURL, secret string) (*LocalStore, error) {
+	if secret == "" {
+		return nil, fmt.Errorf("не задан ключ подписи ссылок")
+	}
+	if err := os.MkdirAll(root, 0o755); err != nil {
+		return nil, err
+	}
+	return &LocalStore{Root: root, BaseURL: strings.TrimRight(baseURL, "/"), secret: []byte(secret)}, nil
+}
+
+// path - путь к файлу; ключи с выходом за пределы каталога отклоняются
+func (s *LocalStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if key == "" || clean == "/" || clean != "/"+key {
+		return "", fmt.Errorf("некорректный ключ файла: %s", key)
+	}
+	return filepath.Join(s.Root, filepath.FromSlash(clean)), nil
+}
+
+// Put - запись во временный файл и переименование, чтобы не оставлять недописанных файлов
+func (s *LocalStore) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Get - открытие файла
+func (s *LocalStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+// Delete - удаление файла
+func (s *LocalStore) Delete(ctx context.Context, key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// SignedURL - ссылка вида /api/files/<key>?expires=...&name=...&type=...&sig=...
+func (s *LocalStore) SignedURL(key string, opts URLOptions) (string, error) {
+	if _, err := s.path(key); err != nil {
+		return "", err
+	}
+	expires := strconv.FormatInt(time.Now().Add(opts.TTL).Unix(), 10)
+
+	q := url.Values{}
+	q.Set("expires", expires)
+	if opts.FileName != "" {
+		q.Set("name", opts.FileName)
+	}
+	if opts.ContentType != "" {
+		q.Set("type", opts.ContentType)
+	}
+	q.Set("sig", s.sign(key, expires, opts.FileName, opts.ContentType))
+
+	return s.BaseURL + "/" + (&url.URL{Path: key}).EscapedPath() + "?" + q.Encode(), nil
+}
+
+// Verify - проверка подписи и срока действия ссылки
+func (s *LocalStore) Verify(key, expires, fileName, contentType, signature string) error {
+	unix, err := strconv.ParseInt(expires, 10, 64)
+	if err != nil || time.Now().Unix() > unix {
+		return ErrInvalidSignature
+	}
+	expected := s.sign(key, expires, fileName, contentType)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return ErrInvalidSignature
+	}
+	return nil
+}
+
+func (s *LocalStore) sign(key, expires, fileName, contentType string) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(strings.Join([]string{key, expires, fileName, contentType}, "\n")))
+	return hex.EncodeToString(mac.Sum(nil))
+}
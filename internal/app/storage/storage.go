@@ -0,0 +1,38 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+)
+
+// ErrNotFound - объект не найден в хранилище
+var ErrNotFound = errors.New("файл не найден")
+
+// ErrInvalidSignature - подпись ссылки неверна или срок её действия истёк
+var ErrInvalidSignature = errors.New("ссылка недействительна или устарела")
+
+// Store - хранилище файлов (локальный диск или S3-совместимое хранилище)
+type Store interface {
+	// Put - сохранение объекта под ключом key
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get - чтение объекта
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete - удаление объекта (отсутствующий объект ошибкой не считается)
+	Delete(ctx context.Context, key string) error
+	// SignedURL - ссылка на скачивание, действующая ограниченное время
+	SignedURL(key string, opts URLOptions) (string, error)
+}
+
+// URLOptions - параметры подписанной ссылки
+type URLOptions struct {
+	TTL         time.Duration // срок действия
+	FileName    string        // имя файла при скачивании
+	ContentType string        // тип содержимого ответа
+}
+
+// Verifier - хранилище, ссылки которого обслуживает само приложение и проверяет их подпись
+type Verifier interface {
+	Verify(key string, expires, fileName, contentType, signature string) error
+}
@@ -0,0 +1,178 @@
+package workflow
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"rip-go-app/internal/app/ds"
+)
+
+// Actor - пользователь, инициирующий переход
+type Actor struct {
+	UserID int
+	Role   string
+}
+
+// Guard - дополнительное условие перехода
+type Guard func(order *ds.Order, actor Actor) error
+
+// Transition - разрешённый переход между статусами заявки
+type Transition struct {
+	From  string
+	To    string
+	Roles []string // роли, которым разрешён переход
+	Guard Guard
+}
+
+var (
+	allRoles       = []string{ds.RoleBuyer, ds.RoleManager, ds.RoleAdmin}
+	moderatorRoles = []string{ds.RoleManager, ds.RoleAdmin}
+	carrierRoles   = []string{ds.RoleManager, ds.RoleAdmin, ds.RoleDriver}
+)
+
+// transitions - единая таблица переходов жизненного цикла заявки
+var transitions = []Transition{
+	{From: ds.StatusDraft, To: ds.StatusFormed, Roles: allRoles, Guard: readyToForm},
+	{From: ds.StatusDraft, To: ds.StatusDeleted, Roles: allRoles},
+	{From: ds.StatusFormed, To: ds.StatusCompleted, Roles: moderatorRoles, Guard: notClaimedByOther},
+	{From: ds.StatusFormed, To: ds.StatusRejected, Roles: moderatorRoles, Guard: notClaimedByOther},
+	{From: ds.StatusFormed, To: ds.StatusCancelled, Roles: allRoles},
+	{From: ds.StatusCompleted, To: ds.StatusShipped, Roles: carrierRoles, Guard: paidOrCredit},
+	{From: ds.StatusCompleted, To: ds.StatusCancelled, Roles: allRoles},
+	{From: ds.StatusShipped, To: ds.StatusDelivered, Roles: carrierRoles},
+	{From: ds.StatusRejected, To: ds.StatusDeleted, Roles: allRoles},
+	{From: ds.StatusCancelled, To: ds.StatusDeleted, Roles: allRoles},
+}
+
+// ErrForbidden - переход существует, но недоступен роли пользователя
+var ErrForbidden = errors.New("недостаточно прав для смены статуса")
+
+// TransitionError - недопустимый переход или не выполнено условие перехода
+type TransitionError struct {
+	From   string
+	To     string
+	Reason string
+}
+
+func (e *TransitionError) Error() string {
+	if e.Reason != "" {
+		return fmt.Sprintf("переход %s -> %s невозможен: %s", e.From, e.To, e.Reason)
+	}
+	return fmt.Sprintf("переход %s -> %s невозможен", e.From, e.To)
+}
+
+// Statuses - все статусы заявки
+func Statuses() []string {
+	return []string{
+		ds.StatusDraft, ds.StatusFormed, ds.StatusCompleted, ds.StatusRejected,
+		ds.StatusShipped, ds.StatusDelivered, ds.StatusCancelled, ds.StatusDeleted,
+	}
+}
+
+// IsStatus - известен ли статус
+func IsStatus(status string) bool {
+	for _, s := range Statuses() {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// Available - переходы из текущего статуса, доступные роли
+func Available(from, role string) []string {
+	var result []string
+	for _, t := range transitions {
+		if t.From == from && hasRole(t.Roles, role) {
+			result = append(result, t.To)
+		}
+	}
+	return result
+}
+
+// Check - проверка перехода без изменения заявки
+func Check(order *ds.Order, to string, actor Actor) error {
+	t, ok := find(order.Status, to)
+	if !ok {
+		return &TransitionError{From: order.Status, To: to}
+	}
+	if !hasRole(t.Roles, actor.Role) {
+		return ErrForbidden
+	}
+	if t.Guard != nil {
+		if err := t.Guard(order, actor); err != nil {
+			return &TransitionError{From: order.Status, To: to, Reason: err.Error()}
+		}
+	}
+	return nil
+}
+
+// Apply - проверка и выполнение перехода с заполнением системных полей
+func Apply(order *ds.Order, to string, actor Actor) error {
+	if err := Check(order, to, actor); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	switch to {
+	case ds.StatusFormed:
+		order.FormedAt = &now
+		order.IsDraft = false
+	case ds.StatusCompleted, ds.StatusRejected:
+		order.ModeratorID = &actor.UserID
+		order.CompletedAt = &now
+	}
+	order.Status = to
+
+	return nil
+}
+
+func find(from, to string) (Transition, bool) {
+	for _, t := range transitions {
+		if t.From == from && t.To == to {
+			return t, true
+		}
+	}
+	return Transition{}, false
+}
+
+func hasRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// readyToForm - заполнены маршрут, параметры груза и есть услуги
+func readyToForm(order *ds.Order, _ Actor) error {
+	if order.FromCity == "" || order.ToCity == "" {
+		return errors.New("не указан маршрут")
+	}
+	if order.Weight <= 0 || order.Length <= 0 || order.Width <= 0 || order.Height <= 0 {
+		return errors.New("не заполнены параметры груза")
+	}
+	if len(order.Services) == 0 {
+		return errors.New("в заявке нет услуг")
+	}
+	return nil
+}
+
+// notClaimedByOther - заявку, взятую в работу другим менеджером, решает только он или администратор
+func notClaimedByOther(order *ds.Order, actor Actor) error {
+	if actor.Role == ds.RoleAdmin || !order.ClaimedByOther(actor.UserID, time.Now()) {
+		return nil
+	}
+	return errors.New("заявка взята в работу другим менеджером")
+}
+
+// paidOrCredit - отгрузка начинается после оплаты или для клиента с одобренной отсрочкой
+// (ожидается предзагруженный Creator)
+func paidOrCredit(order *ds.Order, _ Actor) error {
+	if order.PaymentStatus == ds.PaymentPaid || order.Creator.CreditApproved {
+		return nil
+	}
+	return errors.New("заявка не оплачена")
+}